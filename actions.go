@@ -0,0 +1,76 @@
+package main
+
+import "github.com/gdamore/tcell/v2"
+
+// actionHandler is what an action does, whether run from its keys or from the command palette.
+type actionHandler struct {
+	// run performs the action. From the command palette, args holds the answers to the prompts; from the keys, it is
+	// empty and the action asks for what it needs itself.
+	run func(args []string)
+
+	// prompts are asked for in the command palette before the action is run.
+	prompts []actionPrompt
+
+	// available reports whether the action can be run now. Nil means always.
+	available func() bool
+}
+
+// actionPrompt is an argument of an action, asked for in the command palette.
+type actionPrompt struct {
+	label string
+
+	// choices returns the values to pick from. Nil means any text.
+	choices func() []string
+}
+
+// registerAction sets what an action does, replacing what it did before: the time entry actions, for example, are
+// registered again for every selected work package.
+func (tui *Tui) registerAction(name string, handler actionHandler) {
+	tui.actions[name] = handler
+}
+
+// onAction registers an action without prompts that can always be run.
+func (tui *Tui) onAction(name string, run func()) {
+	tui.registerAction(name, actionHandler{run: func([]string) { run() }})
+}
+
+// actionAvailable reports whether an action is registered and can be run now.
+func (tui *Tui) actionAvailable(name string) bool {
+	handler, ok := tui.actions[name]
+	return ok && (handler.available == nil || handler.available())
+}
+
+// runAction runs an action if it is available, and reports whether it did.
+func (tui *Tui) runAction(name string, args []string) bool {
+	if !tui.actionAvailable(name) {
+		return false
+	}
+	tui.actions[name].run(args)
+	return true
+}
+
+// actionGroup returns the group of an action, which tells where its keys work.
+func actionGroup(name string) string {
+	for _, action := range keyActions {
+		if action.name == name {
+			return action.group
+		}
+	}
+	return ""
+}
+
+// runKeyAction runs the action of a group bound to the key of an event. It returns the event if there is none.
+func (tui *Tui) runKeyAction(event *tcell.EventKey, group string) *tcell.EventKey {
+	action := tui.keys.action(event)
+	if actionGroup(action) != group || !tui.runAction(action, nil) {
+		return event
+	}
+	return nil
+}
+
+// navigationShown reports whether the work packages and their time entries are shown, rather than another page or a
+// form.
+func (tui *Tui) navigationShown() bool {
+	name, _ := tui.Pages.GetFrontPage()
+	return name == "navigation"
+}
@@ -0,0 +1,37 @@
+package main
+
+import "time"
+
+// Backend is what the UI and the commands need from OpenProject: the work packages and time entries of the user, and
+// the changes made offline. `Client` implements it against the API, `MemoryBackend` in memory.
+type Backend interface {
+	// GetCurrentUser returns the user the credentials belong to.
+	GetCurrentUser() (*User, error)
+
+	GetWorkPackage(workPackageId int) (*WorkPackage, error)
+	// ListWorkPackages returns the open work packages assigned to a user, most recently updated first.
+	ListWorkPackages(userId int) (*WorkPackageCollection, error)
+	// SearchWorkPackages returns the work packages, in any project and status, whose subject contains a text.
+	SearchWorkPackages(subject string) (*WorkPackageCollection, error)
+	// ListStatuses returns the statuses a work package can be set to.
+	ListStatuses(workPackageId int) ([]Status, error)
+	UpdateWorkPackageStatus(workPackageId, statusId int) error
+
+	// ListTimeEntries returns the time entries of a work package, by day.
+	ListTimeEntries(workPackageId int) (*TimeEntryCollection, error)
+	ListTimeEntriesBefore(userId int, days int) (*TimeEntryCollection, error)
+	// ListTimeEntriesBetween returns the time entries of a user spent between two dates (inclusive), by day.
+	ListTimeEntriesBetween(userId int, start, end time.Time) (*TimeEntryCollection, error)
+	CreateTimeEntry(te *TimeEntryRequest) error
+	UpdateTimeEntry(timeEntryId int, update map[string]interface{}) error
+	UpdateTimeEntryDuration(timeEntryId int, duration string, comment string, spendOn string) error
+	DeleteTimeEntry(id int) error
+
+	SyncStatus() SyncStatus
+	PendingOperations() []PendingOperation
+	ReplayPending() (int, error)
+	ResolvePending(op PendingOperation, keepMine bool) error
+	Sync(userId int) (bool, error)
+}
+
+var _ Backend = (*Client)(nil)
@@ -0,0 +1,291 @@
+package main
+
+import (
+	"fmt"
+	"github.com/rivo/tview"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// bulkWorkers is the number of requests that bulk actions run concurrently.
+const bulkWorkers = 4
+
+// markText returns the text of the first column of a time entry row, which flags marked entries and entries with
+// changes that are not on the server yet.
+func markText(marked bool, te TimeEntry) string {
+	text := fmt.Sprintf("  %d", idFromHref(te.Links.WorkPackage.Href))
+	if marked {
+		text = fmt.Sprintf("* %d", idFromHref(te.Links.WorkPackage.Href))
+	}
+	if te.Pending {
+		text += " (pending)"
+	}
+	return text
+}
+
+// toggleMark marks or unmarks the selected time entry and moves to the next one.
+func (tui *Tui) toggleMark() {
+	row, _ := tui.TimeEntriesTable.GetSelection()
+	if row < 1 || row > len(tui.timeEntries) {
+		return
+	}
+	te := tui.timeEntries[row-1]
+	if tui.marked[te.Id] {
+		delete(tui.marked, te.Id)
+	} else {
+		tui.marked[te.Id] = true
+	}
+	tui.TimeEntriesTable.GetCell(row, 0).SetText(markText(tui.marked[te.Id], te))
+	if row < len(tui.timeEntries) {
+		tui.TimeEntriesTable.Select(row+1, 0)
+	}
+}
+
+// selectedTimeEntry returns the time entry in the selected row, if any.
+func (tui *Tui) selectedTimeEntry() *TimeEntry {
+	row, _ := tui.TimeEntriesTable.GetSelection()
+	if row < 1 || row > len(tui.timeEntries) {
+		return nil
+	}
+	return &tui.timeEntries[row-1]
+}
+
+// selectedTimeEntries returns the marked time entries or, if none is marked, the selected one.
+func (tui *Tui) selectedTimeEntries() []TimeEntry {
+	var selected []TimeEntry
+	for _, te := range tui.timeEntries {
+		if tui.marked[te.Id] {
+			selected = append(selected, te)
+		}
+	}
+	if te := tui.selectedTimeEntry(); len(selected) == 0 && te != nil {
+		selected = append(selected, *te)
+	}
+	return selected
+}
+
+func (tui *Tui) showBulkActions(client Backend, workPackageIndex int) {
+	entries := tui.selectedTimeEntries()
+	if len(entries) == 0 {
+		return
+	}
+
+	closeMenu := func() {
+		tui.Pages.RemovePage("bulkActions")
+		tui.App.SetFocus(tui.TimeEntriesTable)
+	}
+
+	list := tview.NewList().ShowSecondaryText(false)
+	list.AddItem("Delete", "", 'd', func() {
+		closeMenu()
+		tui.showBulkConfirm(fmt.Sprintf("Are you sure you want to delete %d time entries?", len(entries)), func() {
+			tui.runBulk("Deleting", entries, workPackageIndex, func(te TimeEntry) error {
+				return client.DeleteTimeEntry(te.Id)
+			})
+		})
+	})
+	list.AddItem("Move to work package", "", 'm', func() {
+		closeMenu()
+		tui.showBulkInput("Move", "Work package ID", "", func(value string) error {
+			workPackageId, err := strconv.Atoi(strings.TrimPrefix(value, "#"))
+			if err != nil {
+				return fmt.Errorf("invalid work package ID: %s", value)
+			}
+			tui.runBulk("Moving", entries, workPackageIndex, func(te TimeEntry) error {
+				update := map[string]interface{}{
+					"_links": map[string]interface{}{"workPackage": map[string]string{"href": workPackageHref(workPackageId)}},
+				}
+				return client.UpdateTimeEntry(te.Id, update)
+			})
+			return nil
+		})
+	})
+	list.AddItem("Shift dates", "", 's', func() {
+		closeMenu()
+		tui.showBulkInput("Shift Dates", "Days (e.g. -1 or 7)", "1", func(value string) error {
+			days, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid number of days: %s", value)
+			}
+			tui.runBulk("Shifting", entries, workPackageIndex, func(te TimeEntry) error {
+				date, err := time.Parse("2006-01-02", te.Date)
+				if err != nil {
+					return err
+				}
+				update := map[string]interface{}{"spentOn": date.AddDate(0, 0, days).Format("2006-01-02")}
+				return client.UpdateTimeEntry(te.Id, update)
+			})
+			return nil
+		})
+	})
+	list.AddItem("Change activity", "", 'a', func() {
+		closeMenu()
+		tui.showBulkInput("Change Activity", "Activity ID", "", func(value string) error {
+			activityId, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("invalid activity ID: %s", value)
+			}
+			tui.runBulk("Changing activity", entries, workPackageIndex, func(te TimeEntry) error {
+				update := map[string]interface{}{
+					"_links": map[string]interface{}{"activity": map[string]string{"href": activityHref(activityId)}},
+				}
+				return client.UpdateTimeEntry(te.Id, update)
+			})
+			return nil
+		})
+	})
+	list.AddItem("Set comment", "", 'c', func() {
+		closeMenu()
+		tui.showBulkInput("Set Comment", "Comment", "", func(value string) error {
+			tui.runBulk("Commenting", entries, workPackageIndex, func(te TimeEntry) error {
+				update := map[string]interface{}{"comment": map[string]string{"raw": value}}
+				return client.UpdateTimeEntry(te.Id, update)
+			})
+			return nil
+		})
+	})
+	list.AddItem("Normalise durations", "", 'n', func() {
+		closeMenu()
+		changed, err := normaliseTimeEntries(entries, &tui.rounding)
+		if err != nil {
+			tui.ShowError(err)
+			return
+		}
+		if len(changed) == 0 {
+			tui.Notify("The durations already follow the rounding rules.")
+			return
+		}
+		tui.showBulkConfirm(fmt.Sprintf("Round the duration of %d time entries?", len(changed)), func() {
+			tui.runBulk("Normalising", changed, workPackageIndex, func(te TimeEntry) error {
+				return client.UpdateTimeEntry(te.Id, map[string]interface{}{"hours": te.Hours})
+			})
+		})
+	})
+	list.SetDoneFunc(closeMenu)
+
+	list.SetBorder(true).SetTitle(fmt.Sprintf("Bulk Actions (%d entries)", len(entries))).SetTitleAlign(tview.AlignCenter)
+
+	tui.Pages.AddPage("bulkActions", tui.Modal(list, 45, 8), true, true)
+}
+
+// showBulkConfirm asks for confirmation before running a bulk action.
+func (tui *Tui) showBulkConfirm(text string, onConfirm func()) {
+	closeForm := func() {
+		tui.Pages.RemovePage("bulkConfirm")
+		tui.App.SetFocus(tui.TimeEntriesTable)
+	}
+
+	form := tview.NewForm()
+	form.AddTextView("", text, 0, 0, false, true).
+		AddButton("Yes", func() {
+			closeForm()
+			onConfirm()
+		}).
+		AddButton("Quit", closeForm)
+
+	form.SetBorder(true).SetTitle("Bulk Action").SetTitleAlign(tview.AlignCenter)
+	form.SetCancelFunc(closeForm)
+
+	tui.Pages.AddPage("bulkConfirm", tui.Modal(form, 45, 11), true, true)
+}
+
+// showBulkInput asks for the single value a bulk action needs. An error returned by `apply` is shown to the user.
+func (tui *Tui) showBulkInput(title, label, value string, apply func(value string) error) {
+	closeForm := func() {
+		tui.Pages.RemovePage("bulkInput")
+		tui.App.SetFocus(tui.TimeEntriesTable)
+	}
+
+	form := tview.NewForm()
+	form.AddInputField(label, value, 0, nil, nil).
+		AddButton("Apply", func() {
+			value := strings.TrimSpace(form.GetFormItem(0).(*tview.InputField).GetText())
+			closeForm()
+			if err := apply(value); err != nil {
+				tui.ShowError(err)
+			}
+		}).
+		AddButton("Quit", closeForm)
+
+	form.SetBorder(true).SetTitle(title).SetTitleAlign(tview.AlignCenter)
+	form.SetCancelFunc(closeForm)
+
+	tui.Pages.AddPage("bulkInput", tui.Modal(form, 45, 7), true, true)
+}
+
+// runBulk applies an action to several time entries concurrently. The progress is shown while it runs, and a summary
+// with the failed entries once it is done.
+func (tui *Tui) runBulk(title string, entries []TimeEntry, workPackageIndex int, action func(te TimeEntry) error) {
+	progress := tview.NewTextView().SetTextAlign(tview.AlignCenter)
+	progress.SetBorder(true).SetTitle(title).SetTitleAlign(tview.AlignCenter)
+
+	var (
+		mu       sync.Mutex
+		done     int
+		failures []string
+	)
+	showProgress := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		progress.SetText(fmt.Sprintf("%d/%d done, %d failed", done, len(entries), len(failures)))
+	}
+	showProgress()
+	tui.Pages.AddPage("bulkProgress", tui.Modal(progress, 45, 3), true, true)
+
+	go func() {
+		var wg sync.WaitGroup
+		workers := make(chan struct{}, bulkWorkers)
+		for _, te := range entries {
+			te := te
+			wg.Add(1)
+			workers <- struct{}{}
+			go func() {
+				defer wg.Done()
+				err := action(te)
+				<-workers
+
+				mu.Lock()
+				done++
+				if err != nil {
+					failures = append(failures, fmt.Sprintf("%s: %v", describeTimeEntry(te), err))
+				}
+				mu.Unlock()
+				tui.App.QueueUpdateDraw(showProgress)
+			}()
+		}
+		wg.Wait()
+
+		tui.App.QueueUpdateDraw(func() {
+			tui.Pages.RemovePage("bulkProgress")
+			tui.marked = make(map[int]bool)
+			tui.reloadWorkPackage(workPackageIndex)
+			tui.showBulkSummary(title, len(entries), failures)
+		})
+	}()
+}
+
+// describeTimeEntry returns a short reference to a time entry for messages. Entries that don't exist yet have no ID.
+func describeTimeEntry(te TimeEntry) string {
+	if te.Id != 0 {
+		return fmt.Sprintf("%d", te.Id)
+	}
+	return fmt.Sprintf("#%d on %s", idFromHref(te.Links.WorkPackage.Href), te.Date)
+}
+
+func (tui *Tui) showBulkSummary(title string, total int, failures []string) {
+	text := fmt.Sprintf("%s: %d succeeded, %d failed.", title, total-len(failures), len(failures))
+	if len(failures) > 0 {
+		text += "\n\n" + strings.Join(failures, "\n")
+	}
+	modal := tview.NewModal().
+		SetText(text).
+		AddButtons([]string{"OK"}).
+		SetDoneFunc(func(_ int, _ string) {
+			tui.Pages.RemovePage("bulkSummary")
+			tui.App.SetFocus(tui.TimeEntriesTable)
+		})
+	theme.apply(modal)
+	tui.Pages.AddPage("bulkSummary", modal, true, true)
+}
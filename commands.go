@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// runSetupCommand runs the commands that work without a valid config, `lazyop init` and `lazyop config validate`.
+// It reports whether args was one of them. configPath is the path given with the `-config` flag, if any.
+func runSetupCommand(configPath string, args []string) (bool, error) {
+	switch {
+	case len(args) >= 1 && args[0] == "init":
+		return true, runInit(configPath, args[1:])
+	case len(args) >= 2 && args[0] == "config" && args[1] == "validate":
+		return true, runConfigValidate(configPath, args[2:])
+	}
+	return false, nil
+}
+
+// runAuthCommand runs `lazyop auth login` and `lazyop auth logout`, which manage the OAuth2 tokens of the active
+// profile before a client is created with them. It reports whether args was one of them.
+func runAuthCommand(config *Config, args []string) (bool, error) {
+	if len(args) != 2 || args[0] != "auth" {
+		return false, nil
+	}
+	if !config.Auth.UsesOAuth2() {
+		return true, fmt.Errorf("profile %s doesn't use OAuth2", config.ProfileName)
+	}
+	switch args[1] {
+	case "login":
+		if err := loginOAuth2(config.ProfileName, &config.Profile); err != nil {
+			return true, err
+		}
+		fmt.Printf("Logged in to profile %s.\n", config.ProfileName)
+		return true, nil
+	case "logout":
+		if err := deleteToken(config.ProfileName); err != nil {
+			return true, err
+		}
+		fmt.Printf("Logged out of profile %s.\n", config.ProfileName)
+		return true, nil
+	}
+	return false, nil
+}
+
+// timeCommands are the `lazyop time` commands. They read from the mirror, which runCommand brings up to date first.
+var timeCommands = map[string]func(client Backend, config *Config, args []string) error{
+	"import":    runTimeImport,
+	"templates": runTimeTemplates,
+	"normalise": runTimeNormalise,
+}
+
+// runCommand runs a non-interactive command given on the command line, e.g. `lazyop time import`.
+func runCommand(client Backend, config *Config, args []string) error {
+	if len(args) == 1 && args[0] == "sync" {
+		return runSync(client, config)
+	}
+	var run func(client Backend, config *Config, args []string) error
+	if len(args) >= 2 && args[0] == "time" {
+		run = timeCommands[args[1]]
+	}
+	if run == nil {
+		return fmt.Errorf("unknown command: %s", strings.Join(args, " "))
+	}
+
+	// Offline, the commands work with the last copy of the mirror.
+	client.ReplayPending()
+	if _, err := client.Sync(config.UserID); err != nil && !isOffline(err) {
+		return fmt.Errorf("error syncing: %v", err)
+	}
+	return run(client, config, args[2:])
+}
+
+// runSync implements `lazyop sync`, which replays the changes made offline and syncs the mirror.
+func runSync(client Backend, config *Config) error {
+	replayed, err := client.ReplayPending()
+	if err != nil {
+		return fmt.Errorf("error replaying pending changes: %v", err)
+	}
+	if _, err := client.Sync(config.UserID); err != nil {
+		return fmt.Errorf("error syncing: %v", err)
+	}
+	status := client.SyncStatus()
+	fmt.Printf("Replayed %d pending changes, %d still pending (%d conflicts). Mirror synced.\n", replayed, status.Pending, status.Conflicts)
+	return nil
+}
+
+// stdin reads the answers to the questions asked on the terminal.
+var stdin = bufio.NewReader(os.Stdin)
+
+// confirm asks a yes/no question on the terminal. Anything but `y` or `yes` is a no.
+func confirm(prompt string) bool {
+	fmt.Printf("%s [y/N] ", prompt)
+	answer, _ := stdin.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
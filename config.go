@@ -4,34 +4,234 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 )
 
-type Config struct {
+// defaultProfileName is the name of the profile defined at the top level of the config file.
+const defaultProfileName = "default"
+
+// Profile holds the settings to connect to an OpenProject instance.
+type Profile struct {
 	BaseURL string `json:"base_url"`
 	UserID  int    `json:"user_id"`
 	APIKey  string `json:"api_key"`
+
+	// The API key can be kept out of the config file, see `resolveAPIKey` for the order these are tried in.
+	APIKeyEnv     string `json:"api_key_env"`
+	APIKeyCmd     string `json:"api_key_cmd"`
+	APIKeyFile    string `json:"api_key_file"`
+	APIKeyKeyring bool   `json:"api_key_keyring"`
+
+	// Auth selects OAuth2 instead of the API key.
+	Auth Auth `json:"auth"`
+
+	// HTTP are the proxy, TLS and header settings of the connection.
+	HTTP HTTPOptions `json:"http"`
+}
+
+type Config struct {
+	// Profile is the active profile. When read from the file, it is the `default` profile defined at the top level.
+	Profile
+
+	// ProfileName is the name of the active profile.
+	ProfileName string `json:"-"`
+
+	// Profiles are named OpenProject instances, selected with the `-profile` flag or switched at runtime.
+	Profiles map[string]Profile `json:"profiles"`
+
+	// DefaultProfile is the profile used when none is given.
+	DefaultProfile string `json:"default_profile"`
+
+	// HoursPerDay is the length of a day in the durations typed in days, e.g. `2d`. Defaults to 8.
+	HoursPerDay float64 `json:"hours_per_day"`
+
+	// Rounding are the rules applied to the durations of new and edited time entries.
+	Rounding Rounding `json:"rounding"`
+
+	// Templates are recurring time entries that can be logged at once for a day or week.
+	Templates []Template `json:"templates"`
+
+	// Keymap changes the keys bound to the actions of the UI.
+	Keymap Keymap `json:"keymap"`
+
+	// Theme is the name of the theme of the UI: a built-in one or one of Themes.
+	Theme string `json:"theme"`
+
+	// Themes are the user themes.
+	Themes map[string]Theme `json:"themes"`
 }
 
-func ReadConfig() (*Config, error) {
-	paths := []string{
-		"./config.json",
-		"$HOME/.config/lazyop/config.json",
+// configEnv is the environment variable that gives the path of the config file, like the `-config` flag.
+const configEnv = "LAZYOP_CONFIG"
+
+// configFileNames are the names the config file may have, in order of preference. The format is given by the
+// extension.
+var configFileNames = []string{"config.json", "config.jsonc", "config.yaml", "config.yml", "config.toml"}
+
+// configDirs returns the directories searched for the config file, in order: the working directory, then the XDG
+// base directories for configuration, i.e. `$XDG_CONFIG_HOME/lazyop` and `$XDG_CONFIG_DIRS/lazyop`.
+func configDirs() []string {
+	dirs := []string{".", filepath.Join(configHome(), "lazyop")}
+	configDirs := os.Getenv("XDG_CONFIG_DIRS")
+	if configDirs == "" {
+		configDirs = "/etc/xdg"
 	}
-	var config Config
-	for _, path := range paths {
-		expandedPath := os.ExpandEnv(path)
-		if fileExists(expandedPath) {
-			data, err := os.ReadFile(expandedPath)
-			if err != nil {
-				return nil, fmt.Errorf("error reading file %s: %v", expandedPath, err)
-			}
-			if err := json.Unmarshal(data, &config); err != nil {
-				return nil, fmt.Errorf("error parsing file %s: %v", expandedPath, err)
+	for _, dir := range filepath.SplitList(configDirs) {
+		// Relative paths are invalid according to the XDG specification and must be ignored.
+		if filepath.IsAbs(dir) {
+			dirs = append(dirs, filepath.Join(dir, "lazyop"))
+		}
+	}
+	return dirs
+}
+
+// configHome returns `$XDG_CONFIG_HOME`, which defaults to `~/.config`.
+func configHome() string {
+	if dir := os.Getenv("XDG_CONFIG_HOME"); filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(os.Getenv("HOME"), ".config")
+}
+
+// stateHome returns `$XDG_STATE_HOME`, which defaults to `~/.local/state`. lazyop keeps its tokens and local store
+// there.
+func stateHome() string {
+	if dir := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(dir) {
+		return dir
+	}
+	return filepath.Join(os.Getenv("HOME"), ".local", "state")
+}
+
+// defaultConfigPath is where `lazyop init` writes the config file.
+func defaultConfigPath() string {
+	return filepath.Join(configHome(), "lazyop", "config.json")
+}
+
+// ReadConfig reads the config file given by path, or the first one found if path is empty (see `findConfigFile`).
+// Without a config file, the settings may be given entirely by environment variables (see `applyEnvOverrides`).
+func ReadConfig(path string) (*Config, error) {
+	path, err := findConfigFile(path)
+	var config *Config
+	switch {
+	case err == nil:
+		config, err = loadConfig(path)
+	case os.Getenv(envPrefix+"BASE_URL") != "":
+		path = "environment"
+		config, err = parseConfig(path, []byte("{}"))
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := config.Rounding.Validate(); err != nil {
+		return nil, fmt.Errorf("error in file %s: %v", path, err)
+	}
+	return config, nil
+}
+
+// findConfigFile returns the path of the config file. An explicit path, from the `-config` flag or `$LAZYOP_CONFIG`,
+// must exist; otherwise the first file found in `configDirs` is used.
+func findConfigFile(path string) (string, error) {
+	if path == "" {
+		path = os.Getenv(configEnv)
+	}
+	if path != "" {
+		if !fileExists(path) {
+			return "", fmt.Errorf("config file %s not found", path)
+		}
+		return path, nil
+	}
+	for _, dir := range configDirs() {
+		for _, name := range configFileNames {
+			if path := filepath.Join(dir, name); fileExists(path) {
+				return path, nil
 			}
-			return &config, nil
 		}
 	}
-	return nil, fmt.Errorf("no config file found")
+	return "", fmt.Errorf("no config file found, run `lazyop init` to create one")
+}
+
+// loadConfig parses a config file. Settings at the top level of the file become the `default` profile.
+func loadConfig(path string) (*Config, error) {
+	data, err := readConfigFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseConfig(path, data)
+}
+
+// parseConfig parses the config, as JSON, read from origin and applies the environment variable overrides.
+func parseConfig(origin string, data []byte) (*Config, error) {
+	var config Config
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("error parsing file %s: %v", origin, err)
+	}
+	if err := applyEnvOverrides(&config); err != nil {
+		return nil, err
+	}
+	if config.BaseURL != "" || len(config.Profiles) == 0 {
+		if config.Profiles == nil {
+			config.Profiles = make(map[string]Profile)
+		}
+		if _, ok := config.Profiles[defaultProfileName]; !ok {
+			config.Profiles[defaultProfileName] = config.Profile
+		}
+	}
+	return &config, nil
+}
+
+// ProfileNames returns the names of the profiles, sorted.
+func (c *Config) ProfileNames() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SelectProfile makes the named profile the active one. If name is empty, `default_profile` is used, then the
+// `default` profile, then the only profile if there is just one.
+func (c *Config) SelectProfile(name string) error {
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	if name == "" {
+		if _, ok := c.Profiles[defaultProfileName]; ok || len(c.Profiles) != 1 {
+			name = defaultProfileName
+		} else {
+			name = c.ProfileNames()[0]
+		}
+	}
+	profile, ok := c.Profiles[name]
+	if !ok {
+		if len(c.Profiles) == 0 {
+			return fmt.Errorf("no profile defined")
+		}
+		return fmt.Errorf("unknown profile %q, available profiles: %s", name, strings.Join(c.ProfileNames(), ", "))
+	}
+	if err := applyEnvOverrides(&profile); err != nil {
+		return err
+	}
+	baseURL, err := NormalizeBaseURL(profile.BaseURL)
+	if err != nil {
+		return fmt.Errorf("profile %s: %v", name, err)
+	}
+	profile.BaseURL = baseURL
+	if err := profile.Auth.Validate(); err != nil {
+		return fmt.Errorf("profile %s: %v", name, err)
+	}
+	if !profile.Auth.UsesOAuth2() {
+		apiKey, err := profile.resolveAPIKey(name)
+		if err != nil {
+			return fmt.Errorf("profile %s: %v", name, err)
+		}
+		profile.APIKey = apiKey
+	}
+	c.Profile = profile
+	c.ProfileName = name
+	return nil
 }
 
 func fileExists(path string) bool {
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// envPrefix is the prefix of the environment variables that override the settings of the config file, e.g.
+// `LAZYOP_BASE_URL` or `LAZYOP_ROUNDING_INCREMENT`.
+const envPrefix = "LAZYOP_"
+
+// readConfigFile reads a config file and returns it as JSON. The format is given by the extension: `.yaml` and `.yml`
+// are YAML, `.toml` is TOML and anything else is JSON, which may contain comments and trailing commas.
+func readConfigFile(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("error reading file %s: %v", path, err)
+	}
+
+	var document map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &document)
+	case ".toml":
+		err = toml.Unmarshal(data, &document)
+	default:
+		return stripJSONComments(data), nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error parsing file %s: %v", path, err)
+	}
+	if document == nil {
+		// An empty YAML document.
+		return []byte("{}"), nil
+	}
+	data, err = json.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("error parsing file %s: %v", path, err)
+	}
+	return data, nil
+}
+
+// stripJSONComments removes the `//` and `/* */` comments and the trailing commas from JSON with comments. Removed
+// characters are replaced with spaces, so that the offsets in parse errors stay correct.
+func stripJSONComments(data []byte) []byte {
+	out := make([]byte, len(data))
+	copy(out, data)
+	blank := func(from, to int) {
+		for i := from; i < to; i++ {
+			if out[i] != '\n' {
+				out[i] = ' '
+			}
+		}
+	}
+
+	lastComma := -1
+	for i := 0; i < len(out); i++ {
+		switch c := out[i]; {
+		case c == '"':
+			for i++; i < len(out) && out[i] != '"'; i++ {
+				if out[i] == '\\' {
+					i++
+				}
+			}
+		case c == '/' && i+1 < len(out) && out[i+1] == '/':
+			end := bytes.IndexByte(out[i:], '\n')
+			if end < 0 {
+				end = len(out) - i
+			}
+			blank(i, i+end)
+			i += end - 1
+			continue
+		case c == '/' && i+1 < len(out) && out[i+1] == '*':
+			end := bytes.Index(out[i+2:], []byte("*/"))
+			if end < 0 {
+				// Leave the unterminated comment for the parser to report.
+				return out
+			}
+			blank(i, i+end+4)
+			i += end + 3
+			continue
+		case c == ',':
+			lastComma = i
+			continue
+		case (c == '}' || c == ']') && lastComma >= 0:
+			out[lastComma] = ' '
+		case c == ' ' || c == '\t' || c == '\r' || c == '\n':
+			continue
+		}
+		lastComma = -1
+	}
+	return out
+}
+
+// applyEnvOverrides sets the settings of a `Config` or `Profile` that are given by environment variables. Every
+// setting except profiles and templates can be overridden: the variable is the JSON name in upper case, prefixed
+// with `LAZYOP_` and the names of its parents, e.g. `LAZYOP_API_KEY` or `LAZYOP_ROUNDING_MODE`.
+func applyEnvOverrides(target interface{}) error {
+	return applyEnvOverridesTo(reflect.ValueOf(target).Elem(), envPrefix)
+}
+
+func applyEnvOverridesTo(v reflect.Value, prefix string) error {
+	for i := 0; i < v.NumField(); i++ {
+		field, value := v.Type().Field(i), v.Field(i)
+		if field.Anonymous {
+			if err := applyEnvOverridesTo(value, prefix); err != nil {
+				return err
+			}
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		env := prefix + strings.ToUpper(name)
+		if value.Kind() == reflect.Struct {
+			if err := applyEnvOverridesTo(value, env+"_"); err != nil {
+				return err
+			}
+			continue
+		}
+
+		text, ok := os.LookupEnv(env)
+		if !ok {
+			continue
+		}
+		var err error
+		switch value.Kind() {
+		case reflect.String:
+			value.SetString(text)
+		case reflect.Int:
+			var n int64
+			n, err = strconv.ParseInt(text, 10, 0)
+			value.SetInt(n)
+		case reflect.Float64:
+			var f float64
+			f, err = strconv.ParseFloat(text, 64)
+			value.SetFloat(f)
+		case reflect.Bool:
+			var b bool
+			b, err = strconv.ParseBool(text)
+			value.SetBool(b)
+		}
+		if err != nil {
+			return fmt.Errorf("invalid value %q for %s", text, env)
+		}
+	}
+	return nil
+}
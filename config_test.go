@@ -0,0 +1,350 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// The same config in every format read by `readConfigFile`.
+const (
+	jsoncConfig = `{
+    // The default profile.
+    "base_url": "https://op.example.com",
+    "user_id": 7,
+    "api_key": "default-key",
+    "profiles": {
+        "work": {"base_url": "https://work.example.com", "user_id": 8, "api_key": "work-key", "http": {"headers": {"X-Team": "web"}}},
+    },
+    "default_profile": "work",
+    /* Bill in quarter hours. */
+    "hours_per_day": 7.5,
+    "rounding": {"mode": "up", "increment": 15, "minimum": 15},
+    "templates": [
+        {"work_package": 42, "duration": "15m", "comment": "Standup", "recurrence": "weekdays"},
+    ],
+    "keymap": {"log time": ["l", "Ctrl+L"]},
+    "theme": "light",
+}
+`
+	yamlConfig = `# The default profile.
+base_url: https://op.example.com
+user_id: 7
+api_key: default-key
+profiles:
+  work:
+    base_url: https://work.example.com
+    user_id: 8
+    api_key: work-key
+    http:
+      headers:
+        X-Team: web
+default_profile: work
+hours_per_day: 7.5
+rounding:
+  mode: up
+  increment: 15
+  minimum: 15
+templates:
+  - work_package: 42
+    duration: 15m
+    comment: Standup
+    recurrence: weekdays
+keymap:
+  log time: [l, Ctrl+L]
+theme: light
+`
+	tomlConfig = `# The default profile.
+base_url = "https://op.example.com"
+user_id = 7
+api_key = "default-key"
+default_profile = "work"
+hours_per_day = 7.5
+keymap = {"log time" = ["l", "Ctrl+L"]}
+theme = "light"
+
+[profiles.work]
+base_url = "https://work.example.com"
+user_id = 8
+api_key = "work-key"
+http = {headers = {X-Team = "web"}}
+
+[rounding]
+mode = "up"
+increment = 15
+minimum = 15
+
+[[templates]]
+work_package = 42
+duration = "15m"
+comment = "Standup"
+recurrence = "weekdays"
+`
+)
+
+// writeConfig writes a config file in a temporary directory.
+func writeConfig(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigFormats(t *testing.T) {
+	want, err := loadConfig(writeConfig(t, "config.jsonc", jsoncConfig))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want.UserID != 7 || want.Profiles["work"].HTTP.Headers["X-Team"] != "web" || want.Rounding.Increment != 15 ||
+		len(want.Templates) != 1 || want.Templates[0].WorkPackageId != 42 || want.HoursPerDay != 7.5 {
+		t.Fatalf("config read from JSON with comments = %+v", want)
+	}
+
+	for _, test := range []struct {
+		name, content string
+	}{
+		{"config.json", jsoncConfig},
+		{"config.yaml", yamlConfig},
+		{"config.yml", yamlConfig},
+		{"CONFIG.YML", yamlConfig},
+		{"config.toml", tomlConfig},
+	} {
+		got, err := loadConfig(writeConfig(t, test.name, test.content))
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: config = %+v, want %+v", test.name, got, want)
+		}
+	}
+}
+
+func TestConfigFormatFromExtension(t *testing.T) {
+	for _, test := range []struct {
+		name, content, want string
+	}{
+		// Anything but YAML and TOML is JSON.
+		{"config", jsoncConfig, ""},
+		{"config.conf", yamlConfig, "error parsing file"},
+		{"config.json", tomlConfig, "error parsing file"},
+		{"config.yaml", tomlConfig, "error parsing file"},
+		{"config.toml", yamlConfig, "error parsing file"},
+		{"config.yaml", "", ""},
+		{"config.json", "{\"user_id\": \"seven\"}", "error parsing file"},
+	} {
+		_, err := loadConfig(writeConfig(t, test.name, test.content))
+		if test.want == "" && err != nil || test.want != "" && (err == nil || !strings.Contains(err.Error(), test.want)) {
+			t.Errorf("%s: error = %v, want %q", test.name, err, test.want)
+		}
+	}
+}
+
+func TestFindConfigFile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	t.Setenv("XDG_CONFIG_DIRS", t.TempDir())
+	t.Setenv(configEnv, "")
+	if _, err := findConfigFile(""); err == nil {
+		t.Error("no error without a config file")
+	}
+
+	dir := filepath.Join(home, "lazyop")
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"config.toml", "config.yaml", "config.jsonc"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		if got, err := findConfigFile(""); err != nil || got != filepath.Join(dir, name) {
+			t.Errorf("config file with %s added = %s, %v, want it", name, got, err)
+		}
+	}
+
+	// An explicit path wins, and must exist.
+	explicit := writeConfig(t, "lazyop.yaml", yamlConfig)
+	t.Setenv(configEnv, explicit)
+	if got, err := findConfigFile(""); err != nil || got != explicit {
+		t.Errorf("config file from %s = %s, %v, want %s", configEnv, got, err, explicit)
+	}
+	if _, err := findConfigFile(explicit + ".missing"); err == nil {
+		t.Error("no error with a missing explicit config file")
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	t.Setenv("WORK_KEY", "work-key-from-env")
+	path := writeConfig(t, "config.jsonc", `{
+    "profiles": {
+        "work": {
+            "base_url": "https://work.example.com",
+            "user_id": 8,
+            "api_key": "work-key",
+            "api_key_env": "WORK_KEY",
+            "auth": {"type": "apikey", "client_id": "work-client", "scope": "api_v3"},
+            "http": {"proxy": "http://proxy.example.com:3128", "user_agent": "work", "ca_file": "work.pem"},
+        },
+    },
+    "hours_per_day": 7.5,
+    "rounding": {"mode": "up", "increment": 15, "minimum": 15},
+    "theme": "light",
+}`)
+
+	for _, test := range []struct {
+		env  map[string]string
+		get  func(c *Config) interface{}
+		want interface{}
+	}{
+		{
+			env:  map[string]string{"LAZYOP_BASE_URL": "env.example.com"},
+			get:  func(c *Config) interface{} { return c.BaseURL },
+			want: "https://env.example.com/api/v3/",
+		},
+		{
+			env:  map[string]string{"LAZYOP_USER_ID": "9"},
+			get:  func(c *Config) interface{} { return c.UserID },
+			want: 9,
+		},
+		{
+			env:  map[string]string{"LAZYOP_API_KEY": "env-key", "LAZYOP_API_KEY_ENV": ""},
+			get:  func(c *Config) interface{} { return c.APIKey },
+			want: "env-key",
+		},
+		{
+			env:  map[string]string{"LAZYOP_API_KEY_ENV": "OTHER_KEY", "OTHER_KEY": "other-key"},
+			get:  func(c *Config) interface{} { return c.APIKey },
+			want: "other-key",
+		},
+		{
+			env:  map[string]string{"LAZYOP_API_KEY_CMD": "pass show openproject"},
+			get:  func(c *Config) interface{} { return c.APIKeyCmd },
+			want: "pass show openproject",
+		},
+		{
+			env:  map[string]string{"LAZYOP_API_KEY_FILE": "~/.op-key"},
+			get:  func(c *Config) interface{} { return c.APIKeyFile },
+			want: "~/.op-key",
+		},
+		{
+			env:  map[string]string{"LAZYOP_API_KEY_KEYRING": "true"},
+			get:  func(c *Config) interface{} { return c.APIKeyKeyring },
+			want: true,
+		},
+		{
+			env:  map[string]string{"LAZYOP_AUTH_TYPE": "oauth2"},
+			get:  func(c *Config) interface{} { return c.Auth.UsesOAuth2() },
+			want: true,
+		},
+		{
+			env:  map[string]string{"LAZYOP_AUTH_CLIENT_ID": "env-client"},
+			get:  func(c *Config) interface{} { return c.Auth.ClientID },
+			want: "env-client",
+		},
+		{
+			env:  map[string]string{"LAZYOP_AUTH_SCOPE": "api_v3 bcf_v2_1"},
+			get:  func(c *Config) interface{} { return c.Auth.Scope },
+			want: "api_v3 bcf_v2_1",
+		},
+		{
+			env:  map[string]string{"LAZYOP_HTTP_PROXY": "http://env-proxy.example.com:8080"},
+			get:  func(c *Config) interface{} { return c.HTTP.Proxy },
+			want: "http://env-proxy.example.com:8080",
+		},
+		{
+			env:  map[string]string{"LAZYOP_HTTP_USER_AGENT": "env"},
+			get:  func(c *Config) interface{} { return c.HTTP.UserAgent },
+			want: "env",
+		},
+		{
+			env:  map[string]string{"LAZYOP_HTTP_CA_FILE": "env.pem"},
+			get:  func(c *Config) interface{} { return c.HTTP.CAFile },
+			want: "env.pem",
+		},
+		{
+			env:  map[string]string{"LAZYOP_HTTP_INSECURE_SKIP_VERIFY": "1"},
+			get:  func(c *Config) interface{} { return c.HTTP.InsecureSkipVerify },
+			want: true,
+		},
+		{
+			env:  map[string]string{"LAZYOP_HOURS_PER_DAY": "6"},
+			get:  func(c *Config) interface{} { return c.HoursPerDay },
+			want: 6.0,
+		},
+		{
+			env:  map[string]string{"LAZYOP_ROUNDING_MODE": "down"},
+			get:  func(c *Config) interface{} { return c.Rounding.Mode },
+			want: "down",
+		},
+		{
+			env:  map[string]string{"LAZYOP_ROUNDING_INCREMENT": "6"},
+			get:  func(c *Config) interface{} { return c.Rounding.Increment },
+			want: 6,
+		},
+		{
+			env:  map[string]string{"LAZYOP_ROUNDING_MINIMUM": "0"},
+			get:  func(c *Config) interface{} { return c.Rounding.Minimum },
+			want: 0,
+		},
+		{
+			env:  map[string]string{"LAZYOP_THEME": "dark"},
+			get:  func(c *Config) interface{} { return c.Theme },
+			want: "dark",
+		},
+	} {
+		for name, value := range test.env {
+			t.Setenv(name, value)
+		}
+		config, err := ReadConfig(path)
+		if err == nil {
+			err = config.SelectProfile("work")
+		}
+		if err != nil {
+			t.Errorf("%v: %v", test.env, err)
+		} else if got := test.get(config); got != test.want {
+			t.Errorf("%v: setting = %v, want %v", test.env, got, test.want)
+		}
+		for name := range test.env {
+			os.Unsetenv(name)
+		}
+	}
+}
+
+func TestInvalidEnvOverrides(t *testing.T) {
+	path := writeConfig(t, "config.json", `{"base_url": "https://op.example.com", "api_key": "key"}`)
+	for env, value := range map[string]string{
+		"LAZYOP_USER_ID":                   "seven",
+		"LAZYOP_HOURS_PER_DAY":             "many",
+		"LAZYOP_HTTP_INSECURE_SKIP_VERIFY": "sure",
+		"LAZYOP_ROUNDING_INCREMENT":        "-15",
+		"LAZYOP_ROUNDING_MODE":             "sideways",
+	} {
+		t.Setenv(env, value)
+		if _, err := ReadConfig(path); err == nil {
+			t.Errorf("%s=%s: no error", env, value)
+		}
+		os.Unsetenv(env)
+	}
+}
+
+func TestConfigFromEnvironmentOnly(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	t.Setenv("XDG_CONFIG_DIRS", t.TempDir())
+	t.Setenv(configEnv, "")
+	t.Setenv("LAZYOP_BASE_URL", "https://op.example.com")
+	t.Setenv("LAZYOP_API_KEY", "env-key")
+	config, err := ReadConfig("")
+	if err == nil {
+		err = config.SelectProfile("")
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.ProfileName != defaultProfileName || config.BaseURL != "https://op.example.com/api/v3/" || config.APIKey != "env-key" {
+		t.Errorf("profile %s = %+v", config.ProfileName, config.Profile)
+	}
+}
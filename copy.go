@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// startOfWeek returns the Monday of the week of the given date, at midnight.
+func startOfWeek(date time.Time) time.Time {
+	weekday := (int(date.Weekday()) + 6) % 7
+	year, month, day := date.AddDate(0, 0, -weekday).Date()
+	return time.Date(year, month, day, 0, 0, 0, 0, date.Location())
+}
+
+// showCopyTimeEntriesForm copies, after confirmation, the time entries the user logged between two dates onto the
+// same days `offset` days later. Entries that already exist on the target day are not copied again.
+func (tui *Tui) showCopyTimeEntriesForm(client Backend, userId int, workPackageIndex int, label string, start, end time.Time, offset int) {
+	timeEntries, err := client.ListTimeEntriesBetween(userId, start, end)
+	if err != nil {
+		tui.NotifyError(err)
+		return
+	}
+	existing, err := existingTimeEntries(client, userId, start.AddDate(0, 0, offset), end.AddDate(0, 0, offset))
+	if err != nil {
+		tui.NotifyError(err)
+		return
+	}
+
+	var entries []TimeEntry
+	for _, te := range timeEntries.Embedded.Elements {
+		hours, err := ParseIso8601(te.Hours)
+		if err != nil {
+			tui.NotifyError(err)
+			return
+		}
+		date, err := time.Parse("2006-01-02", te.Date)
+		if err != nil {
+			tui.NotifyError(err)
+			return
+		}
+		te.Date = date.AddDate(0, 0, offset).Format("2006-01-02")
+		if !existing[timeEntryKey(idFromHref(te.Links.WorkPackage.Href), te.Date, hours, te.Comment.Raw)] {
+			entries = append(entries, te)
+		}
+	}
+	if len(entries) == 0 {
+		tui.Notify(fmt.Sprintf("There are no time entries from %s left to copy.", label))
+		return
+	}
+
+	title := fmt.Sprintf("Copying %s", label)
+	text := fmt.Sprintf("Copy %d time entries from %s?", len(entries), label)
+	tui.showBulkConfirm(text, func() {
+		tui.runBulk(title, entries, workPackageIndex, func(te TimeEntry) error {
+			return createTimeEntryCopy(client, userId, te)
+		})
+	})
+}
+
+// createTimeEntryCopy logs a new time entry with the same work package, activity, duration, date and comment as te.
+func createTimeEntryCopy(client Backend, userId int, te TimeEntry) error {
+	hours, err := ParseIso8601(te.Hours)
+	if err != nil {
+		return err
+	}
+	activityId := idFromHref(te.Links.Activity.Href)
+	if activityId == 0 {
+		activityId = defaultActivityId
+	}
+	request := NewTimeEntryRequest(userId, idFromHref(te.Links.WorkPackage.Href), activityId, hours, te.Comment.Raw, te.Date)
+	return client.CreateTimeEntry(request)
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"lazyop/openprojecttest"
+	"time"
+)
+
+// startDemo starts a local stand-in for OpenProject with sample data, see `openprojecttest.NewDemoServer`, and
+// returns a config and a client for it. Nothing is stored locally: the changes are lost when lazyop exits.
+func startDemo() (*Config, *Client) {
+	server := openprojecttest.NewDemoServer(time.Now())
+	config := &Config{ProfileName: "demo"}
+	config.BaseURL = server.BaseURL()
+	config.APIKey = openprojecttest.APIKey
+	config.Profiles = map[string]Profile{"demo": config.Profile}
+	return config, NewClient(config.BaseURL, "apikey", config.APIKey)
+}
@@ -0,0 +1,378 @@
+package main
+
+import (
+	"encoding/csv"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// Formats supported by `lazyop time import`.
+const (
+	importFormatCSV      = "csv"
+	importFormatToggl    = "toggl"
+	importFormatClockify = "clockify"
+)
+
+var (
+	// importDateLayouts are the date layouts accepted in imported files, tried in order.
+	importDateLayouts = []string{"2006-01-02", "01/02/2006", "02.01.2006"}
+
+	// workPackageReference matches a work package reference such as `#1234` in free text.
+	workPackageReference = regexp.MustCompile(`#(\d+)`)
+)
+
+// importColumns lists, for every field of an imported row, the header names it may be read from.
+// The first non-empty column wins.
+type importColumns struct {
+	workPackage []string
+	subject     []string
+	date        []string
+	duration    []string
+	comment     []string
+}
+
+var importFormats = map[string]importColumns{
+	importFormatCSV: {
+		workPackage: []string{"work_package", "work package"},
+		subject:     []string{"subject"},
+		date:        []string{"date", "spent_on"},
+		duration:    []string{"duration", "hours"},
+		comment:     []string{"comment"},
+	},
+	importFormatToggl: {
+		subject:  []string{"task", "description"},
+		date:     []string{"start date"},
+		duration: []string{"duration"},
+		comment:  []string{"description"},
+	},
+	importFormatClockify: {
+		subject:  []string{"task", "description"},
+		date:     []string{"start date"},
+		duration: []string{"duration (h)", "duration (decimal)"},
+		comment:  []string{"description"},
+	},
+}
+
+// ImportRow is a single time entry read from an imported file, or materialised from a template.
+type ImportRow struct {
+	// Line is the line of the imported file, or the number of the template.
+	Line          int
+	WorkPackageId int
+	ActivityId    int
+	// Subject is used to look up the work package when no ID is given.
+	Subject  string
+	Date     string
+	Duration Duration
+	Comment  string
+	// Err is set when the row can't be imported.
+	Err error
+	// Exists is set when the same time entry has already been logged.
+	Exists bool
+}
+
+// ReadImportFile reads time entries from a CSV file. If format is empty, it is detected from the header.
+func ReadImportFile(r io.Reader, format string) ([]ImportRow, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading csv: %v", err)
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("empty file")
+	}
+
+	header := make(map[string]int)
+	for i, name := range records[0] {
+		header[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if format == "" {
+		format = detectImportFormat(header)
+	}
+	columns, ok := importFormats[format]
+	if !ok {
+		return nil, fmt.Errorf("unknown format: %s", format)
+	}
+
+	rows := make([]ImportRow, 0, len(records)-1)
+	for i, record := range records[1:] {
+		rows = append(rows, parseImportRecord(i+2, record, header, columns))
+	}
+	return rows, nil
+}
+
+// detectImportFormat guesses the format of a file from its header.
+func detectImportFormat(header map[string]int) string {
+	if _, ok := header["duration (h)"]; ok {
+		return importFormatClockify
+	}
+	if _, ok := header["start date"]; ok {
+		return importFormatToggl
+	}
+	return importFormatCSV
+}
+
+func parseImportRecord(line int, record []string, header map[string]int, columns importColumns) ImportRow {
+	value := func(names []string) string {
+		for _, name := range names {
+			if i, ok := header[name]; ok && i < len(record) && strings.TrimSpace(record[i]) != "" {
+				return strings.TrimSpace(record[i])
+			}
+		}
+		return ""
+	}
+
+	row := ImportRow{
+		Line:    line,
+		Subject: value(columns.subject),
+		Comment: value(columns.comment),
+	}
+
+	if wp := value(columns.workPackage); wp != "" {
+		id, err := strconv.Atoi(strings.TrimPrefix(wp, "#"))
+		if err != nil {
+			row.Err = fmt.Errorf("invalid work package ID %q", wp)
+			return row
+		}
+		row.WorkPackageId = id
+	} else if match := workPackageReference.FindStringSubmatch(row.Subject + " " + row.Comment); match != nil {
+		row.WorkPackageId, _ = strconv.Atoi(match[1])
+	}
+
+	date, err := parseImportDate(value(columns.date))
+	if err != nil {
+		row.Err = err
+		return row
+	}
+	row.Date = date
+
+	duration, err := parseImportDuration(value(columns.duration))
+	if err != nil {
+		row.Err = err
+		return row
+	}
+	row.Duration = *duration
+	return row
+}
+
+// parseImportDate parses a date in any of the accepted layouts and returns it as `YYYY-MM-DD`.
+func parseImportDate(value string) (string, error) {
+	if value == "" {
+		return "", fmt.Errorf("missing date")
+	}
+	for _, layout := range importDateLayouts {
+		if date, err := time.Parse(layout, value); err == nil {
+			return date.Format("2006-01-02"), nil
+		}
+	}
+	return "", fmt.Errorf("invalid date %q", value)
+}
+
+// parseImportDuration parses a duration of an imported row, e.g. `01:30:00`, `1.5` (decimal hours) or `1h30m`.
+func parseImportDuration(value string) (*Duration, error) {
+	if value == "" {
+		return nil, fmt.Errorf("missing duration")
+	}
+	d, err := Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	if d.IsZero() {
+		return nil, fmt.Errorf("duration %q is zero", value)
+	}
+	return d, nil
+}
+
+// resolveImportRows looks up the work package of rows that only reference it by subject.
+func resolveImportRows(client Backend, rows []ImportRow) {
+	type result struct {
+		id  int
+		err error
+	}
+	cache := make(map[string]result)
+	for i := range rows {
+		row := &rows[i]
+		if row.Err != nil || row.WorkPackageId != 0 {
+			continue
+		}
+		if row.Subject == "" {
+			row.Err = fmt.Errorf("no work package ID or subject")
+			continue
+		}
+		key := strings.ToLower(row.Subject)
+		res, ok := cache[key]
+		if !ok {
+			res.id, res.err = findWorkPackageBySubject(client, row.Subject)
+			cache[key] = res
+		}
+		row.WorkPackageId, row.Err = res.id, res.err
+	}
+}
+
+// findWorkPackageBySubject returns the ID of the only work package whose subject matches the given one.
+// An exact (case-insensitive) match is preferred over a partial one.
+func findWorkPackageBySubject(client Backend, subject string) (int, error) {
+	collection, err := client.SearchWorkPackages(subject)
+	if err != nil {
+		return 0, err
+	}
+	candidates := collection.Embedded.Elements
+	var matches []WorkPackage
+	for _, wp := range candidates {
+		if strings.EqualFold(strings.TrimSpace(wp.Subject), subject) {
+			matches = append(matches, wp)
+		}
+	}
+	if len(matches) == 0 && len(candidates) == 1 {
+		matches = candidates
+	}
+	switch len(matches) {
+	case 0:
+		return 0, fmt.Errorf("no work package matches %q", subject)
+	case 1:
+		return matches[0].Id, nil
+	default:
+		return 0, fmt.Errorf("%d work packages match %q", len(matches), subject)
+	}
+}
+
+// roundImportRows applies the rounding rules to the duration of the rows.
+func roundImportRows(rows []ImportRow, rounding *Rounding) {
+	for i := range rows {
+		rows[i].Duration = *rounding.Round(&rows[i].Duration)
+	}
+}
+
+// markExistingImportRows flags the rows that have already been logged by the user.
+func markExistingImportRows(client Backend, userId int, rows []ImportRow) error {
+	start, end := importRowsPeriod(rows)
+	if start.IsZero() {
+		return nil
+	}
+
+	existing, err := existingTimeEntries(client, userId, start, end)
+	if err != nil {
+		return err
+	}
+	for i := range rows {
+		row := &rows[i]
+		if row.Err == nil {
+			row.Exists = existing[timeEntryKey(row.WorkPackageId, row.Date, &row.Duration, row.Comment)]
+		}
+	}
+	return nil
+}
+
+// importRowsPeriod returns the first and last dates of the valid rows, or zero times if there are none.
+func importRowsPeriod(rows []ImportRow) (time.Time, time.Time) {
+	var start, end time.Time
+	for _, row := range rows {
+		if row.Err != nil {
+			continue
+		}
+		date, _ := time.Parse("2006-01-02", row.Date)
+		if start.IsZero() || date.Before(start) {
+			start = date
+		}
+		if end.IsZero() || date.After(end) {
+			end = date
+		}
+	}
+	return start, end
+}
+
+// printImportPreview writes a table with the rows that would be imported and why others would not.
+// origin names what `ImportRow.Line` refers to, e.g. `line`.
+func printImportPreview(w io.Writer, origin string, rows []ImportRow) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "%s\tWORK PACKAGE\tDATE\tDURATION\tCOMMENT\tSTATUS\n", strings.ToUpper(origin))
+	for _, row := range rows {
+		status := "new"
+		switch {
+		case row.Err != nil:
+			status = fmt.Sprintf("error: %v", row.Err)
+		case row.Exists:
+			status = "exists, skipped"
+		}
+		workPackage := ""
+		if row.WorkPackageId != 0 {
+			workPackage = fmt.Sprintf("#%d", row.WorkPackageId)
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\n", row.Line, workPackage, row.Date, row.Duration.ToString(), row.Comment, status)
+	}
+	tw.Flush()
+}
+
+// runTimeImport implements `lazyop time import [flags] <file>`.
+func runTimeImport(client Backend, config *Config, args []string) error {
+	flags := flag.NewFlagSet("time import", flag.ExitOnError)
+	format := flags.String("format", "", "input format: csv, toggl or clockify (detected from the header by default)")
+	dryRun := flags.Bool("dry-run", false, "only show what would be imported")
+	yes := flags.Bool("yes", false, "import without asking for confirmation")
+	activityId := flags.Int("activity", defaultActivityId, "activity ID of the imported time entries")
+	flags.Parse(args)
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: lazyop time import [-format csv|toggl|clockify] [-activity id] [-dry-run] [-yes] <file>")
+	}
+
+	file, err := os.Open(flags.Arg(0))
+	if err != nil {
+		return fmt.Errorf("error opening file: %v", err)
+	}
+	defer file.Close()
+
+	rows, err := ReadImportFile(file, *format)
+	if err != nil {
+		return err
+	}
+	resolveImportRows(client, rows)
+	for i := range rows {
+		rows[i].ActivityId = *activityId
+	}
+	roundImportRows(rows, &config.Rounding)
+	if err := markExistingImportRows(client, config.UserID, rows); err != nil {
+		return err
+	}
+	return createImportRows(client, config.UserID, "line", rows, *dryRun, *yes)
+}
+
+// createImportRows shows a preview of the rows and, unless it's a dry run, creates the valid ones that don't exist
+// yet after confirmation.
+func createImportRows(client Backend, userId int, origin string, rows []ImportRow, dryRun, yes bool) error {
+	printImportPreview(os.Stdout, origin, rows)
+
+	var pending []ImportRow
+	for _, row := range rows {
+		if row.Err == nil && !row.Exists {
+			pending = append(pending, row)
+		}
+	}
+	if dryRun || len(pending) == 0 {
+		return nil
+	}
+	if !yes && !confirm(fmt.Sprintf("Create %d time entries?", len(pending))) {
+		return nil
+	}
+
+	failed := 0
+	for _, row := range pending {
+		te := NewTimeEntryRequest(userId, row.WorkPackageId, row.ActivityId, &row.Duration, row.Comment, row.Date)
+		if err := client.CreateTimeEntry(te); err != nil {
+			fmt.Fprintf(os.Stderr, "%s %d: %v\n", origin, row.Line, err)
+			failed++
+		}
+	}
+	fmt.Printf("Created %d time entries, %d failed.\n", len(pending)-failed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d time entries could not be created", failed, len(pending))
+	}
+	return nil
+}
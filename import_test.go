@@ -0,0 +1,149 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestDetectImportFormat(t *testing.T) {
+	for _, test := range []struct {
+		header string
+		want   string
+	}{
+		{"work_package,date,duration,comment", importFormatCSV},
+		{"subject,spent_on,hours", importFormatCSV},
+		{"User,Email,Client,Project,Task,Description,Billable,Start date,Start time,Duration", importFormatToggl},
+		{"Project,Client,Description,Task,User,Start Date,Duration (h),Duration (decimal)", importFormatClockify},
+		{"\ufeffProject,Description,Start Date,Duration (h)", importFormatClockify},
+	} {
+		rows, err := ReadImportFile(strings.NewReader(test.header+"\n"), "")
+		if err != nil || len(rows) != 0 {
+			t.Errorf("%q: rows = %v, error = %v", test.header, rows, err)
+		}
+		header := make(map[string]int)
+		for i, name := range strings.Split(test.header, ",") {
+			header[strings.ToLower(strings.TrimPrefix(name, "\ufeff"))] = i
+		}
+		if got := detectImportFormat(header); got != test.want {
+			t.Errorf("%q: format = %s, want %s", test.header, got, test.want)
+		}
+	}
+}
+
+func TestReadImportFile(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		format string
+		file   string
+		want   []ImportRow
+	}{
+		{
+			name: "csv",
+			file: "work_package,subject,date,duration,comment\n" +
+				"#12,,2024-05-06,1h30m,Design\n" +
+				"12,,2024-05-06,2h,\n" +
+				",Checkout,2024-05-07,45m,Payments\n",
+			want: []ImportRow{
+				{Line: 2, WorkPackageId: 12, Date: "2024-05-06", Duration: NewDurationFromSeconds(5400), Comment: "Design"},
+				{Line: 3, WorkPackageId: 12, Date: "2024-05-06", Duration: NewDurationFromSeconds(7200)},
+				{Line: 4, Subject: "Checkout", Date: "2024-05-07", Duration: NewDurationFromSeconds(2700), Comment: "Payments"},
+			},
+		},
+		{
+			name: "alternative csv columns",
+			file: "Work Package,spent_on,hours\n7,2024-05-06,1.5\n",
+			want: []ImportRow{{Line: 2, WorkPackageId: 7, Date: "2024-05-06", Duration: NewDurationFromSeconds(5400)}},
+		},
+		{
+			name: "toggl",
+			file: "User,Email,Project,Task,Description,Start date,Start time,Duration\n" +
+				"Dana,dana@example.com,Website,Landing page,Hero #34,2024-05-06,09:00:00,01:15:00\n" +
+				"Dana,dana@example.com,Website,Checkout,Payments,05/07/2024,10:00:00,00:30:00\n",
+			want: []ImportRow{
+				{Line: 2, WorkPackageId: 34, Subject: "Landing page", Date: "2024-05-06", Duration: NewDurationFromSeconds(4500),
+					Comment: "Hero #34"},
+				{Line: 3, Subject: "Checkout", Date: "2024-05-07", Duration: NewDurationFromSeconds(1800), Comment: "Payments"},
+			},
+		},
+		{
+			name: "clockify",
+			file: "Project,Description,Task,Start Date,Duration (h),Duration (decimal)\n" +
+				"Website,Hero,Landing page #34,06.05.2024,01:15:00,1.25\n" +
+				"Website,Payments,,07.05.2024,,0.50\n",
+			want: []ImportRow{
+				{Line: 2, WorkPackageId: 34, Subject: "Landing page #34", Date: "2024-05-06", Duration: NewDurationFromSeconds(4500),
+					Comment: "Hero"},
+				{Line: 3, Subject: "Payments", Date: "2024-05-07", Duration: NewDurationFromSeconds(1800), Comment: "Payments"},
+			},
+		},
+		{
+			name:   "format given",
+			format: importFormatToggl,
+			file:   "Task,Start date,Duration\n#5,2024-05-06,00:45:00\n",
+			want:   []ImportRow{{Line: 2, WorkPackageId: 5, Subject: "#5", Date: "2024-05-06", Duration: NewDurationFromSeconds(2700)}},
+		},
+		{
+			name: "quoted fields",
+			file: "work_package,date,duration,comment\n" +
+				"3,2024-05-06,1h,\"Review, then \"\"merge\"\"\"\n" +
+				"3,2024-05-06,1h,\"Two\nlines\"\n",
+			want: []ImportRow{
+				{Line: 2, WorkPackageId: 3, Date: "2024-05-06", Duration: NewDurationFromSeconds(3600), Comment: `Review, then "merge"`},
+				{Line: 3, WorkPackageId: 3, Date: "2024-05-06", Duration: NewDurationFromSeconds(3600), Comment: "Two\nlines"},
+			},
+		},
+		{
+			name: "invalid rows",
+			file: "work_package,date,duration,comment\n" +
+				"abc,2024-05-06,1h,\n" +
+				"3,,1h,\n" +
+				"3,2024-13-45,1h,\n" +
+				"3,2024-05-06,,\n" +
+				"3,2024-05-06,0,\n" +
+				"3,2024-05-06\n",
+			want: []ImportRow{
+				{Line: 2, Err: fmt.Errorf(`invalid work package ID "abc"`)},
+				{Line: 3, WorkPackageId: 3, Err: fmt.Errorf("missing date")},
+				{Line: 4, WorkPackageId: 3, Err: fmt.Errorf(`invalid date "2024-13-45"`)},
+				{Line: 5, WorkPackageId: 3, Date: "2024-05-06", Err: fmt.Errorf("missing duration")},
+				{Line: 6, WorkPackageId: 3, Date: "2024-05-06", Err: fmt.Errorf(`duration "0" is zero`)},
+				{Line: 7, WorkPackageId: 3, Date: "2024-05-06", Err: fmt.Errorf("missing duration")},
+			},
+		},
+	} {
+		rows, err := ReadImportFile(strings.NewReader(test.file), test.format)
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got, want := importRowsString(rows), importRowsString(test.want); got != want {
+			t.Errorf("%s: rows =\n%s\nwant\n%s", test.name, got, want)
+		}
+	}
+}
+
+func TestReadImportFileErrors(t *testing.T) {
+	for _, test := range []struct {
+		name, format, file, want string
+	}{
+		{"empty", "", "", "empty file"},
+		{"unknown format", "harvest", "date,hours\n", "unknown format: harvest"},
+		{"malformed csv", "", "work_package,date,duration\n3,\"2024-05-06,1h\n", "error reading csv"},
+	} {
+		_, err := ReadImportFile(strings.NewReader(test.file), test.format)
+		if err == nil || !strings.HasPrefix(err.Error(), test.want) {
+			t.Errorf("%s: error = %v, want %s", test.name, err, test.want)
+		}
+	}
+}
+
+// importRowsString formats the fields of rows read from a file, one row per line.
+func importRowsString(rows []ImportRow) string {
+	var lines []string
+	for _, row := range rows {
+		lines = append(lines, fmt.Sprintf("%d #%d %q %s %s %q %v",
+			row.Line, row.WorkPackageId, row.Subject, row.Date, row.Duration.ToIso8601String(), row.Comment, row.Err))
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// iso8601Units are the designators of ISO 8601 durations in the order they must appear, with their length in
+// seconds. Years and months have no fixed length and are rejected.
+var iso8601Units = []struct {
+	designator byte
+	time       bool
+	seconds    func() float64
+}{
+	{'Y', false, nil},
+	{'M', false, nil},
+	{'W', false, func() float64 { return 7 * 24 * 3600 }},
+	{'D', false, func() float64 { return 24 * 3600 }},
+	{'H', true, func() float64 { return 3600 }},
+	{'M', true, func() float64 { return 60 }},
+	{'S', true, func() float64 { return 1 }},
+}
+
+// ParseIso8601 parses a duration in ISO 8601 format, as returned by the OpenProject API, e.g. `PT1H30M`, `PT1.5H`,
+// `P2DT3H`, `PT45M30S` or `PT0S`. Days are 24 hours, as OpenProject counts them, not `HoursPerDay`, and weeks are 7
+// days. Fractions are rounded to the second.
+func ParseIso8601(duration string) (*Duration, error) {
+	fail := func(format string, args ...interface{}) (*Duration, error) {
+		return nil, fmt.Errorf("invalid duration format: %s: %s", duration, fmt.Sprintf(format, args...))
+	}
+
+	input := strings.TrimSpace(duration)
+	sign := 1.0
+	if strings.HasPrefix(input, "-") {
+		sign, input = -1, input[1:]
+	} else if strings.HasPrefix(input, "+") {
+		input = input[1:]
+	}
+	if !strings.HasPrefix(input, "P") {
+		return fail("must start with P")
+	}
+
+	rest := input[1:]
+	if rest == "" {
+		return fail("no components")
+	}
+	seconds := 0.0
+	next := 0
+	inTime, fraction := false, false
+	for rest != "" {
+		if rest[0] == 'T' {
+			if inTime {
+				return fail("T is repeated")
+			}
+			inTime, rest = true, rest[1:]
+			if rest == "" {
+				return fail("no time components after T")
+			}
+			continue
+		}
+
+		n := scanDecimal(rest)
+		if n == 0 {
+			return fail("expected a number at %q", rest)
+		}
+		if n == len(rest) {
+			return fail("missing designator after %q", rest)
+		}
+		if fraction {
+			return fail("only the last component may have a fraction")
+		}
+		number, designator := rest[:n], rest[n]
+		fraction = strings.ContainsAny(number, ".,")
+
+		unit := -1
+		for i := next; i < len(iso8601Units); i++ {
+			if iso8601Units[i].designator == designator && iso8601Units[i].time == inTime {
+				unit = i
+				break
+			}
+		}
+		if unit < 0 {
+			return fail("unexpected %q, designators must be in the order Y, M, W, D, T, H, M, S", string(designator))
+		}
+		if iso8601Units[unit].seconds == nil {
+			return fail("years and months are not supported because their length varies")
+		}
+		next = unit + 1
+		seconds += parseDecimal(number) * iso8601Units[unit].seconds()
+		rest = rest[n+1:]
+	}
+	return durationFromSeconds(sign * seconds), nil
+}
+
+// ParseNullableIso8601 parses an optional ISO 8601 duration. OpenProject returns `null` for unset durations, such
+// as the estimated time of a work package, which are decoded as an empty string. It returns nil for those.
+func ParseNullableIso8601(duration string) (*Duration, error) {
+	if duration == "" || duration == "null" {
+		return nil, nil
+	}
+	return ParseIso8601(duration)
+}
+
+// scanDecimal returns the length of the non-negative decimal number, e.g. `1.5` or `1,5`, at the start of s.
+func scanDecimal(s string) int {
+	digits := func(from int) int {
+		i := from
+		for i < len(s) && s[i] >= '0' && s[i] <= '9' {
+			i++
+		}
+		return i
+	}
+	end := digits(0)
+	if end == 0 {
+		return 0
+	}
+	if end < len(s) && (s[end] == '.' || s[end] == ',') {
+		if fractionEnd := digits(end + 1); fractionEnd > end+1 {
+			return fractionEnd
+		}
+	}
+	return end
+}
+
+// ToIso8601String returns the duration as an ISO 8601 string in hours, minutes and seconds, e.g. `PT1H30M` or
+// `PT26H30M`. Days are never written, so that no length of a day has to be agreed on; a zero duration is `PT0S`.
+func (d *Duration) ToIso8601String() string {
+	if d.seconds == 0 {
+		return "PT0S"
+	}
+	sign, total := "", d.seconds
+	if total < 0 {
+		sign, total = "-", -total
+	}
+	hours, minutes, seconds := total/3600, total%3600/60, total%60
+
+	isoDuration := []string{sign + "PT"}
+	if hours > 0 {
+		isoDuration = append(isoDuration, fmt.Sprintf("%dH", hours))
+	}
+	if minutes > 0 {
+		isoDuration = append(isoDuration, fmt.Sprintf("%dM", minutes))
+	}
+	if seconds > 0 {
+		isoDuration = append(isoDuration, fmt.Sprintf("%dS", seconds))
+	}
+	return strings.Join(isoDuration, "")
+}
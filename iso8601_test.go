@@ -0,0 +1,185 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseIso8601(t *testing.T) {
+	tests := []struct {
+		input   string
+		seconds int64
+	}{
+		{"PT1H30M", 5400},
+		{"PT1.5H", 5400},
+		{"PT1,5H", 5400},
+		{"PT8H", 8 * 3600},
+		{"PT45M", 2700},
+		{"PT45M30S", 2730},
+		{"PT30S", 30},
+		{"PT0.5S", 1},
+		{"PT0S", 0},
+		{"PT0H", 0},
+		{"P0D", 0},
+		{"P2DT3H", (2*24 + 3) * 3600},
+		{"P1DT2H30M", 26*3600 + 1800},
+		{"P1D", 24 * 3600},
+		{"P1W", 7 * 24 * 3600},
+		{"P1.5D", 36 * 3600},
+		{"PT26H", 26 * 3600},
+		{"PT1H0.5M", 3630},
+		{"-PT1H", -3600},
+		{"+PT1H", 3600},
+		{"-P1DT1H", -25 * 3600},
+		{" PT2H ", 7200},
+	}
+	for _, test := range tests {
+		d, err := ParseIso8601(test.input)
+		if err != nil {
+			t.Errorf("ParseIso8601(%q): %v", test.input, err)
+			continue
+		}
+		if d.Seconds() != test.seconds {
+			t.Errorf("ParseIso8601(%q) = %ds, want %ds", test.input, d.Seconds(), test.seconds)
+		}
+	}
+}
+
+func TestParseIso8601HoursPerDay(t *testing.T) {
+	// The days of OpenProject are 24 hours, whatever the length of the working days typed.
+	withHoursPerDay(t, 8)
+	d, err := ParseIso8601("P2DT3H")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Seconds() != 51*3600 {
+		t.Errorf("ParseIso8601(P2DT3H) = %ds with 8 hours per day, want %ds", d.Seconds(), 51*3600)
+	}
+	typed, _ := Parse("2d 3h")
+	if typed.Seconds() != 19*3600 {
+		t.Errorf("Parse(2d 3h) = %ds with 8 hours per day, want %ds", typed.Seconds(), 19*3600)
+	}
+}
+
+func TestParseIso8601Invalid(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"", "must start with P"},
+		{"1H", "must start with P"},
+		{"P", "no components"},
+		{"PT", "no time components after T"},
+		{"P1DT", "no time components after T"},
+		{"P1M", "years and months are not supported"},
+		{"P1Y", "years and months are not supported"},
+		{"PT1H30", "missing designator"},
+		{"PTH", "expected a number"},
+		{"PT1.5H30M", "only the last component may have a fraction"},
+		{"PT30M1H", "designators must be in the order"},
+		{"PT1D", "designators must be in the order"},
+		{"P1H", "designators must be in the order"},
+		{"PT1HT2M", "T is repeated"},
+		{"PT1X", "designators must be in the order"},
+		{"null", "must start with P"},
+	}
+	for _, test := range tests {
+		d, err := ParseIso8601(test.input)
+		if err == nil {
+			t.Errorf("ParseIso8601(%q) = %ds, want an error", test.input, d.Seconds())
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("ParseIso8601(%q) error = %q, want it to contain %q", test.input, err, test.err)
+		}
+	}
+}
+
+func TestParseNullableIso8601(t *testing.T) {
+	for _, input := range []string{"", "null"} {
+		d, err := ParseNullableIso8601(input)
+		if err != nil || d != nil {
+			t.Errorf("ParseNullableIso8601(%q) = %v, %v, want nil, nil", input, d, err)
+		}
+	}
+	d, err := ParseNullableIso8601("PT2H")
+	if err != nil || d == nil || d.Seconds() != 7200 {
+		t.Errorf("ParseNullableIso8601(PT2H) = %v, %v, want 2h", d, err)
+	}
+	if _, err := ParseNullableIso8601("P1M"); err == nil {
+		t.Error("ParseNullableIso8601(P1M) succeeded, want an error")
+	}
+}
+
+func TestToIso8601String(t *testing.T) {
+	tests := []struct {
+		seconds int64
+		want    string
+	}{
+		{0, "PT0S"},
+		{5400, "PT1H30M"},
+		{2730, "PT45M30S"},
+		{30, "PT30S"},
+		{8 * 3600, "PT8H"},
+		{9 * 3600, "PT9H"},
+		{24 * 3600, "PT24H"},
+		{26*3600 + 1800, "PT26H30M"},
+		{(2*24 + 3) * 3600, "PT51H"},
+		{3600 + 1, "PT1H1S"},
+		{-3600, "-PT1H"},
+		{-(25 * 3600), "-PT25H"},
+	}
+	for _, test := range tests {
+		d := NewDurationFromSeconds(test.seconds)
+		if got := d.ToIso8601String(); got != test.want {
+			t.Errorf("ToIso8601String() of %ds = %q, want %q", test.seconds, got, test.want)
+		}
+	}
+}
+
+func TestIso8601RoundTrip(t *testing.T) {
+	for _, hoursPerDay := range []float64{24, 8, 7.5} {
+		withHoursPerDay(t, hoursPerDay)
+		for _, test := range []struct{ input, want string }{
+			{"PT0S", "PT0S"},
+			{"PT1H30M", "PT1H30M"},
+			{"PT45M30S", "PT45M30S"},
+			{"PT5H", "PT5H"},
+			{"PT1.5H", "PT1H30M"},
+			{"PT90M", "PT1H30M"},
+			{"P1D", "PT24H"},
+			{"P1DT2H30M", "PT26H30M"},
+			{"P3DT1H1M1S", "PT73H1M1S"},
+			{"P1W", "PT168H"},
+			{"-PT1H", "-PT1H"},
+		} {
+			d, err := ParseIso8601(test.input)
+			if err != nil {
+				t.Errorf("ParseIso8601(%q) with %v hours per day: %v", test.input, hoursPerDay, err)
+				continue
+			}
+			if got := d.ToIso8601String(); got != test.want {
+				t.Errorf("ToIso8601String() of ParseIso8601(%q) with %v hours per day = %q, want %q", test.input, hoursPerDay, got, test.want)
+			}
+		}
+
+		// A typed working day is sent as its hours, which OpenProject reads back as the same duration.
+		for _, test := range []struct {
+			typed string
+			hours float64
+		}{{"1d", hoursPerDay}, {"1d 1h", hoursPerDay + 1}, {"8h", 8}, {"9h", 9}} {
+			d, err := Parse(test.typed)
+			if err != nil {
+				t.Fatal(err)
+			}
+			sent := d.ToIso8601String()
+			if strings.Contains(sent, "D") {
+				t.Errorf("%s is sent as %s, in days", test.typed, sent)
+			}
+			read, err := ParseIso8601(sent)
+			if err != nil || float64(read.Seconds()) != test.hours*3600 {
+				t.Errorf("%s is sent as %s, read back as %v, want %vh", test.typed, sent, read, test.hours)
+			}
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"strings"
+	"unicode/utf8"
+)
+
+// Key actions.
+const (
+	actionNavigation    = "navigation"
+	actionCalendar      = "calendar"
+	actionLogTime       = "log_time"
+	actionSwitchProfile = "switch_profile"
+	actionPending       = "pending"
+	actionExportReport  = "export_report"
+	actionRefresh       = "refresh"
+	actionPalette       = "command_palette"
+	actionHelp          = "help"
+	actionUp            = "up"
+	actionDown          = "down"
+	actionTop           = "top"
+	actionBottom        = "bottom"
+	actionOpen          = "open"
+	actionBack          = "back"
+	actionChangeStatus  = "change_status"
+	actionNewEntry      = "new_entry"
+	actionCopyEntry     = "copy_entry"
+	actionCopyYesterday = "copy_yesterday"
+	actionCopyLastWeek  = "copy_last_week"
+	actionEditEntry     = "edit_entry"
+	actionDeleteEntry   = "delete_entry"
+	actionMark          = "mark"
+	actionBulk          = "bulk"
+	actionTemplates     = "templates"
+)
+
+// keyAction is an action that can be bound to keys.
+type keyAction struct {
+	name        string
+	group       string
+	description string
+	keys        []string
+}
+
+// keyActions are the actions, in the order they are listed in the help, with their default keys.
+var keyActions = []keyAction{
+	{actionNavigation, "Global", "Show the work packages", []string{"F1"}},
+	{actionCalendar, "Global", "Show the time entries of the last week", []string{"F2"}},
+	{actionLogTime, "Global", "Log time on any work package", []string{"F3"}},
+	{actionSwitchProfile, "Global", "Switch profile", []string{"F4"}},
+	{actionPending, "Global", "Show the pending changes", []string{"F5"}},
+	{actionExportReport, "Global", "Export the time entries of a period to CSV", []string{"F6"}},
+	{actionRefresh, "Global", "Sync and reload", []string{"Ctrl-R"}},
+	{actionPalette, "Global", "Command palette", []string{":", "Ctrl-P"}},
+	{actionHelp, "Global", "Show this help", []string{"?"}},
+	{actionUp, "Lists", "Move up", []string{"Up", "k"}},
+	{actionDown, "Lists", "Move down", []string{"Down", "j"}},
+	{actionTop, "Lists", "Go to the first item", []string{"Home", "g"}},
+	{actionBottom, "Lists", "Go to the last item", []string{"End", "G"}},
+	{actionOpen, "Work packages", "Go to the time entries", []string{"Enter"}},
+	{actionChangeStatus, "Work packages", "Change status", []string{"s"}},
+	{actionBack, "Time entries", "Return to the list", []string{"Esc"}},
+	{actionNewEntry, "Time entries", "New entry", []string{"n"}},
+	{actionEditEntry, "Time entries", "Edit entry", []string{"e"}},
+	{actionDeleteEntry, "Time entries", "Delete entry", []string{"d"}},
+	{actionCopyEntry, "Time entries", "Copy entry", []string{"c"}},
+	{actionCopyYesterday, "Time entries", "Copy yesterday's entries to today", []string{"y"}},
+	{actionCopyLastWeek, "Time entries", "Copy last week's entries to this week", []string{"w"}},
+	{actionTemplates, "Time entries", "Log templates", []string{"t"}},
+	{actionMark, "Time entries", "Mark entry", []string{"Space"}},
+	{actionBulk, "Time entries", "Bulk actions on the marked entries", []string{"b"}},
+}
+
+// Keymap maps actions to the keys that trigger them, as set in the `keymap` section of the config file. The actions
+// that are not in it keep their default keys; an empty list unbinds an action.
+//
+// Keys are written as a single character (case-sensitive, e.g. `G`), `Space`, `Alt-` followed by a character, or the
+// name of a special key: `Enter`, `Esc`, `Tab`, `Backspace`, `Delete`, `Up`, `Down`, `Left`, `Right`, `Home`, `End`,
+// `PgUp`, `PgDn`, `F1` to `F12`, `Ctrl-A` to `Ctrl-Z`… `Backspace` matches the key whichever code the terminal sends.
+type Keymap map[string][]string
+
+// keyBindings is a keymap with the defaults applied.
+type keyBindings struct {
+	// keys are the keys of each action.
+	keys map[string][]string
+	// actions are the actions of each key.
+	actions map[string]string
+}
+
+// Validate checks that the actions exist and that the keys are valid and bound only once.
+func (k Keymap) Validate() error {
+	_, err := k.bindings()
+	return err
+}
+
+// bindings returns the keys of all the actions: those of the keymap, or else the defaults.
+func (k Keymap) bindings() (*keyBindings, error) {
+	known := make(map[string]bool)
+	for _, action := range keyActions {
+		known[action.name] = true
+	}
+	for name := range k {
+		if !known[name] {
+			return nil, fmt.Errorf("unknown action %q", name)
+		}
+	}
+
+	b := &keyBindings{keys: make(map[string][]string), actions: make(map[string]string)}
+	for _, action := range keyActions {
+		keys, ok := k[action.name]
+		if !ok {
+			keys = action.keys
+		}
+		for _, key := range keys {
+			name, err := parseKey(key)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", action.name, err)
+			}
+			if other, ok := b.actions[name]; ok {
+				return nil, fmt.Errorf("key %s is bound to both %s and %s", name, other, action.name)
+			}
+			b.actions[name] = action.name
+			b.keys[action.name] = append(b.keys[action.name], name)
+		}
+	}
+	return b, nil
+}
+
+// specialKeys are the names of the keys that aren't characters, in lower case, e.g. `pgup` for `PgUp`.
+var specialKeys = func() map[string]string {
+	names := map[string]string{"space": "Space"}
+	for _, name := range tcell.KeyNames {
+		names[strings.ToLower(name)] = name
+	}
+	return names
+}()
+
+// keyAliases are the names of the keys that terminals send for the same key, e.g. `Backspace2` (DEL) for the
+// backspace key, and the name used for both.
+var keyAliases = map[string]string{"Backspace2": "Backspace"}
+
+// parseKey returns the name of a key as used by `keyName`.
+func parseKey(key string) (string, error) {
+	if utf8.RuneCountInString(key) == 1 {
+		if key == " " {
+			return "Space", nil
+		}
+		return key, nil
+	}
+	if strings.HasPrefix(strings.ToLower(key), "alt-") && utf8.RuneCountInString(key) == 5 {
+		return "Alt-" + key[4:], nil
+	}
+	if name, ok := specialKeys[strings.ToLower(key)]; ok {
+		if alias, ok := keyAliases[name]; ok {
+			return alias, nil
+		}
+		return name, nil
+	}
+	return "", fmt.Errorf("invalid key %q", key)
+}
+
+// keyName returns the name of the key of an event, e.g. `j`, `Space`, `Alt-x`, `F1` or `Ctrl-P`.
+func keyName(event *tcell.EventKey) string {
+	if event.Key() != tcell.KeyRune {
+		name := tcell.KeyNames[event.Key()]
+		if alias, ok := keyAliases[name]; ok {
+			return alias
+		}
+		return name
+	}
+	name := string(event.Rune())
+	if event.Rune() == ' ' {
+		name = "Space"
+	}
+	if event.Modifiers()&tcell.ModAlt != 0 {
+		name = "Alt-" + name
+	}
+	return name
+}
+
+// action returns the action bound to the key of an event, or an empty string.
+func (b *keyBindings) action(event *tcell.EventKey) string {
+	return b.actions[keyName(event)]
+}
+
+// describe returns the keys of an action for the help, e.g. `Up/k`.
+func (b *keyBindings) describe(action string) string {
+	return strings.Join(b.keys[action], "/")
+}
+
+// SetupKeymap sets the keys of the actions, on top of the defaults.
+func (tui *Tui) SetupKeymap(keymap Keymap) error {
+	keys, err := keymap.bindings()
+	if err != nil {
+		return err
+	}
+	tui.keys = keys
+	tui.TimeEntriesFrame.Clear()
+	tui.TimeEntriesFrame.AddText(tui.helpLine(), false, tview.AlignCenter, tview.Styles.PrimaryTextColor)
+	return nil
+}
+
+// helpLine returns the keys of the main actions on the time entries, shown below them.
+func (tui *Tui) helpLine() string {
+	var parts []string
+	for _, action := range []struct{ name, label string }{
+		{actionNewEntry, "New"},
+		{actionEditEntry, "Edit"},
+		{actionDeleteEntry, "Delete"},
+		{actionCopyEntry, "Copy"},
+		{actionMark, "Mark"},
+		{actionBulk, "Bulk"},
+		{actionBack, "Back"},
+		{actionHelp, "Help"},
+	} {
+		if keys := tui.keys.describe(action.name); keys != "" {
+			parts = append(parts, fmt.Sprintf("<%s> %s", styled(styleAccent, tview.Escape(keys)), styled(styleLabel, action.label)))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+// navigationKeys maps the navigation actions to the keys the views of tview understand.
+var navigationKeys = map[string]tcell.Key{
+	actionUp:     tcell.KeyUp,
+	actionDown:   tcell.KeyDown,
+	actionTop:    tcell.KeyHome,
+	actionBottom: tcell.KeyEnd,
+}
+
+// isTextInput reports whether a view takes typed text, in which case characters are not bound to actions.
+func isTextInput(p tview.Primitive) bool {
+	switch p.(type) {
+	case *tview.InputField, *tview.TextArea, *tview.DropDown:
+		return true
+	}
+	return false
+}
+
+// toggleHelp shows the keys of all the actions, as currently bound, or hides them if they are shown.
+func (tui *Tui) toggleHelp() {
+	if tui.Pages.HasPage("help") {
+		tui.closeHelp()
+		return
+	}
+	var builder strings.Builder
+	group := ""
+	for _, action := range keyActions {
+		if action.group != group {
+			if group != "" {
+				builder.WriteString("\n")
+			}
+			group = action.group
+			builder.WriteString(styled(styleAccent, group) + "\n")
+		}
+		keys := tui.keys.describe(action.name)
+		if keys == "" {
+			keys = "(unbound)"
+		}
+		builder.WriteString(fmt.Sprintf("  %s %s\n", styled(styleLabel, fmt.Sprintf("%-14s", tview.Escape(keys))), action.description))
+	}
+
+	text := tview.NewTextView().SetDynamicColors(true).SetText(builder.String())
+	text.SetBorder(true).SetTitle("Keys (ESC: close)").SetTitleAlign(tview.AlignCenter)
+	tui.helpReturnFocus = tui.App.GetFocus()
+	text.SetDoneFunc(func(key tcell.Key) {
+		tui.closeHelp()
+	})
+	tui.Pages.AddPage("help", tui.Modal(text, 70, len(keyActions)+10), true, true)
+}
+
+func (tui *Tui) closeHelp() {
+	tui.Pages.RemovePage("help")
+	tui.App.SetFocus(tui.helpReturnFocus)
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"strings"
+	"testing"
+)
+
+func TestParseKey(t *testing.T) {
+	for _, test := range []struct {
+		key, want string
+	}{
+		{"j", "j"},
+		{"G", "G"},
+		{":", ":"},
+		{"é", "é"},
+		{" ", "Space"},
+		{"Space", "Space"},
+		{"space", "Space"},
+		{"Alt-x", "Alt-x"},
+		{"alt-X", "Alt-X"},
+		{"Enter", "Enter"},
+		{"esc", "Esc"},
+		{"PGUP", "PgUp"},
+		{"F12", "F12"},
+		{"Ctrl-P", "Ctrl-P"},
+		{"ctrl-p", "Ctrl-P"},
+		{"Backspace", "Backspace"},
+		{"Backspace2", "Backspace"},
+		{"", `invalid key ""`},
+		{"jj", `invalid key "jj"`},
+		{"Alt-", `invalid key "Alt-"`},
+		{"Alt-xy", `invalid key "Alt-xy"`},
+		{"Hyper-x", `invalid key "Hyper-x"`},
+		{"F99", `invalid key "F99"`},
+	} {
+		got, err := parseKey(test.key)
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.want {
+			t.Errorf("parseKey(%q) = %s, want %s", test.key, got, test.want)
+		}
+	}
+}
+
+func TestKeymapBindings(t *testing.T) {
+	for _, test := range []struct {
+		name   string
+		keymap Keymap
+		// want are the keys of some actions, as described in the help.
+		want map[string]string
+		err  string
+	}{
+		{
+			name:   "defaults",
+			keymap: nil,
+			want:   map[string]string{actionUp: "Up/k", actionPalette: ":/Ctrl-P", actionMark: "Space"},
+		},
+		{
+			name:   "rebound",
+			keymap: Keymap{actionUp: {"Up", "i"}, actionMark: {"m", "Alt-m"}, actionDeleteEntry: {"Delete"}},
+			want:   map[string]string{actionUp: "Up/i", actionMark: "m/Alt-m", actionDeleteEntry: "Delete", actionDown: "Down/j"},
+		},
+		{
+			name:   "unbound",
+			keymap: Keymap{actionHelp: {}},
+			want:   map[string]string{actionHelp: ""},
+		},
+		{
+			name:   "key freed by another action",
+			keymap: Keymap{actionDeleteEntry: {"x"}, actionEditEntry: {"d"}},
+			want:   map[string]string{actionDeleteEntry: "x", actionEditEntry: "d"},
+		},
+		{
+			name:   "backspace",
+			keymap: Keymap{actionBack: {"Esc", "Backspace2"}},
+			want:   map[string]string{actionBack: "Esc/Backspace"},
+		},
+		{
+			name:   "unknown action",
+			keymap: Keymap{"launch_rockets": {"r"}},
+			err:    `unknown action "launch_rockets"`,
+		},
+		{
+			name:   "invalid key",
+			keymap: Keymap{actionUp: {"Up", "Hyper-k"}},
+			err:    `up: invalid key "Hyper-k"`,
+		},
+		{
+			name:   "key bound to a default of another action",
+			keymap: Keymap{actionUp: {"j"}},
+			err:    "key j is bound to both up and down",
+		},
+		{
+			name:   "duplicate keys",
+			keymap: Keymap{actionNewEntry: {"x"}, actionDeleteEntry: {"x"}},
+			err:    "key x is bound to both new_entry and delete_entry",
+		},
+		{
+			name:   "duplicate key in an action",
+			keymap: Keymap{actionMark: {"Space", " "}},
+			err:    "key Space is bound to both mark and mark",
+		},
+		{
+			name:   "both backspaces",
+			keymap: Keymap{actionBack: {"Backspace"}, actionDeleteEntry: {"Backspace2"}},
+			err:    "key Backspace is bound to both back and delete_entry",
+		},
+	} {
+		b, err := test.keymap.bindings()
+		if test.err != "" {
+			if err == nil || err.Error() != test.err {
+				t.Errorf("%s: error = %v, want %s", test.name, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		for action, want := range test.want {
+			if got := b.describe(action); got != want {
+				t.Errorf("%s: keys of %s = %q, want %q", test.name, action, got, want)
+			}
+		}
+	}
+}
+
+func TestKeyActionOfEvents(t *testing.T) {
+	b, err := Keymap{actionBack: {"Backspace"}, actionMark: {"Space"}, actionBulk: {"Alt-b"}}.bindings()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, test := range []struct {
+		event *tcell.EventKey
+		want  string
+	}{
+		// Terminals send either for the backspace key.
+		{tcell.NewEventKey(tcell.KeyBackspace, 0, tcell.ModNone), actionBack},
+		{tcell.NewEventKey(tcell.KeyBackspace2, 0, tcell.ModNone), actionBack},
+		{tcell.NewEventKey(tcell.KeyRune, ' ', tcell.ModNone), actionMark},
+		{tcell.NewEventKey(tcell.KeyRune, 'b', tcell.ModAlt), actionBulk},
+		{tcell.NewEventKey(tcell.KeyRune, 'b', tcell.ModNone), ""},
+		{tcell.NewEventKey(tcell.KeyUp, 0, tcell.ModNone), actionUp},
+		{tcell.NewEventKey(tcell.KeyCtrlP, 0, tcell.ModCtrl), actionPalette},
+		{tcell.NewEventKey(tcell.KeyEsc, 0, tcell.ModNone), ""},
+	} {
+		if got := b.action(test.event); got != test.want {
+			t.Errorf("action of %s = %q, want %q", strings.TrimSpace(test.event.Name()), got, test.want)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"strconv"
+	"strings"
+)
+
+// recentWorkPackagesDays is how far back time entries are looked at to find recently used work packages.
+const recentWorkPackagesDays = 30
+
+// recentWorkPackages returns the work packages the user logged time on recently, most recent first.
+func recentWorkPackages(client Backend, userId int) ([]WorkPackage, error) {
+	timeEntries, err := client.ListTimeEntriesBefore(userId, recentWorkPackagesDays)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[int]bool)
+	var recent []WorkPackage
+	elements := timeEntries.Embedded.Elements
+	for i := len(elements) - 1; i >= 0; i-- {
+		link := elements[i].Links.WorkPackage
+		id := idFromHref(link.Href)
+		if id == 0 || seen[id] {
+			continue
+		}
+		seen[id] = true
+		recent = append(recent, WorkPackage{Id: id, Subject: link.Title})
+	}
+	return recent, nil
+}
+
+// showLogTimeForm lets the user pick any work package, by ID, by subject or from the recently used ones, and log
+// time on it.
+func (tui *Tui) showLogTimeForm(client Backend, userId int, query string) {
+	returnFocus := tui.App.GetFocus()
+	closeForm := func() {
+		tui.Pages.RemovePage("logTimeForm")
+		tui.App.SetFocus(returnFocus)
+	}
+
+	results := tview.NewList().ShowSecondaryText(false)
+	results.SetBorder(true).SetTitle("Recent Work Packages")
+	setResults := func(title string, workPackages []WorkPackage) {
+		results.Clear()
+		results.SetTitle(title)
+		for _, wp := range workPackages {
+			wp := wp
+			text := fmt.Sprintf("%s: %s", styled(styleLabel, fmt.Sprintf("#%d", wp.Id)), wp.Subject)
+			if wp.Links.Project.Title != "" {
+				text = fmt.Sprintf("%s: %s", styled(styleLabel, fmt.Sprintf("#%d %s", wp.Id, wp.Links.Project.Title)), wp.Subject)
+			}
+			results.AddItem(text, "", 0, func() {
+				closeForm()
+				tui.showNewTimeEntryForm(client, userId, wp.Id, tui.WorkPackageList.GetCurrentItem(), nil)
+			})
+		}
+	}
+
+	// find shows the work package with an ID, or those whose subject matches, and reports whether it did.
+	find := func(query string) bool {
+		query = strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(query), "#"))
+		if query == "" {
+			return false
+		}
+		if id, err := strconv.Atoi(query); err == nil {
+			wp, err := client.GetWorkPackage(id)
+			if err != nil {
+				tui.NotifyError(err)
+				return false
+			}
+			setResults("Work Package", []WorkPackage{*wp})
+		} else {
+			collection, err := client.SearchWorkPackages(query)
+			if err != nil {
+				tui.NotifyError(err)
+				return false
+			}
+			setResults(fmt.Sprintf("Search Results (%d)", len(collection.Embedded.Elements)), collection.Embedded.Elements)
+		}
+		return true
+	}
+
+	search := tview.NewInputField().SetLabel("ID or subject: ").SetText(query)
+	search.SetDoneFunc(func(key tcell.Key) {
+		if key == tcell.KeyEnter && find(search.GetText()) {
+			tui.App.SetFocus(results)
+		}
+	})
+
+	if !find(query) {
+		recent, err := recentWorkPackages(client, userId)
+		if err != nil {
+			tui.NotifyError(err)
+			return
+		}
+		setResults("Recent Work Packages", recent)
+	}
+
+	flex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(search, 1, 0, true).
+		AddItem(results, 0, 1, false)
+	flex.SetBorder(true).SetTitle("Log Time on Any Work Package").SetTitleAlign(tview.AlignCenter)
+	flex.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyEscape:
+			closeForm()
+			return nil
+		case tcell.KeyTab:
+			if search.HasFocus() {
+				tui.App.SetFocus(results)
+			} else {
+				tui.App.SetFocus(search)
+			}
+			return nil
+		}
+		return event
+	})
+
+	tui.Pages.AddPage("logTimeForm", tui.Modal(flex, 70, 20), true, true)
+}
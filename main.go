@@ -1,48 +1,129 @@
 package main
 
 import (
-	"github.com/gdamore/tcell/v2"
+	"flag"
 	"log"
 )
 
+var (
+	profileName = flag.String("profile", "", "name of the profile to use from the config file")
+	configPath  = flag.String("config", "", "path of the config file (default: $LAZYOP_CONFIG, then the first one found)")
+	demo        = flag.Bool("demo", false, "run against a local demo server with sample data instead of OpenProject")
+)
+
 func main() {
-	config, err := ReadConfig()
+	flag.Parse()
+
+	if handled, err := runSetupCommand(*configPath, flag.Args()); handled {
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	var config *Config
+	var client *Client
+	if *demo {
+		config, client = startDemo()
+	} else {
+		var err error
+		config, err = ReadConfig(*configPath)
+		if err != nil {
+			log.Fatalf("error reading config: %v", err)
+		}
+		if err := config.SelectProfile(*profileName); err != nil {
+			log.Fatalf("error reading config: %v", err)
+		}
+
+		if config.HoursPerDay > 0 {
+			HoursPerDay = config.HoursPerDay
+		}
+
+		if handled, err := runAuthCommand(config, flag.Args()); handled {
+			if err != nil {
+				log.Fatal(err)
+			}
+			return
+		}
+
+		client, err = NewClientFromProfile(config.ProfileName, &config.Profile, true)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	user, err := resolveCurrentUser(client, &config.Profile)
 	if err != nil {
-		log.Fatalf("error reading config: %v", err)
+		log.Fatal(err)
+	}
+
+	if flag.NArg() > 0 {
+		if err := runCommand(client, config, flag.Args()); err != nil {
+			log.Fatal(err)
+		}
+		return
 	}
 
-	tui := NewTui()
-	client := NewClient(config.BaseURL, "apikey", config.APIKey)
+	if err := UseTheme(config.Theme, config.Themes); err != nil {
+		log.Fatalf("error reading config: theme: %v", err)
+	}
+	tui := NewTui(nil)
+	if err := tui.SetupKeymap(config.Keymap); err != nil {
+		log.Fatalf("error reading config: keymap: %v", err)
+	}
 
 	workPackages, err := client.ListWorkPackages(config.UserID)
 	if err != nil {
 		log.Fatalf("error listing work packages: %v", err)
 	}
 	tui.SetupWorkPackages(client, config.UserID, workPackages)
+	tui.SetupStatusBar(user, config.ProfileName, instanceHost(config.BaseURL))
+	tui.SetupTemplates(config.Templates)
+	tui.SetupRounding(config.Rounding)
 
-	tui.App.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		if event.Key() == tcell.KeyF1 {
-			tui.Pages.SwitchToPage("navigation")
-			tui.App.SetFocus(tui.WorkPackageList)
-			return nil
+	current := func() (Backend, int) {
+		return client, config.UserID
+	}
+	tui.SetupGlobalActions(current, config.ProfileNames, func(name string) {
+		// The previous profile is restored as it is, with the user found for it and its resolved API key, if the
+		// switch fails.
+		previousName, previousProfile := config.ProfileName, config.Profile
+		restore := func() {
+			config.ProfileName, config.Profile = previousName, previousProfile
 		}
-		if event.Key() == tcell.KeyF2 {
-			// The calendar view is updated every time it's accessed.
-			tui.CalendarFlex.Clear()
-
-			timeEntries, err := client.ListTimeEntriesBefore(config.UserID, 7)
-			if err != nil {
-				log.Fatalf("error listing time entries: %v", err)
-			}
-			tui.SetupCalendar(timeEntries)
-
-			tui.Pages.SwitchToPage("calendar")
-			tui.App.SetFocus(tui.CalendarFlex)
-			return nil
+		if err := config.SelectProfile(name); err != nil {
+			tui.ShowError(err)
+			return
+		}
+		// The terminal is used by the UI, so OAuth2 profiles must already be logged in.
+		newClient, err := NewClientFromProfile(config.ProfileName, &config.Profile, false)
+		if err != nil {
+			restore()
+			tui.ShowError(err)
+			return
 		}
-		return event
+		newUser, err := resolveCurrentUser(newClient, &config.Profile)
+		if err != nil {
+			restore()
+			tui.ShowError(err)
+			return
+		}
+		workPackages, err := newClient.ListWorkPackages(config.UserID)
+		if err != nil {
+			restore()
+			tui.ShowError(err)
+			return
+		}
+		client = newClient
+
+		tui.Pages.SwitchToPage("navigation")
+		tui.SetupWorkPackages(client, config.UserID, workPackages)
+		tui.SetupStatusBar(newUser, config.ProfileName, instanceHost(config.BaseURL))
+		tui.App.SetFocus(tui.WorkPackageList)
 	})
 
+	tui.startBackgroundSync(current)
+
 	if err := tui.Start(); err != nil {
 		panic(err)
 	}
@@ -0,0 +1,343 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// memoryPageSize is the number of elements in a page of a collection, as requested by `Client`.
+const memoryPageSize = 100
+
+// MemoryBackend is a `Backend` that keeps the work packages and time entries in memory, for tests and demos. It
+// behaves like OpenProject: it assigns the IDs, applies the same filters and sorting as the queries of `Client`, and
+// rejects invalid time entries with the same status codes. It is always online, so nothing is ever pending.
+type MemoryBackend struct {
+	mu sync.Mutex
+
+	user         User
+	workPackages []memoryWorkPackage
+	timeEntries  map[int]TimeEntry
+
+	lastWorkPackageId int
+	lastTimeEntryId   int
+
+	// now returns the current time, used for `updatedAt`.
+	now func() time.Time
+}
+
+// memoryStatuses are the statuses of a new OpenProject instance. Any work package can be set to any of them.
+var memoryStatuses = []Status{
+	{Id: 1, Name: "New"},
+	{Id: 2, Name: "In progress"},
+	{Id: 3, Name: "On hold"},
+	{Id: 4, Name: "Closed", IsClosed: true},
+	{Id: 5, Name: "Rejected", IsClosed: true},
+}
+
+// memoryWorkPackage is a work package with what the API only exposes through filters.
+type memoryWorkPackage struct {
+	WorkPackage
+	assigneeId int
+	closed     bool
+}
+
+// NewMemoryBackend returns an empty backend where user is the current user.
+func NewMemoryBackend(user User) *MemoryBackend {
+	return &MemoryBackend{user: user, timeEntries: make(map[int]TimeEntry), now: time.Now}
+}
+
+var _ Backend = (*MemoryBackend)(nil)
+
+// AddWorkPackage adds a work package assigned to a user (0 for nobody), and returns it with its ID. A closed work
+// package is not listed by `ListWorkPackages`. Without a status, it is "New", or "Closed" if closed.
+func (m *MemoryBackend) AddWorkPackage(wp WorkPackage, assigneeId int, closed bool) WorkPackage {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.lastWorkPackageId++
+	wp.Id = m.lastWorkPackageId
+	if wp.Type == "" {
+		wp.Type = "WorkPackage"
+	}
+	now := m.now().UTC().Format(time.RFC3339)
+	if wp.CreatedAt == "" {
+		wp.CreatedAt = now
+	}
+	if wp.UpdatedAt == "" {
+		wp.UpdatedAt = now
+	}
+	if wp.Links.Status.Href == "" {
+		status := memoryStatuses[0]
+		if closed {
+			status = memoryStatuses[3]
+		}
+		wp.Links.Status.Href, wp.Links.Status.Title = statusHref(status.Id), status.Name
+	}
+	m.workPackages = append(m.workPackages, memoryWorkPackage{WorkPackage: wp, assigneeId: assigneeId, closed: closed})
+	return m.withSpentTime(wp)
+}
+
+func (m *MemoryBackend) GetCurrentUser() (*User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user := m.user
+	return &user, nil
+}
+
+func (m *MemoryBackend) GetWorkPackage(workPackageId int) (*WorkPackage, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	wp := m.workPackage(workPackageId)
+	if wp == nil {
+		return nil, fmt.Errorf("error making request: %w", notFoundError())
+	}
+	result := m.withSpentTime(wp.WorkPackage)
+	return &result, nil
+}
+
+func (m *MemoryBackend) ListWorkPackages(userId int) (*WorkPackageCollection, error) {
+	return m.listWorkPackages(func(wp *memoryWorkPackage) bool {
+		return wp.assigneeId == userId && !wp.closed
+	}), nil
+}
+
+func (m *MemoryBackend) SearchWorkPackages(subject string) (*WorkPackageCollection, error) {
+	subject = strings.ToLower(subject)
+	return m.listWorkPackages(func(wp *memoryWorkPackage) bool {
+		return strings.Contains(strings.ToLower(wp.Subject), subject)
+	}), nil
+}
+
+// listWorkPackages returns the first page of the work packages matching a filter, most recently updated first.
+func (m *MemoryBackend) listWorkPackages(match func(wp *memoryWorkPackage) bool) *WorkPackageCollection {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var elements []WorkPackage
+	for i := range m.workPackages {
+		if match(&m.workPackages[i]) {
+			elements = append(elements, m.withSpentTime(m.workPackages[i].WorkPackage))
+		}
+	}
+	sort.SliceStable(elements, func(i, j int) bool { return elements[i].UpdatedAt > elements[j].UpdatedAt })
+	collection := &WorkPackageCollection{Total: len(elements)}
+	if len(elements) > memoryPageSize {
+		elements = elements[:memoryPageSize]
+	}
+	collection.Embedded.Elements = elements
+	return collection
+}
+
+func (m *MemoryBackend) ListStatuses(workPackageId int) ([]Status, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.workPackage(workPackageId) == nil {
+		return nil, fmt.Errorf("error making request: %w", notFoundError())
+	}
+	return append([]Status(nil), memoryStatuses...), nil
+}
+
+func (m *MemoryBackend) UpdateWorkPackageStatus(workPackageId, statusId int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	wp := m.workPackage(workPackageId)
+	if wp == nil {
+		return fmt.Errorf("error making request: %w", notFoundError())
+	}
+	for _, status := range memoryStatuses {
+		if status.Id == statusId {
+			wp.Links.Status.Href, wp.Links.Status.Title = statusHref(status.Id), status.Name
+			wp.closed = status.IsClosed
+			wp.UpdatedAt = m.now().UTC().Format(time.RFC3339)
+			return nil
+		}
+	}
+	return fmt.Errorf("error making request: %w", constraintViolation("Status is invalid."))
+}
+
+func (m *MemoryBackend) ListTimeEntries(workPackageId int) (*TimeEntryCollection, error) {
+	return m.listTimeEntries(func(te *TimeEntry) bool {
+		return idFromHref(te.Links.WorkPackage.Href) == workPackageId
+	}), nil
+}
+
+func (m *MemoryBackend) ListTimeEntriesBefore(userId int, days int) (*TimeEntryCollection, error) {
+	now := m.now()
+	return m.ListTimeEntriesBetween(userId, now.AddDate(0, 0, -days), now)
+}
+
+func (m *MemoryBackend) ListTimeEntriesBetween(userId int, start, end time.Time) (*TimeEntryCollection, error) {
+	from, to := start.Format("2006-01-02"), end.Format("2006-01-02")
+	return m.listTimeEntries(func(te *TimeEntry) bool {
+		return idFromHref(te.Links.User.Href) == userId && te.Date >= from && te.Date <= to
+	}), nil
+}
+
+// listTimeEntries returns the first page of the time entries matching a filter, by day.
+func (m *MemoryBackend) listTimeEntries(match func(te *TimeEntry) bool) *TimeEntryCollection {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	var elements []TimeEntry
+	for _, te := range m.timeEntries {
+		if match(&te) {
+			elements = append(elements, te)
+		}
+	}
+	sort.Slice(elements, func(i, j int) bool {
+		if elements[i].Date != elements[j].Date {
+			return elements[i].Date < elements[j].Date
+		}
+		return elements[i].Id < elements[j].Id
+	})
+	collection := &TimeEntryCollection{Total: len(elements)}
+	if len(elements) > memoryPageSize {
+		elements = elements[:memoryPageSize]
+	}
+	collection.Embedded.Elements = elements
+	return collection
+}
+
+func (m *MemoryBackend) CreateTimeEntry(request *TimeEntryRequest) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	te := request.timeEntry(m.lastTimeEntryId + 1)
+	te.Pending = false
+	if te.Links.User.Href == "" {
+		te.Links.User.Href = userHref(m.user.Id)
+	}
+	if te.Links.Activity.Href == "" {
+		te.Links.Activity.Href = activityHref(defaultActivityId)
+	}
+	if err := m.validate(&te); err != nil {
+		return fmt.Errorf("error making request: %w", err)
+	}
+	m.lastTimeEntryId++
+	te.UpdatedAt = m.now().UTC().Format(time.RFC3339)
+	m.timeEntries[te.Id] = te
+	return nil
+}
+
+func (m *MemoryBackend) UpdateTimeEntryDuration(timeEntryId int, duration string, comment string, spendOn string) error {
+	update := map[string]interface{}{"hours": duration, "comment": map[string]string{"raw": comment}, "spentOn": spendOn}
+	return m.UpdateTimeEntry(timeEntryId, update)
+}
+
+func (m *MemoryBackend) UpdateTimeEntry(timeEntryId int, update map[string]interface{}) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	te, ok := m.timeEntries[timeEntryId]
+	if !ok {
+		return fmt.Errorf("error making request: %w", notFoundError())
+	}
+	if err := mergeJSON(&te, update); err != nil {
+		return fmt.Errorf("error making request: %w", &StatusError{Code: http.StatusBadRequest, Body: halError("InvalidRequestBody", err.Error())})
+	}
+	// The ID and author can't be changed.
+	te.Id = timeEntryId
+	te.Links.User = m.timeEntries[timeEntryId].Links.User
+	if err := m.validate(&te); err != nil {
+		return fmt.Errorf("error making request: %w", err)
+	}
+	te.UpdatedAt = m.now().UTC().Format(time.RFC3339)
+	m.timeEntries[timeEntryId] = te
+	return nil
+}
+
+func (m *MemoryBackend) DeleteTimeEntry(id int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.timeEntries[id]; !ok {
+		return fmt.Errorf("error making request: %w", notFoundError())
+	}
+	delete(m.timeEntries, id)
+	return nil
+}
+
+func (m *MemoryBackend) SyncStatus() SyncStatus {
+	return SyncStatus{}
+}
+
+func (m *MemoryBackend) PendingOperations() []PendingOperation {
+	return nil
+}
+
+func (m *MemoryBackend) ReplayPending() (int, error) {
+	return 0, nil
+}
+
+func (m *MemoryBackend) ResolvePending(op PendingOperation, keepMine bool) error {
+	return fmt.Errorf("no pending operation %d", op.Id)
+}
+
+func (m *MemoryBackend) Sync(userId int) (bool, error) {
+	return false, nil
+}
+
+// validate checks a time entry like OpenProject does before saving it.
+func (m *MemoryBackend) validate(te *TimeEntry) error {
+	if m.workPackage(idFromHref(te.Links.WorkPackage.Href)) == nil {
+		return constraintViolation("Work package is invalid.")
+	}
+	if idFromHref(te.Links.User.Href) != m.user.Id {
+		return constraintViolation("User is invalid.")
+	}
+	if idFromHref(te.Links.Activity.Href) <= 0 {
+		return constraintViolation("Activity is invalid.")
+	}
+	if _, err := time.Parse("2006-01-02", te.Date); err != nil {
+		return constraintViolation("Date is invalid.")
+	}
+	hours, err := ParseIso8601(te.Hours)
+	if err != nil || hours.Seconds() < 0 {
+		return constraintViolation("Hours is invalid.")
+	}
+	return nil
+}
+
+// workPackage returns a work package by ID, or nil. The lock must be held.
+func (m *MemoryBackend) workPackage(id int) *memoryWorkPackage {
+	for i := range m.workPackages {
+		if m.workPackages[i].Id == id {
+			return &m.workPackages[i]
+		}
+	}
+	return nil
+}
+
+// withSpentTime returns a work package with its spent time computed from its time entries. The lock must be held.
+func (m *MemoryBackend) withSpentTime(wp WorkPackage) WorkPackage {
+	spent := NewDuration()
+	for _, te := range m.timeEntries {
+		if idFromHref(te.Links.WorkPackage.Href) != wp.Id {
+			continue
+		}
+		if hours, err := ParseIso8601(te.Hours); err == nil {
+			spent.Add(hours)
+		}
+	}
+	wp.SpentTime = spent.ToIso8601String()
+	return wp
+}
+
+// notFoundError is the error OpenProject returns for a resource that doesn't exist.
+func notFoundError() *StatusError {
+	return &StatusError{Code: http.StatusNotFound, Body: halError("NotFound", "The requested resource could not be found.")}
+}
+
+// constraintViolation is the error OpenProject returns for an invalid resource.
+func constraintViolation(message string) *StatusError {
+	return &StatusError{Code: http.StatusUnprocessableEntity, Body: halError("PropertyConstraintViolation", message)}
+}
+
+// halError returns the body of an OpenProject error response.
+func halError(identifier, message string) string {
+	body, _ := json.Marshal(map[string]string{
+		"_type":           "Error",
+		"errorIdentifier": "urn:openproject-org:api:v3:errors:" + identifier,
+		"message":         message,
+	})
+	return string(body)
+}
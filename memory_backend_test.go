@@ -0,0 +1,352 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestMemoryBackend returns a backend at a fixed time, with Dana as the current user.
+func newTestMemoryBackend() *MemoryBackend {
+	backend := NewMemoryBackend(User{Id: 1, Name: "Dana"})
+	backend.now = func() time.Time { return time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC) }
+	return backend
+}
+
+// silenceOutput discards what is printed on the standard output and error for the duration of a test.
+func silenceOutput(t *testing.T) {
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout, stderr := os.Stdout, os.Stderr
+	os.Stdout, os.Stderr = devNull, devNull
+	t.Cleanup(func() {
+		os.Stdout, os.Stderr = stdout, stderr
+		devNull.Close()
+	})
+}
+
+func TestMemoryBackendAssignsIds(t *testing.T) {
+	backend := newTestMemoryBackend()
+	first := backend.AddWorkPackage(WorkPackage{Subject: "Landing page"}, 1, false)
+	second := backend.AddWorkPackage(WorkPackage{Subject: "Checkout"}, 1, false)
+	if first.Id != 1 || second.Id != 2 {
+		t.Errorf("work package IDs = %d, %d, want 1, 2", first.Id, second.Id)
+	}
+
+	ids := []int{
+		logTime(t, backend, first.Id, 3600, "", "2024-05-06"),
+		logTime(t, backend, second.Id, 3600, "", "2024-05-06"),
+	}
+	// A rejected time entry doesn't use up an ID.
+	hours := NewDurationFromSeconds(3600)
+	if err := backend.CreateTimeEntry(NewTimeEntryRequest(1, 99, 3, &hours, "", "2024-05-06")); err == nil {
+		t.Fatal("CreateTimeEntry on a missing work package succeeded")
+	}
+	ids = append(ids, logTime(t, backend, first.Id, 3600, "", "2024-05-06"))
+	if ids[0] != 1 || ids[1] != 2 || ids[2] != 3 {
+		t.Errorf("time entry IDs = %v, want [1 2 3]", ids)
+	}
+
+	// The ID and the author can't be changed.
+	update := map[string]interface{}{"id": 42, "_links": map[string]interface{}{"user": map[string]string{"href": userHref(7)}}}
+	if err := backend.UpdateTimeEntry(1, update); err != nil {
+		t.Fatal(err)
+	}
+	timeEntries, _ := backend.ListTimeEntries(first.Id)
+	if got := timeEntries.Embedded.Elements[0]; got.Id != 1 || idFromHref(got.Links.User.Href) != 1 {
+		t.Errorf("time entry after changing its ID and user = %d by %s, want 1 by %s", got.Id, got.Links.User.Href, userHref(1))
+	}
+}
+
+func TestMemoryBackendListWorkPackages(t *testing.T) {
+	backend := newTestMemoryBackend()
+	backend.AddWorkPackage(WorkPackage{Subject: "Landing page", UpdatedAt: "2024-05-01T10:00:00Z"}, 1, false)
+	backend.AddWorkPackage(WorkPackage{Subject: "Checkout", UpdatedAt: "2024-05-03T10:00:00Z"}, 1, false)
+	backend.AddWorkPackage(WorkPackage{Subject: "Closed page", UpdatedAt: "2024-05-04T10:00:00Z"}, 1, true)
+	backend.AddWorkPackage(WorkPackage{Subject: "Someone else's page", UpdatedAt: "2024-05-05T10:00:00Z"}, 2, false)
+	backend.AddWorkPackage(WorkPackage{Subject: "Unassigned page", UpdatedAt: "2024-05-06T10:00:00Z"}, 0, false)
+
+	subjects := func(collection *WorkPackageCollection) []string {
+		var subjects []string
+		for _, wp := range collection.Embedded.Elements {
+			subjects = append(subjects, wp.Subject)
+		}
+		return subjects
+	}
+	tests := []struct {
+		name string
+		list func() (*WorkPackageCollection, error)
+		want []string
+	}{
+		{"assigned", func() (*WorkPackageCollection, error) { return backend.ListWorkPackages(1) }, []string{"Checkout", "Landing page"}},
+		{"assigned to someone else", func() (*WorkPackageCollection, error) { return backend.ListWorkPackages(2) }, []string{"Someone else's page"}},
+		{"assigned to nobody", func() (*WorkPackageCollection, error) { return backend.ListWorkPackages(3) }, nil},
+		{"search", func() (*WorkPackageCollection, error) { return backend.SearchWorkPackages("PAGE") }, []string{"Unassigned page", "Someone else's page", "Closed page", "Landing page"}},
+	}
+	for _, test := range tests {
+		collection, err := test.list()
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got := subjects(collection); strings.Join(got, ", ") != strings.Join(test.want, ", ") {
+			t.Errorf("%s = %q, want %q", test.name, got, test.want)
+		}
+		if collection.Total != len(test.want) {
+			t.Errorf("%s total = %d, want %d", test.name, collection.Total, len(test.want))
+		}
+	}
+}
+
+func TestMemoryBackendUpdateWorkPackageStatus(t *testing.T) {
+	backend := newTestMemoryBackend()
+	wp := backend.AddWorkPackage(WorkPackage{Subject: "Landing page"}, 1, false)
+	if wp.Links.Status.Title != "New" {
+		t.Errorf("status of a new work package = %q, want New", wp.Links.Status.Title)
+	}
+	statuses, err := backend.ListStatuses(wp.Id)
+	if err != nil || len(statuses) != len(memoryStatuses) {
+		t.Fatalf("ListStatuses = %v, %v, want the %d statuses", statuses, err, len(memoryStatuses))
+	}
+
+	for _, test := range []struct {
+		statusId int
+		title    string
+		listed   bool
+	}{
+		{2, "In progress", true},
+		{4, "Closed", false},
+		{1, "New", true},
+	} {
+		if err := backend.UpdateWorkPackageStatus(wp.Id, test.statusId); err != nil {
+			t.Fatal(err)
+		}
+		got, _ := backend.GetWorkPackage(wp.Id)
+		if got.Links.Status.Title != test.title || got.Links.Status.Href != statusHref(test.statusId) {
+			t.Errorf("status = %q (%s), want %q", got.Links.Status.Title, got.Links.Status.Href, test.title)
+		}
+		assigned, _ := backend.ListWorkPackages(1)
+		if listed := len(assigned.Embedded.Elements) == 1; listed != test.listed {
+			t.Errorf("%s work package listed = %v, want %v", test.title, listed, test.listed)
+		}
+	}
+}
+
+func TestMemoryBackendListTimeEntries(t *testing.T) {
+	backend := newTestMemoryBackend()
+	landing := backend.AddWorkPackage(WorkPackage{Subject: "Landing page"}, 1, false)
+	checkout := backend.AddWorkPackage(WorkPackage{Subject: "Checkout"}, 1, false)
+	for _, date := range []string{"2024-05-09", "2024-05-01", "2024-05-03", "2024-05-10", "2024-04-30"} {
+		logTime(t, backend, landing.Id, 3600, date, date)
+	}
+	logTime(t, backend, checkout.Id, 1800, "2024-05-03", "2024-05-03")
+
+	dates := func(collection *TimeEntryCollection) string {
+		var dates []string
+		for _, te := range collection.Embedded.Elements {
+			dates = append(dates, te.Date)
+		}
+		return strings.Join(dates, " ")
+	}
+	day := func(date string) time.Time {
+		d, _ := time.Parse("2006-01-02", date)
+		return d
+	}
+	tests := []struct {
+		name string
+		list func() (*TimeEntryCollection, error)
+		want string
+	}{
+		{"work package", func() (*TimeEntryCollection, error) { return backend.ListTimeEntries(landing.Id) }, "2024-04-30 2024-05-01 2024-05-03 2024-05-09 2024-05-10"},
+		{"other work package", func() (*TimeEntryCollection, error) { return backend.ListTimeEntries(checkout.Id) }, "2024-05-03"},
+		{"between, bounds included", func() (*TimeEntryCollection, error) {
+			return backend.ListTimeEntriesBetween(1, day("2024-05-01"), day("2024-05-09"))
+		}, "2024-05-01 2024-05-03 2024-05-03 2024-05-09"},
+		{"between, other user", func() (*TimeEntryCollection, error) {
+			return backend.ListTimeEntriesBetween(2, day("2024-04-01"), day("2024-05-31"))
+		}, ""},
+		{"last 7 days", func() (*TimeEntryCollection, error) { return backend.ListTimeEntriesBefore(1, 7) }, "2024-05-03 2024-05-03 2024-05-09 2024-05-10"},
+	}
+	for _, test := range tests {
+		collection, err := test.list()
+		if err != nil {
+			t.Errorf("%s: %v", test.name, err)
+			continue
+		}
+		if got := dates(collection); got != test.want {
+			t.Errorf("%s = %q, want %q", test.name, got, test.want)
+		}
+	}
+
+	details, err := backend.GetWorkPackage(landing.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if details.SpentTime != "PT5H" {
+		t.Errorf("spent time = %s, want PT5H", details.SpentTime)
+	}
+}
+
+func TestMemoryBackendErrors(t *testing.T) {
+	backend := newTestMemoryBackend()
+	wp := backend.AddWorkPackage(WorkPackage{Subject: "Landing page"}, 1, false)
+	id := logTime(t, backend, wp.Id, 3600, "", "2024-05-06")
+	hours := NewDurationFromSeconds(3600)
+	negative := NewDurationFromSeconds(-3600)
+
+	tests := []struct {
+		name       string
+		call       func() error
+		code       int
+		identifier string
+		message    string
+	}{
+		{"get missing work package", func() error { _, err := backend.GetWorkPackage(99); return err }, http.StatusNotFound, "NotFound", ""},
+		{"update missing time entry", func() error { return backend.UpdateTimeEntry(99, map[string]interface{}{"hours": "PT1H"}) }, http.StatusNotFound, "NotFound", ""},
+		{"delete missing time entry", func() error { return backend.DeleteTimeEntry(99) }, http.StatusNotFound, "NotFound", ""},
+		{"create on missing work package", func() error {
+			return backend.CreateTimeEntry(NewTimeEntryRequest(1, 99, 3, &hours, "", "2024-05-06"))
+		}, http.StatusUnprocessableEntity, "PropertyConstraintViolation", "Work package is invalid."},
+		{"create for another user", func() error {
+			return backend.CreateTimeEntry(NewTimeEntryRequest(2, wp.Id, 3, &hours, "", "2024-05-06"))
+		}, http.StatusUnprocessableEntity, "PropertyConstraintViolation", "User is invalid."},
+		{"create without activity", func() error {
+			return backend.CreateTimeEntry(NewTimeEntryRequest(1, wp.Id, 0, &hours, "", "2024-05-06"))
+		}, http.StatusUnprocessableEntity, "PropertyConstraintViolation", "Activity is invalid."},
+		{"create with invalid date", func() error {
+			return backend.CreateTimeEntry(NewTimeEntryRequest(1, wp.Id, 3, &hours, "", "06/05/2024"))
+		}, http.StatusUnprocessableEntity, "PropertyConstraintViolation", "Date is invalid."},
+		{"create with negative hours", func() error {
+			return backend.CreateTimeEntry(NewTimeEntryRequest(1, wp.Id, 3, &negative, "", "2024-05-06"))
+		}, http.StatusUnprocessableEntity, "PropertyConstraintViolation", "Hours is invalid."},
+		{"update with invalid hours", func() error { return backend.UpdateTimeEntry(id, map[string]interface{}{"hours": "P1M"}) }, http.StatusUnprocessableEntity, "PropertyConstraintViolation", "Hours is invalid."},
+		{"update with invalid body", func() error { return backend.UpdateTimeEntry(id, map[string]interface{}{"hours": 1}) }, http.StatusBadRequest, "InvalidRequestBody", ""},
+		{"statuses of missing work package", func() error { _, err := backend.ListStatuses(99); return err }, http.StatusNotFound, "NotFound", ""},
+		{"set status of missing work package", func() error { return backend.UpdateWorkPackageStatus(99, 2) }, http.StatusNotFound, "NotFound", ""},
+		{"set missing status", func() error { return backend.UpdateWorkPackageStatus(wp.Id, 99) }, http.StatusUnprocessableEntity, "PropertyConstraintViolation", "Status is invalid."},
+	}
+	for _, test := range tests {
+		err := test.call()
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) {
+			t.Errorf("%s: error = %v, want a status error", test.name, err)
+			continue
+		}
+		if statusErr.Code != test.code || !strings.Contains(statusErr.Body, "urn:openproject-org:api:v3:errors:"+test.identifier) || !strings.Contains(statusErr.Body, test.message) {
+			t.Errorf("%s: error = %v, want %d %s %q", test.name, statusErr, test.code, test.identifier, test.message)
+		}
+	}
+
+	// Nothing was changed by the rejected requests.
+	timeEntries, _ := backend.ListTimeEntries(wp.Id)
+	if len(timeEntries.Embedded.Elements) != 1 || timeEntries.Embedded.Elements[0].Hours != "PT1H" {
+		t.Errorf("time entries = %+v, want only the 1h logged", timeEntries.Embedded.Elements)
+	}
+}
+
+func TestTimeImportCommand(t *testing.T) {
+	silenceOutput(t)
+	backend := newTestMemoryBackend()
+	landing := backend.AddWorkPackage(WorkPackage{Subject: "Landing page"}, 1, false)
+	checkout := backend.AddWorkPackage(WorkPackage{Subject: "Checkout"}, 1, false)
+
+	file := filepath.Join(t.TempDir(), "import.csv")
+	csv := "work_package,subject,date,duration,comment\n" +
+		"#1,,2024-05-06,1h30m,Design\n" +
+		",Checkout,2024-05-07,2h,Payments\n"
+	if err := os.WriteFile(file, []byte(csv), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	config := &Config{Profile: Profile{UserID: 1}}
+	if err := runCommand(backend, config, []string{"time", "import", "-yes", file}); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []struct {
+		workPackageId int
+		hours, date   string
+	}{{landing.Id, "PT1H30M", "2024-05-06"}, {checkout.Id, "PT2H", "2024-05-07"}} {
+		timeEntries, _ := backend.ListTimeEntries(want.workPackageId)
+		if got := timeEntries.Embedded.Elements; len(got) != 1 || got[0].Hours != want.hours || got[0].Date != want.date {
+			t.Errorf("time entries of #%d = %+v, want %s on %s", want.workPackageId, got, want.hours, want.date)
+		}
+	}
+
+	// The time entries already logged are skipped, and those that can't be created are reported.
+	csv += "#99,,2024-05-08,1h,Missing\n"
+	if err := os.WriteFile(file, []byte(csv), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	err := runCommand(backend, config, []string{"time", "import", "-yes", file})
+	if err == nil || err.Error() != "1 of 1 time entries could not be created" {
+		t.Errorf("import with a missing work package: error = %v", err)
+	}
+	all, _ := backend.ListTimeEntriesBetween(1, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 31, 0, 0, 0, 0, time.UTC))
+	if len(all.Embedded.Elements) != 2 {
+		t.Errorf("%d time entries after importing again, want 2", len(all.Embedded.Elements))
+	}
+}
+
+// syncingBackend counts the replays and syncs run before the commands.
+type syncingBackend struct {
+	*MemoryBackend
+	syncs int
+}
+
+func (b *syncingBackend) ReplayPending() (int, error) {
+	b.syncs++
+	return b.MemoryBackend.ReplayPending()
+}
+
+func (b *syncingBackend) Sync(userId int) (bool, error) {
+	b.syncs++
+	return b.MemoryBackend.Sync(userId)
+}
+
+func TestUnknownCommandDoesntSync(t *testing.T) {
+	silenceOutput(t)
+	backend := &syncingBackend{MemoryBackend: newTestMemoryBackend()}
+	config := &Config{Profile: Profile{UserID: 1}, Rounding: Rounding{Increment: 15}}
+	for _, args := range [][]string{{"time"}, {"time", "export"}, {"report"}} {
+		err := runCommand(backend, config, args)
+		if err == nil || !strings.HasPrefix(err.Error(), "unknown command") {
+			t.Errorf("%q: error = %v, want unknown command", args, err)
+		}
+	}
+	if backend.syncs != 0 {
+		t.Errorf("%d replays and syncs before unknown commands, want 0", backend.syncs)
+	}
+
+	if err := runCommand(backend, config, []string{"time", "normalise", "-yes"}); err != nil {
+		t.Fatal(err)
+	}
+	if backend.syncs != 2 {
+		t.Errorf("%d replays and syncs before a time command, want 2", backend.syncs)
+	}
+}
+
+func TestTimeNormaliseCommand(t *testing.T) {
+	silenceOutput(t)
+	backend := newTestMemoryBackend()
+	wp := backend.AddWorkPackage(WorkPackage{Subject: "Landing page"}, 1, false)
+	rounded := logTime(t, backend, wp.Id, 70*60, "", "2024-05-06")
+	kept := logTime(t, backend, wp.Id, 45*60, "", "2024-05-06")
+	outside := logTime(t, backend, wp.Id, 10*60, "", "2024-05-08")
+
+	config := &Config{Profile: Profile{UserID: 1}, Rounding: Rounding{Increment: 15}}
+	if err := runCommand(backend, config, []string{"time", "normalise", "-from", "2024-05-06", "-to", "2024-05-07", "-yes"}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[int]string{rounded: "PT1H15M", kept: "PT45M", outside: "PT10M"}
+	timeEntries, _ := backend.ListTimeEntries(wp.Id)
+	for _, te := range timeEntries.Embedded.Elements {
+		if te.Hours != want[te.Id] {
+			t.Errorf("time entry %d = %s after normalising, want %s", te.Id, te.Hours, want[te.Id])
+		}
+	}
+}
@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"time"
+)
+
+const (
+	// mirrorDays is how far back the time entries of the user are mirrored in the local store.
+	mirrorDays = 90
+
+	// syncInterval is how often the mirror is synced in the background.
+	syncInterval = 2 * time.Minute
+
+	// syncSkew is subtracted from the time of the last sync when fetching the changes since then, in case the clocks
+	// of the server and the client differ.
+	syncSkew = 5 * time.Minute
+)
+
+var (
+	// filterTimeEntriesMirror is a filter to get the time entries of a user spent since a date and, optionally,
+	// updated since a time (given as a complete filter, see `filterUpdatedSince`).
+	filterTimeEntriesMirror = "[{\"user\":{\"operator\":\"=\",\"values\":[\"%d\"]}},{\"spent_on\":{\"operator\":\"<>d\",\"values\":[\"%s\",\"\"]}}%s]"
+
+	// filterWorkPackagesMirror is a filter to get the open work packages assigned to a user and, optionally, updated
+	// since a time.
+	filterWorkPackagesMirror = "[{\"assigned_to\":{\"operator\":\"=\",\"values\":[\"%d\"]}},{\"status\":{\"operator\":\"o\",\"values\":[]}}%s]"
+
+	// filterUpdatedSince is appended to the filters above to only get what changed since a time.
+	filterUpdatedSince = ",{\"updated_at\":{\"operator\":\"<>d\",\"values\":[\"%s\",\"\"]}}"
+)
+
+// mirrorState records what the local store mirrors and when it was last synced.
+type mirrorState struct {
+	UserId int `json:"user_id"`
+	// From is the first day of the mirrored time entries.
+	From     string    `json:"from"`
+	SyncedAt time.Time `json:"synced_at"`
+}
+
+// mirror returns the state of the mirror, or nil if it was never synced.
+func (s *Store) mirror() *mirrorState {
+	defer s.lock()()
+	if s.data.Mirror == nil {
+		return nil
+	}
+	state := *s.data.Mirror
+	return &state
+}
+
+// mirrors reports whether the time entries of a user since a day are mirrored.
+func (s *Store) mirrors(userId int, from string) bool {
+	state := s.mirror()
+	return state != nil && state.UserId == userId && state.From <= from
+}
+
+func (s *Store) setMirror(state mirrorState) {
+	defer s.lock()()
+	s.data.Mirror = &state
+	s.save()
+}
+
+// isAssigned reports whether a work package is one of the mirrored assigned work packages.
+func (s *Store) isAssigned(workPackageId int) bool {
+	defer s.lock()()
+	for _, wp := range s.data.AssignedWorkPackages {
+		if wp.Id == workPackageId {
+			return true
+		}
+	}
+	return false
+}
+
+// upsertTimeEntries adds or replaces time entries.
+func (s *Store) upsertTimeEntries(timeEntries []TimeEntry) {
+	defer s.lock()()
+	for _, te := range timeEntries {
+		s.data.TimeEntries[te.Id] = te
+	}
+	s.save()
+}
+
+// removeTimeEntries forgets deleted time entries.
+func (s *Store) removeTimeEntries(ids []int) {
+	defer s.lock()()
+	for _, id := range ids {
+		delete(s.data.TimeEntries, id)
+	}
+	s.save()
+}
+
+// storedWorkPackageIds returns the work package of a time entry of the local store, if it is there.
+func (c *Client) storedWorkPackageIds(timeEntryId int) []int {
+	var ids []int
+	for _, te := range c.store.timeEntries(func(te *TimeEntry) bool { return te.Id == timeEntryId }) {
+		ids = append(ids, idFromHref(te.Links.WorkPackage.Href))
+	}
+	return ids
+}
+
+// refreshSpentTime fetches again the stored work packages whose time entries were changed. Their spent time changed
+// but not their `updatedAt`, so `Sync` wouldn't fetch them, and `GetWorkPackage` would return them as they were.
+func (c *Client) refreshSpentTime(workPackageIds ...int) {
+	refreshed := make(map[int]bool)
+	for _, id := range workPackageIds {
+		if refreshed[id] || c.store.workPackage(id) == nil {
+			continue
+		}
+		refreshed[id] = true
+		if wp, err := c.fetchWorkPackage(id); err == nil {
+			c.store.saveWorkPackages([]WorkPackage{*wp})
+		}
+	}
+}
+
+// Sync brings the mirror of the time entries and assigned work packages of a user up to date. The first sync fetches
+// everything; the following ones only fetch what was updated since the previous one, plus the IDs of everything to
+// detect deletions. It reports whether anything changed.
+func (c *Client) Sync(userId int) (bool, error) {
+	if c.store == nil {
+		return false, nil
+	}
+	started := time.Now()
+	state := c.store.mirror()
+	full := state == nil || state.UserId != userId
+	if full {
+		state = &mirrorState{UserId: userId, From: time.Now().AddDate(0, 0, -mirrorDays).Format("2006-01-02")}
+	}
+	updatedSince := ""
+	if !full {
+		updatedSince = fmt.Sprintf(filterUpdatedSince, state.SyncedAt.Add(-syncSkew).UTC().Format(time.RFC3339))
+	}
+
+	changedTimeEntries, err := c.syncTimeEntries(state, updatedSince)
+	if err != nil {
+		return false, err
+	}
+	changedWorkPackages, err := c.syncWorkPackages(userId, updatedSince)
+	if err != nil {
+		return false, err
+	}
+
+	state.SyncedAt = started
+	c.store.setMirror(*state)
+	return full || changedTimeEntries || changedWorkPackages, nil
+}
+
+// syncTimeEntries fetches the time entries updated since the last sync, or all of them if updatedSince is empty, and
+// the IDs of all of them to forget the deleted ones.
+func (c *Client) syncTimeEntries(state *mirrorState, updatedSince string) (bool, error) {
+	mirrored := func(te *TimeEntry) bool {
+		return idFromHref(te.Links.User.Href) == state.UserId && te.Date >= state.From
+	}
+	updated, err := c.fetchAllTimeEntries(fmt.Sprintf(filterTimeEntriesMirror, state.UserId, state.From, updatedSince), "")
+	if err != nil {
+		return false, err
+	}
+	if updatedSince == "" {
+		c.store.saveTimeEntries(updated, mirrored)
+		return true, nil
+	}
+	c.store.upsertTimeEntries(updated)
+
+	current, err := c.fetchAllTimeEntries(fmt.Sprintf(filterTimeEntriesMirror, state.UserId, state.From, ""), "total,elements/id")
+	if err != nil {
+		return false, err
+	}
+	exists := make(map[int]bool)
+	for _, te := range current {
+		exists[te.Id] = true
+	}
+	var deleted []int
+	for _, te := range c.store.timeEntries(mirrored) {
+		if !exists[te.Id] {
+			deleted = append(deleted, te.Id)
+		}
+	}
+	if len(deleted) > 0 {
+		c.store.removeTimeEntries(deleted)
+	}
+	return len(updated) > 0 || len(deleted) > 0, nil
+}
+
+// syncWorkPackages fetches the assigned work packages updated since the last sync, or all of them if updatedSince is
+// empty, and the IDs of all of them to forget those no longer assigned or open.
+func (c *Client) syncWorkPackages(userId int, updatedSince string) (bool, error) {
+	updated, err := c.fetchAllWorkPackages(fmt.Sprintf(filterWorkPackagesMirror, userId, updatedSince), "*,elements/*,self/status")
+	if err != nil {
+		return false, err
+	}
+	c.store.saveWorkPackages(updated)
+	assigned := updated
+	if updatedSince != "" {
+		ids, err := c.fetchAllWorkPackages(fmt.Sprintf(filterWorkPackagesMirror, userId, ""), "total,elements/id")
+		if err != nil {
+			return false, err
+		}
+		assigned = nil
+		for _, wp := range ids {
+			stored := c.store.workPackage(wp.Id)
+			if stored == nil {
+				if stored, err = c.fetchWorkPackage(wp.Id); err != nil {
+					return false, err
+				}
+			}
+			assigned = append(assigned, *stored)
+		}
+		sort.SliceStable(assigned, func(i, j int) bool { return assigned[i].UpdatedAt > assigned[j].UpdatedAt })
+	}
+
+	previous, fetched := c.store.assignedWorkPackages()
+	changed := !fetched || len(updated) > 0 || len(previous.Embedded.Elements) != len(assigned)
+	c.store.saveAssignedWorkPackages(assigned)
+	return changed, nil
+}
+
+// fetchAllTimeEntries returns the time entries matching filters from all pages, with the given properties or all of
+// them if properties is empty.
+func (c *Client) fetchAllTimeEntries(filters, properties string) ([]TimeEntry, error) {
+	var timeEntries []TimeEntry
+	for page := 1; ; page++ {
+		collection, err := c.fetchTimeEntriesPage(filters, page, properties)
+		if err != nil {
+			return nil, err
+		}
+		timeEntries = append(timeEntries, collection.Embedded.Elements...)
+		if len(collection.Embedded.Elements) == 0 || len(timeEntries) >= collection.Total {
+			return timeEntries, nil
+		}
+	}
+}
+
+// fetchAllWorkPackages returns the work packages matching filters from all pages.
+func (c *Client) fetchAllWorkPackages(filters, properties string) ([]WorkPackage, error) {
+	var workPackages []WorkPackage
+	for page := 1; ; page++ {
+		collection, err := c.listWorkPackagesPage(filters, page, properties)
+		if err != nil {
+			return nil, err
+		}
+		workPackages = append(workPackages, collection.Embedded.Elements...)
+		if len(collection.Embedded.Elements) == 0 || len(workPackages) >= collection.Total {
+			return workPackages, nil
+		}
+	}
+}
+
+// startBackgroundSync replays the changes made offline once the server is reachable again and syncs the mirror, and
+// refreshes the UI when they change what it shows. current returns the client and user of the active profile; it is
+// called from the UI goroutine.
+func (tui *Tui) startBackgroundSync(current func() (Backend, int)) {
+	go func() {
+		var synced Backend
+		var lastSync time.Time
+		for ; ; time.Sleep(replayInterval) {
+			var client Backend
+			var userId int
+			tui.App.QueueUpdate(func() { client, userId = current() })
+
+			changed := false
+			if client.SyncStatus().Pending > 0 {
+				replayed, _ := client.ReplayPending()
+				changed = replayed > 0
+			}
+			if client != synced || time.Since(lastSync) >= syncInterval {
+				if syncChanged, err := client.Sync(userId); err == nil {
+					synced, lastSync = client, time.Now()
+					changed = changed || syncChanged
+				}
+			}
+
+			tui.App.QueueUpdateDraw(func() {
+				if active, _ := current(); active != client {
+					// The profile was switched in the meantime.
+					return
+				}
+				tui.SetupSyncStatus(client.SyncStatus())
+				if changed {
+					tui.refreshWorkPackages(client, userId)
+				}
+			})
+		}
+	}()
+}
+
+// refresh replays the pending changes and syncs the mirror right away, rather than waiting for the background sync,
+// then reloads what is shown.
+func (tui *Tui) refresh(client Backend, userId int) {
+	if replayed, err := client.ReplayPending(); err != nil {
+		tui.NotifyError(fmt.Errorf("replayed %d changes, the server is still unreachable: %v", replayed, err))
+	} else if _, err := client.Sync(userId); err != nil {
+		tui.NotifyError(fmt.Errorf("error syncing: %v", err))
+	} else {
+		tui.Notify("Up to date.")
+	}
+	tui.SetupSyncStatus(client.SyncStatus())
+	if name, _ := tui.Pages.GetFrontPage(); name == "calendar" {
+		tui.showCalendar(client, userId)
+	} else {
+		tui.refreshWorkPackages(client, userId)
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"lazyop/openprojecttest"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// recordingTransport records the URLs of the requests it sends.
+type recordingTransport struct {
+	mu   sync.Mutex
+	urls []*url.URL
+}
+
+func (t *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.urls = append(t.urls, req.URL)
+	t.mu.Unlock()
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+// newMirrorServer returns a server with a user, a work package assigned to them, and two time entries logged today.
+func newMirrorServer(t *testing.T) (*openprojecttest.Server, openprojecttest.User, openprojecttest.WorkPackage, []openprojecttest.TimeEntry) {
+	server := openprojecttest.NewServer()
+	t.Cleanup(server.Close)
+	user := server.AddUser(openprojecttest.User{Login: "dana", Name: "Dana"})
+	project := server.AddProject(openprojecttest.Project{Name: "Website"})
+	wp := server.AddWorkPackage(openprojecttest.WorkPackage{Subject: "Landing page", ProjectId: project.Id, AssigneeId: user.Id})
+	today := time.Now().Format("2006-01-02")
+	var timeEntries []openprojecttest.TimeEntry
+	for _, hours := range []string{"PT1H", "PT2H"} {
+		timeEntries = append(timeEntries, server.AddTimeEntry(openprojecttest.TimeEntry{WorkPackageId: wp.Id, Hours: hours, SpentOn: today}))
+	}
+	return server, user, wp, timeEntries
+}
+
+func TestSyncDetectsDeletionsFromIds(t *testing.T) {
+	server, user, _, timeEntries := newMirrorServer(t)
+	client := newStoreClient(t, server.BaseURL())
+	transport := &recordingTransport{}
+	client.Client = &http.Client{Transport: transport}
+	if _, err := client.Sync(user.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := NewClient(server.BaseURL(), "apikey", openprojecttest.APIKey).DeleteTimeEntry(timeEntries[0].Id); err != nil {
+		t.Fatal(err)
+	}
+	transport.urls = nil
+	changed, err := client.Sync(user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !changed {
+		t.Error("Sync() reported no change after a deletion")
+	}
+
+	mirrored, err := client.ListTimeEntriesBefore(user.Id, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := mirrored.Embedded.Elements; len(got) != 1 || got[0].Id != timeEntries[1].Id {
+		t.Errorf("mirrored time entries = %+v, want only %d", got, timeEntries[1].Id)
+	}
+
+	full := 0
+	for _, u := range transport.urls {
+		if u.Path != "/api/v3/time_entries" {
+			continue
+		}
+		switch selected := u.Query().Get("select"); selected {
+		case "total,elements/id":
+		case "":
+			if !strings.Contains(u.Query().Get("filters"), "updated_at") {
+				full++
+			}
+		default:
+			t.Errorf("unexpected select=%q", selected)
+		}
+	}
+	if full > 0 {
+		t.Errorf("the incremental sync fetched all the time entries %d times, want only their IDs", full)
+	}
+}
+
+func TestLoggingTimeRefreshesMirroredSpentTime(t *testing.T) {
+	server, user, wp, timeEntries := newMirrorServer(t)
+	client := newStoreClient(t, server.BaseURL())
+	if _, err := client.Sync(user.Id); err != nil {
+		t.Fatal(err)
+	}
+	spentTime := func() string {
+		details, err := client.GetWorkPackage(wp.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return details.SpentTime
+	}
+	if got := spentTime(); got != "PT3H" {
+		t.Fatalf("spent time = %s, want PT3H", got)
+	}
+
+	hours := NewDurationFromSeconds(30 * 60)
+	if err := client.CreateTimeEntry(NewTimeEntryRequest(user.Id, wp.Id, 3, &hours, "", time.Now().Format("2006-01-02"))); err != nil {
+		t.Fatal(err)
+	}
+	if got := spentTime(); got != "PT3H30M" {
+		t.Errorf("spent time after logging 30m = %s, want PT3H30M", got)
+	}
+
+	if err := client.UpdateTimeEntry(timeEntries[1].Id, map[string]interface{}{"hours": "PT4H"}); err != nil {
+		t.Fatal(err)
+	}
+	if got := spentTime(); got != "PT5H30M" {
+		t.Errorf("spent time after changing 2h to 4h = %s, want PT5H30M", got)
+	}
+
+	if err := client.DeleteTimeEntry(timeEntries[0].Id); err != nil {
+		t.Fatal(err)
+	}
+	if got := spentTime(); got != "PT4H30M" {
+		t.Errorf("spent time after deleting 1h = %s, want PT4H30M", got)
+	}
+}
+
+func TestMirrorWithoutAssignedWorkPackages(t *testing.T) {
+	server := openprojecttest.NewServer()
+	t.Cleanup(server.Close)
+	user := server.AddUser(openprojecttest.User{Login: "dana", Name: "Dana"})
+	if _, err := newStoreClient(t, server.BaseURL()).Sync(user.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	// The next start reads the mirror from the file.
+	store, err := OpenStore("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(server.BaseURL(), "apikey", openprojecttest.APIKey)
+	client.store = store
+	workPackages, err := client.ListWorkPackages(user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if workPackages == nil || len(workPackages.Embedded.Elements) != 0 {
+		t.Fatalf("ListWorkPackages = %+v, want no work packages", workPackages)
+	}
+	newMemoryTui(t, client)
+}
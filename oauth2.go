@@ -0,0 +1,472 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Authentication types.
+const (
+	authAPIKey = "apikey"
+	authOAuth2 = "oauth2"
+)
+
+const (
+	// defaultOAuth2Scope gives access to the API v3.
+	defaultOAuth2Scope = "api_v3"
+
+	// defaultRedirectURI is the loopback address that receives the authorization code. It must be registered as a
+	// redirect URI of the OAuth application in OpenProject.
+	defaultRedirectURI = "http://127.0.0.1:8765/callback"
+
+	// oauth2KeyringService is the service name under which OAuth2 tokens are stored in the Secret Service keyring.
+	oauth2KeyringService = "lazyop-oauth2"
+
+	// loginTimeout is how long to wait for the user to authorize the application in the browser.
+	loginTimeout = 5 * time.Minute
+)
+
+// Auth selects how a profile authenticates. The zero value uses the API key.
+type Auth struct {
+	// Type is `apikey` (the default) or `oauth2`.
+	Type string `json:"type"`
+
+	// ClientID is the ID of the OAuth application created in Administration > Authentication > OAuth applications.
+	ClientID string `json:"client_id"`
+	// ClientSecret is only needed for confidential applications.
+	ClientSecret string `json:"client_secret"`
+	// Scope defaults to `api_v3`.
+	Scope string `json:"scope"`
+	// RedirectURI must be a loopback address, `http://127.0.0.1:8765/callback` by default.
+	RedirectURI string `json:"redirect_uri"`
+}
+
+// UsesOAuth2 reports whether the profile authenticates with OAuth2 rather than an API key.
+func (a *Auth) UsesOAuth2() bool {
+	return a.Type == authOAuth2
+}
+
+// Validate checks that the settings are consistent.
+func (a *Auth) Validate() error {
+	switch a.Type {
+	case "", authAPIKey:
+		return nil
+	case authOAuth2:
+	default:
+		return fmt.Errorf("invalid auth type %q: must be %s or %s", a.Type, authAPIKey, authOAuth2)
+	}
+	if a.ClientID == "" {
+		return fmt.Errorf("missing auth client_id")
+	}
+	redirect, err := url.Parse(a.redirectURI())
+	if err != nil {
+		return fmt.Errorf("invalid auth redirect_uri: %v", err)
+	}
+	if redirect.Scheme != "http" || !isLoopback(redirect.Hostname()) || redirect.Port() == "" {
+		return fmt.Errorf("invalid auth redirect_uri %q: must be http on a loopback address with a port", a.RedirectURI)
+	}
+	return nil
+}
+
+func (a *Auth) scope() string {
+	if a.Scope == "" {
+		return defaultOAuth2Scope
+	}
+	return a.Scope
+}
+
+func (a *Auth) redirectURI() string {
+	if a.RedirectURI == "" {
+		return defaultRedirectURI
+	}
+	return a.RedirectURI
+}
+
+// isLoopback reports whether host is `localhost` or a loopback IP address.
+func isLoopback(host string) bool {
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// authenticator adds credentials to the requests of a `Client`.
+type authenticator interface {
+	// authorize sets the credentials of a request.
+	authorize(req *http.Request) error
+	// refresh renews the credentials after the server rejected them. It reports whether they changed, i.e. whether
+	// the request is worth retrying.
+	refresh() (bool, error)
+}
+
+// apiKeyAuth authenticates with basic auth, using an API key as password.
+type apiKeyAuth struct {
+	// username is always `apikey`.
+	username string
+	apiKey   string
+}
+
+func (a *apiKeyAuth) authorize(req *http.Request) error {
+	req.SetBasicAuth(a.username, a.apiKey)
+	return nil
+}
+
+func (a *apiKeyAuth) refresh() (bool, error) {
+	return false, nil
+}
+
+// oauth2Token is the token set returned by the token endpoint.
+type oauth2Token struct {
+	AccessToken  string    `json:"access_token"`
+	RefreshToken string    `json:"refresh_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresAt    time.Time `json:"expires_at"`
+}
+
+// expired reports whether the access token is expired or about to.
+func (t *oauth2Token) expired() bool {
+	return !t.ExpiresAt.IsZero() && time.Now().Add(30*time.Second).After(t.ExpiresAt)
+}
+
+// oauth2Auth authenticates with OAuth2 bearer tokens, obtained with the authorization code flow with PKCE and
+// refreshed when they expire. The tokens are stored with `saveToken`.
+type oauth2Auth struct {
+	config      Auth
+	profileName string
+	// instanceURL is the address of the instance, where the `oauth/authorize` and `oauth/token` endpoints are.
+	instanceURL string
+	// httpClient sends the requests to the token endpoint, with the same transport as the API requests.
+	httpClient *http.Client
+
+	// mu guards token, as requests may be made concurrently.
+	mu    sync.Mutex
+	token *oauth2Token
+}
+
+// newOAuth2Auth returns the authenticator of a profile, using its stored tokens. If there are none, or they can't
+// be refreshed, the user is asked to log in when interactive is set; otherwise an error is returned. Tokens that
+// can't be refreshed because the server is unreachable are kept, so that the changes made offline are queued.
+func newOAuth2Auth(profileName string, profile *Profile, httpClient *http.Client, interactive bool) (*oauth2Auth, error) {
+	a := &oauth2Auth{
+		config:      profile.Auth,
+		profileName: profileName,
+		instanceURL: strings.TrimSuffix(profile.BaseURL, apiPath),
+		httpClient:  httpClient,
+	}
+	token, err := loadToken(profileName)
+	if err == nil && token != nil {
+		a.token = token
+		if !token.expired() {
+			return a, nil
+		}
+		if err = a.refreshToken(); err == nil || isOffline(err) {
+			return a, nil
+		}
+	}
+	if !interactive {
+		if err == nil {
+			err = fmt.Errorf("not logged in")
+		}
+		return nil, fmt.Errorf("profile %s: %v, run `lazyop -profile %s auth login`", profileName, err, profileName)
+	}
+	if err := a.login(); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// loginOAuth2 logs in to a profile and stores the new tokens, replacing the previous ones.
+func loginOAuth2(profileName string, profile *Profile) error {
+	httpClient, err := profile.HTTP.Client()
+	if err != nil {
+		return fmt.Errorf("profile %s: %v", profileName, err)
+	}
+	a := &oauth2Auth{
+		config:      profile.Auth,
+		profileName: profileName,
+		instanceURL: strings.TrimSuffix(profile.BaseURL, apiPath),
+		httpClient:  httpClient,
+	}
+	return a.login()
+}
+
+func (a *oauth2Auth) authorize(req *http.Request) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.token.expired() {
+		if err := a.refreshToken(); err != nil {
+			return err
+		}
+	}
+	req.Header.Set("Authorization", "Bearer "+a.token.AccessToken)
+	return nil
+}
+
+func (a *oauth2Auth) refresh() (bool, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.refreshToken(); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// refreshToken exchanges the refresh token for a new token set. a.mu must be held.
+func (a *oauth2Auth) refreshToken() error {
+	if a.token.RefreshToken == "" {
+		return fmt.Errorf("the access token expired and cannot be refreshed, run `lazyop -profile %s auth login`", a.profileName)
+	}
+	token, err := a.requestToken(url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {a.token.RefreshToken},
+	})
+	if err != nil {
+		return fmt.Errorf("error refreshing the access token: %w", err)
+	}
+	if token.RefreshToken == "" {
+		token.RefreshToken = a.token.RefreshToken
+	}
+	a.token = token
+	return saveToken(a.profileName, token)
+}
+
+// login runs the authorization code flow: it opens the authorization page in the browser and waits for the
+// redirect to the loopback address with the code, which it exchanges for a token set.
+func (a *oauth2Auth) login() error {
+	verifier, err := randomString(32)
+	if err != nil {
+		return err
+	}
+	state, err := randomString(16)
+	if err != nil {
+		return err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	redirect, err := url.Parse(a.config.redirectURI())
+	if err != nil {
+		return fmt.Errorf("invalid auth redirect_uri: %v", err)
+	}
+	listener, err := net.Listen("tcp", redirect.Host)
+	if err != nil {
+		return fmt.Errorf("cannot listen on %s for the OAuth2 redirect: %v", redirect.Host, err)
+	}
+
+	type result struct {
+		code string
+		err  error
+	}
+	results := make(chan result, 1)
+	mux := http.NewServeMux()
+	mux.HandleFunc(redirect.Path, func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		var res result
+		switch {
+		case query.Get("state") != state:
+			res.err = fmt.Errorf("invalid state in the OAuth2 redirect")
+		case query.Get("error") != "":
+			res.err = fmt.Errorf("authorization denied: %s %s", query.Get("error"), query.Get("error_description"))
+		default:
+			res.code = query.Get("code")
+		}
+		if res.err != nil {
+			http.Error(w, res.err.Error(), http.StatusBadRequest)
+		} else {
+			fmt.Fprintln(w, "lazyop is now authorized, you can close this page.")
+		}
+		select {
+		case results <- res:
+		default:
+		}
+	})
+	server := &http.Server{Handler: mux}
+	go server.Serve(listener)
+	defer server.Shutdown(context.Background())
+
+	authorizeURL := a.instanceURL + "oauth/authorize?" + url.Values{
+		"response_type":         {"code"},
+		"client_id":             {a.config.ClientID},
+		"redirect_uri":          {redirect.String()},
+		"scope":                 {a.config.scope()},
+		"state":                 {state},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}.Encode()
+	fmt.Printf("Open the following page to authorize lazyop for profile %s:\n\n%s\n\n", a.profileName, authorizeURL)
+	openBrowser(authorizeURL)
+
+	var res result
+	select {
+	case res = <-results:
+	case <-time.After(loginTimeout):
+		return fmt.Errorf("timed out waiting for the authorization")
+	}
+	if res.err != nil {
+		return res.err
+	}
+
+	token, err := a.requestToken(url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {res.code},
+		"redirect_uri":  {redirect.String()},
+		"code_verifier": {verifier},
+	})
+	if err != nil {
+		return fmt.Errorf("error exchanging the authorization code: %v", err)
+	}
+	a.mu.Lock()
+	a.token = token
+	a.mu.Unlock()
+	return saveToken(a.profileName, token)
+}
+
+// requestToken posts a grant to the token endpoint.
+func (a *oauth2Auth) requestToken(values url.Values) (*oauth2Token, error) {
+	values.Set("client_id", a.config.ClientID)
+	if a.config.ClientSecret != "" {
+		values.Set("client_secret", a.config.ClientSecret)
+	}
+	res, err := a.httpClient.PostForm(a.instanceURL+"oauth/token", values)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		oauth2Token
+		ExpiresIn        int64  `json:"expires_in"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.Unmarshal(body, &response); err != nil {
+		return nil, fmt.Errorf("unexpected response (status %d): %s", res.StatusCode, string(body))
+	}
+	if response.Error != "" {
+		return nil, fmt.Errorf("%s: %s", response.Error, response.ErrorDescription)
+	}
+	if res.StatusCode != http.StatusOK || response.AccessToken == "" {
+		return nil, fmt.Errorf("unexpected response (status %d): %s", res.StatusCode, string(body))
+	}
+	token := response.oauth2Token
+	if response.ExpiresIn > 0 {
+		token.ExpiresAt = time.Now().Add(time.Duration(response.ExpiresIn) * time.Second)
+	}
+	return &token, nil
+}
+
+// randomString returns n random bytes, base64url-encoded, as used for the PKCE code verifier and the state.
+func randomString(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating random data: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// openBrowser tries to open a URL in the default browser. Failures are ignored, the URL is printed anyway.
+func openBrowser(address string) {
+	command := "xdg-open"
+	switch runtime.GOOS {
+	case "darwin":
+		command = "open"
+	case "windows":
+		command = "explorer"
+	}
+	exec.Command(command, address).Start()
+}
+
+// The tokens are stored in the Secret Service keyring when `secret-tool` is available, otherwise in a file that only
+// the user can read, in `$XDG_STATE_HOME/lazyop/tokens`.
+
+// tokenFile returns the path of the file storing the tokens of a profile.
+func tokenFile(profileName string) string {
+	return filepath.Join(stateHome(), "lazyop", "tokens", profileName+".json")
+}
+
+// keyringAvailable reports whether the Secret Service keyring can be used.
+func keyringAvailable() bool {
+	_, err := exec.LookPath("secret-tool")
+	return err == nil
+}
+
+// loadToken returns the stored tokens of a profile, or nil if there are none.
+func loadToken(profileName string) (*oauth2Token, error) {
+	var data []byte
+	if keyringAvailable() {
+		output, err := exec.Command("secret-tool", "lookup", "service", oauth2KeyringService, "profile", profileName).Output()
+		if err == nil {
+			data = output
+		}
+	}
+	if len(data) == 0 {
+		var err error
+		data, err = os.ReadFile(tokenFile(profileName))
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading OAuth2 tokens: %v", err)
+		}
+	}
+	var token oauth2Token
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, fmt.Errorf("error reading OAuth2 tokens: %v", err)
+	}
+	return &token, nil
+}
+
+// saveToken stores the tokens of a profile.
+func saveToken(profileName string, token *oauth2Token) error {
+	data, err := json.Marshal(token)
+	if err != nil {
+		return fmt.Errorf("error saving OAuth2 tokens: %v", err)
+	}
+	if keyringAvailable() {
+		cmd := exec.Command("secret-tool", "store", "--label", "lazyop OAuth2 tokens ("+profileName+")",
+			"service", oauth2KeyringService, "profile", profileName)
+		cmd.Stdin = bytes.NewReader(data)
+		if err := cmd.Run(); err == nil {
+			return nil
+		}
+	}
+	path := tokenFile(profileName)
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("error saving OAuth2 tokens: %v", err)
+	}
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		return fmt.Errorf("error saving OAuth2 tokens: %v", err)
+	}
+	return nil
+}
+
+// deleteToken removes the stored tokens of a profile.
+func deleteToken(profileName string) error {
+	if keyringAvailable() {
+		exec.Command("secret-tool", "clear", "service", oauth2KeyringService, "profile", profileName).Run()
+	}
+	if err := os.Remove(tokenFile(profileName)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("error deleting OAuth2 tokens: %v", err)
+	}
+	return nil
+}
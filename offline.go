@@ -0,0 +1,180 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// replayInterval is how often the pending operations are replayed while there are some.
+const replayInterval = 30 * time.Second
+
+// isOffline reports whether a request failed because the server couldn't be reached, rather than being rejected. Only
+// failures to resolve or connect to the server count: the request wasn't sent, so it can safely be queued. Others,
+// e.g. a certificate that isn't trusted or a timeout once the request was sent, are errors.
+func isOffline(err error) bool {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr) && opErr.Op == "dial"
+}
+
+// SyncStatus describes the connection and the changes that are not on the server yet.
+type SyncStatus struct {
+	Offline   bool
+	Pending   int
+	Conflicts int
+}
+
+// SyncStatus returns whether the last request reached the server and how many operations are pending.
+func (c *Client) SyncStatus() SyncStatus {
+	status := SyncStatus{Offline: c.offline.Load()}
+	if c.store == nil {
+		return status
+	}
+	for _, op := range c.store.pending() {
+		status.Pending++
+		if op.Conflict != "" {
+			status.Conflicts++
+		}
+	}
+	return status
+}
+
+// PendingOperations returns the changes made offline, in the order they will be replayed.
+func (c *Client) PendingOperations() []PendingOperation {
+	if c.store == nil {
+		return nil
+	}
+	return c.store.pending()
+}
+
+// ReplayPending replays the pending operations in order, skipping those in conflict. An operation whose time entry
+// changed on the server since it was queued, or that the server rejects, is flagged as a conflict for the user to
+// resolve with `ResolvePending`. It stops at the first operation that can't reach the server, and returns the
+// number of operations replayed.
+func (c *Client) ReplayPending() (int, error) {
+	c.replaying.Lock()
+	defer c.replaying.Unlock()
+	if c.store != nil {
+		defer c.store.lockReplay()()
+	}
+
+	replayed := 0
+	// changed are the time entries changed by the operations replayed so far. Their version on the server is ours,
+	// so they don't conflict with the following operations.
+	changed := make(map[int]bool)
+	// blocked are the time entries with an operation in conflict. Their following operations wait for it.
+	blocked := make(map[int]bool)
+	for _, op := range c.PendingOperations() {
+		if op.Conflict != "" || blocked[op.TimeEntryId] {
+			blocked[op.TimeEntryId] = true
+			continue
+		}
+		var conflict string
+		var err error
+		if !changed[op.TimeEntryId] {
+			conflict, err = c.checkPending(op)
+		}
+		if err == nil && conflict == "" {
+			err = c.applyPending(op)
+		}
+		switch {
+		case isOffline(err):
+			return replayed, err
+		case err != nil:
+			conflict = err.Error()
+		}
+		if conflict != "" {
+			if err := c.store.setConflict(op.Id, conflict); err != nil {
+				return replayed, err
+			}
+			blocked[op.TimeEntryId] = true
+			continue
+		}
+		if err := c.store.resolvePending(op.Id); err != nil {
+			return replayed, err
+		}
+		changed[op.TimeEntryId] = true
+		replayed++
+	}
+	return replayed, nil
+}
+
+// ResolvePending resolves a conflict: the operation is either applied regardless of the changes on the server, or
+// discarded.
+func (c *Client) ResolvePending(op PendingOperation, keepMine bool) error {
+	c.replaying.Lock()
+	defer c.replaying.Unlock()
+	defer c.store.lockReplay()()
+	if !c.store.isPending(op.Id) {
+		// Resolved by another process.
+		return nil
+	}
+
+	if keepMine {
+		err := c.applyPending(op)
+		var statusErr *StatusError
+		if op.Kind == pendingDelete && errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound {
+			// Already deleted on the server.
+			err = nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return c.store.resolvePending(op.Id)
+}
+
+// checkPending returns why an update or deletion conflicts with the time entry on the server, if it does.
+func (c *Client) checkPending(op PendingOperation) (string, error) {
+	if op.Kind == pendingCreate {
+		return "", nil
+	}
+	current, err := c.GetTimeEntry(op.TimeEntryId)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.Code == http.StatusNotFound {
+		return "the time entry was deleted on the server", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if op.Base != nil && current.UpdatedAt != op.Base.UpdatedAt {
+		return fmt.Sprintf("the time entry was changed on the server at %s", current.UpdatedAt), nil
+	}
+	return "", nil
+}
+
+// applyPending sends a pending operation to the server.
+func (c *Client) applyPending(op PendingOperation) error {
+	switch op.Kind {
+	case pendingCreate:
+		return c.createTimeEntry(op.Request)
+	case pendingUpdate:
+		return c.updateTimeEntry(op.TimeEntryId, op.Update)
+	case pendingDelete:
+		return c.deleteTimeEntry(op.TimeEntryId)
+	}
+	return fmt.Errorf("unknown pending operation %q", op.Kind)
+}
+
+// describePending returns a one-line description of a pending operation.
+func describePending(op PendingOperation) string {
+	var te TimeEntry
+	switch {
+	case op.Kind == pendingCreate:
+		te = op.Request.timeEntry(op.TimeEntryId)
+	case op.Base != nil:
+		te = *op.Base
+	default:
+		return fmt.Sprintf("%s time entry %d", op.Kind, op.TimeEntryId)
+	}
+	if op.Kind == pendingUpdate {
+		mergeJSON(&te, op.Update)
+	}
+	return fmt.Sprintf("%s %s on #%d, %s: %s", op.Kind, formatHours(te.Hours), idFromHref(te.Links.WorkPackage.Href), te.Date, te.Comment.Raw)
+}
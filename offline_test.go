@@ -0,0 +1,195 @@
+package main
+
+import (
+	"io"
+	"lazyop/openprojecttest"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newStoreClient returns a client of a server with a local store in a temporary directory.
+func newStoreClient(t *testing.T, baseURL string) *Client {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+	store, err := OpenStore("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(baseURL, "apikey", openprojecttest.APIKey)
+	client.store = store
+	return client
+}
+
+// unreachableURL returns the base URL of a server that was stopped.
+func unreachableURL() string {
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	return server.URL + "/api/v3/"
+}
+
+func TestReplayPendingSendsEachOperationOnce(t *testing.T) {
+	server := openprojecttest.NewServer()
+	defer server.Close()
+	user := server.AddUser(openprojecttest.User{Login: "dana", Name: "Dana"})
+	project := server.AddProject(openprojecttest.Project{Name: "Website"})
+	wp := server.AddWorkPackage(openprojecttest.WorkPackage{Subject: "Landing page", ProjectId: project.Id, AssigneeId: user.Id})
+
+	client := newStoreClient(t, server.BaseURL())
+	hours := NewDurationFromSeconds(3600)
+	for _, comment := range []string{"first", "second"} {
+		if err := client.store.queueCreate(NewTimeEntryRequest(user.Id, wp.Id, 3, &hours, comment, "2024-05-06")); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The background sync, the pending list and the refresh action may replay at the same time.
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.ReplayPending(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if got := len(server.TimeEntries()); got != 2 {
+		t.Errorf("%d time entries created on the server, want 2", got)
+	}
+	if status := client.SyncStatus(); status.Pending != 0 {
+		t.Errorf("%d operations still pending, want 0", status.Pending)
+	}
+}
+
+// openStoreClient returns a client with its own copy of the store of the profile of newStoreClient, as another
+// process would have.
+func openStoreClient(t *testing.T, baseURL string) *Client {
+	store, err := OpenStore("test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	client := NewClient(baseURL, "apikey", openprojecttest.APIKey)
+	client.store = store
+	return client
+}
+
+func TestStoreSharedByProcesses(t *testing.T) {
+	server := openprojecttest.NewServer()
+	defer server.Close()
+	user := server.AddUser(openprojecttest.User{Login: "dana", Name: "Dana"})
+	project := server.AddProject(openprojecttest.Project{Name: "Website"})
+	wp := server.AddWorkPackage(openprojecttest.WorkPackage{Subject: "Landing page", ProjectId: project.Id, AssigneeId: user.Id})
+
+	// The UI and `lazyop time import` queue changes while offline, each with the store as it read it.
+	ui := newStoreClient(t, unreachableURL())
+	imported := openStoreClient(t, unreachableURL())
+	hours := NewDurationFromSeconds(3600)
+	var wg sync.WaitGroup
+	for _, client := range []*Client{ui, imported} {
+		client := client
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := 0; i < 10; i++ {
+				if err := client.CreateTimeEntry(NewTimeEntryRequest(user.Id, wp.Id, 3, &hours, "", "2024-05-06")); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	ids := make(map[int]bool)
+	for _, op := range openStoreClient(t, unreachableURL()).PendingOperations() {
+		ids[op.Id] = true
+	}
+	if len(ids) != 20 {
+		t.Fatalf("%d distinct operations queued by both processes, want 20", len(ids))
+	}
+
+	// Both replay them once the server is reachable again.
+	for _, client := range []*Client{openStoreClient(t, server.BaseURL()), openStoreClient(t, server.BaseURL())} {
+		client := client
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := client.ReplayPending(); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	if got := len(server.TimeEntries()); got != 20 {
+		t.Errorf("%d time entries created on the server, want 20", got)
+	}
+	if pending := ui.PendingOperations(); len(pending) != 0 {
+		t.Errorf("%d operations still pending, want 0", len(pending))
+	}
+}
+
+func TestExpiredOAuth2TokenOfflineQueuesChanges(t *testing.T) {
+	baseURL := unreachableURL()
+	client := newStoreClient(t, baseURL)
+	client.auth = &oauth2Auth{
+		profileName: "test",
+		instanceURL: baseURL[:len(baseURL)-len(apiPath)],
+		httpClient:  http.DefaultClient,
+		token: &oauth2Token{
+			AccessToken:  "expired",
+			RefreshToken: "refresh",
+			ExpiresAt:    time.Now().Add(-time.Hour),
+		},
+	}
+
+	hours := NewDurationFromSeconds(3600)
+	if err := client.CreateTimeEntry(NewTimeEntryRequest(1, 1, 3, &hours, "offline", "2024-05-06")); err != nil {
+		t.Fatalf("CreateTimeEntry with an expired token offline: %v, want it queued", err)
+	}
+	status := client.SyncStatus()
+	if !status.Offline || status.Pending != 1 {
+		t.Errorf("SyncStatus() = %+v, want offline with 1 pending operation", status)
+	}
+	if token := client.auth.(*oauth2Auth).token; token.RefreshToken != "refresh" {
+		t.Errorf("refresh token = %q after a failed refresh, want it kept", token.RefreshToken)
+	}
+}
+
+func TestIsOffline(t *testing.T) {
+	tlsServer := httptest.NewUnstartedServer(http.NotFoundHandler())
+	tlsServer.Config.ErrorLog = log.New(io.Discard, "", 0)
+	tlsServer.StartTLS()
+	t.Cleanup(tlsServer.Close)
+	slowServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	t.Cleanup(slowServer.Close)
+
+	tests := []struct {
+		name    string
+		baseURL string
+		timeout time.Duration
+		offline bool
+	}{
+		{"connection refused", unreachableURL(), 0, true},
+		{"unknown host", "http://lazyop.invalid/api/v3/", 0, true},
+		{"untrusted certificate", tlsServer.URL + "/api/v3/", 0, false},
+		{"timeout once sent", slowServer.URL + "/api/v3/", 50 * time.Millisecond, false},
+	}
+	for _, test := range tests {
+		client := NewClient(test.baseURL, "apikey", openprojecttest.APIKey)
+		client.Client = &http.Client{Timeout: test.timeout}
+		hours := NewDurationFromSeconds(3600)
+		err := client.createTimeEntry(NewTimeEntryRequest(1, 1, 3, &hours, "", "2024-05-06"))
+		if err == nil {
+			t.Errorf("%s: no error", test.name)
+			continue
+		}
+		if got := isOffline(err); got != test.offline {
+			t.Errorf("%s: isOffline(%v) = %v, want %v", test.name, err, got, test.offline)
+		}
+	}
+}
@@ -1,9 +1,16 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"sync"
+	"sync/atomic"
 )
 
 type Client struct {
@@ -12,44 +19,179 @@ type Client struct {
 	// baseURL is the base URL of the OpenProject API including the version.
 	baseURL string
 
-	// username is the username used for authentication. It is always `apikey`.
-	username string
+	// auth adds the credentials to the requests: an API key or OAuth2 tokens.
+	auth authenticator
 
-	// apiKey is the API key used for authentication.
-	apiKey string
+	// store keeps the data fetched last and the changes made while offline, see `offline.go`. It may be nil.
+	store *Store
+
+	// offline is set when the last request couldn't reach the server.
+	offline atomic.Bool
+
+	// replaying serialises the replays of the pending operations, which are started by the background sync and by the
+	// user, so that an operation is never sent twice.
+	replaying sync.Mutex
 }
 
+// NewClient returns a client authenticating with an API key. The username is always `apikey`.
 func NewClient(baseURL, username, apiKey string) *Client {
 	return &Client{
-		Client:   http.DefaultClient,
-		baseURL:  baseURL,
-		username: username,
-		apiKey:   apiKey,
+		Client:  http.DefaultClient,
+		baseURL: baseURL,
+		auth:    &apiKeyAuth{username: username, apiKey: apiKey},
+	}
+}
+
+// NewClientFromProfile returns a client for a profile selected with `SelectProfile`, connecting with its `http`
+// options and authenticating as set in its `auth` section. An OAuth2 login is only started, and warnings only
+// printed, if interactive is set, i.e. when the terminal isn't used by the UI.
+func NewClientFromProfile(profileName string, profile *Profile, interactive bool) (*Client, error) {
+	httpClient, err := profile.HTTP.Client()
+	if err != nil {
+		return nil, fmt.Errorf("profile %s: %v", profileName, err)
+	}
+	if profile.HTTP.InsecureSkipVerify && interactive {
+		fmt.Fprintf(os.Stderr, "warning: profile %s doesn't verify the certificate of %s, the connection is not secure\n", profileName, profile.BaseURL)
 	}
+
+	store, err := OpenStore(profileName)
+	if err != nil {
+		return nil, err
+	}
+	client := &Client{Client: httpClient, baseURL: profile.BaseURL, store: store}
+	if !profile.Auth.UsesOAuth2() {
+		client.auth = &apiKeyAuth{username: "apikey", apiKey: profile.APIKey}
+		return client, nil
+	}
+	client.auth, err = newOAuth2Auth(profileName, profile, httpClient, interactive)
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
 }
 
-// doRequest performs an HTTP request with the given method, endpoint, and body.
+// doRequest performs an HTTP request with the given method, endpoint, and body. If the credentials are rejected and
+// can be refreshed, e.g. an expired OAuth2 access token, the request is retried once with the new ones.
 func (c *Client) doRequest(method, endpoint string, body io.Reader) ([]byte, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = io.ReadAll(body); err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+
+	res, err := c.send(method, endpoint, payload)
+	c.offline.Store(isOffline(err))
+	if err != nil {
+		return nil, err
+	}
+	if res.StatusCode == http.StatusUnauthorized {
+		refreshed, err := c.auth.refresh()
+		if err != nil {
+			res.Body.Close()
+			return nil, err
+		}
+		if refreshed {
+			res.Body.Close()
+			if res, err = c.send(method, endpoint, payload); err != nil {
+				return nil, err
+			}
+		}
+	}
+	defer res.Body.Close()
+
+	resBody, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return nil, &StatusError{Code: res.StatusCode, Body: string(resBody)}
+	}
+
+	return resBody, nil
+}
+
+// StatusError is returned for responses with a status code other than 2xx.
+type StatusError struct {
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status code: %d, response: %s", e.Code, e.Body)
+}
+
+// send performs a single authenticated HTTP request.
+func (c *Client) send(method, endpoint string, payload []byte) (*http.Response, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
 	req, err := http.NewRequest(method, endpoint, body)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create request: %w", err)
 	}
 	req.Header.Set("Content-Type", "application/json")
-	req.SetBasicAuth(c.username, c.apiKey)
+	if err := c.auth.authorize(req); err != nil {
+		return nil, err
+	}
 
 	res, err := c.Do(req)
 	if err != nil {
 		return nil, fmt.Errorf("failed to perform request: %w", err)
 	}
-	defer res.Body.Close()
+	return res, nil
+}
 
-	resBody, err := io.ReadAll(res.Body)
+// Root represents the root resource of the API, which describes the instance.
+type Root struct {
+	Type         string `json:"_type"`
+	InstanceName string `json:"instanceName"`
+	CoreVersion  string `json:"coreVersion"`
+}
+
+// GetRoot returns the root resource of the API. It is a cheap way to test the connection and the credentials.
+func (c *Client) GetRoot() (*Root, error) {
+	body, err := c.doRequest("GET", c.baseURL, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read response body: %w", err)
+		return nil, fmt.Errorf("error making request: %w", err)
 	}
-	if res.StatusCode < 200 || res.StatusCode >= 300 {
-		return nil, fmt.Errorf("unexpected status code: %d, response: %s", res.StatusCode, string(resBody))
+	var root Root
+	if err := json.Unmarshal(body, &root); err != nil {
+		return nil, fmt.Errorf("error unmarshalling response: %v", err)
+	}
+	if root.Type != "Root" {
+		return nil, fmt.Errorf("%s is not the root of the OpenProject API v3", c.baseURL)
 	}
+	return &root, nil
+}
 
-	return resBody, nil
+// idFromHref returns the numeric ID at the end of a resource link, e.g. `/api/v3/work_packages/42`.
+func idFromHref(href string) int {
+	id, err := strconv.Atoi(path.Base(href))
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// workPackageHref returns the link to a work package.
+func workPackageHref(id int) string {
+	return fmt.Sprintf("/api/v3/work_packages/%d", id)
+}
+
+// activityHref returns the link to a time entry activity.
+func activityHref(id int) string {
+	return fmt.Sprintf("/api/v3/time_entries/activities/%d", id)
+}
+
+// userHref returns the link to a user.
+func userHref(id int) string {
+	return fmt.Sprintf("/api/v3/users/%d", id)
+}
+
+// statusHref returns the link to a work package status.
+func statusHref(id int) string {
+	return fmt.Sprintf("/api/v3/statuses/%d", id)
 }
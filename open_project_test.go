@@ -0,0 +1,274 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"lazyop/openprojecttest"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// newTestServer returns a server with two users and a project, and a client of the first user.
+func newTestServer(t *testing.T) (*openprojecttest.Server, *Client, openprojecttest.User, openprojecttest.Project) {
+	server := openprojecttest.NewServer()
+	t.Cleanup(server.Close)
+	user := server.AddUser(openprojecttest.User{Login: "dana", Name: "Dana"})
+	server.AddUser(openprojecttest.User{Login: "sam", Name: "Sam"})
+	project := server.AddProject(openprojecttest.Project{Name: "Website"})
+	return server, NewClient(server.BaseURL(), "apikey", openprojecttest.APIKey), user, project
+}
+
+func TestClientPagination(t *testing.T) {
+	server, client, user, project := newTestServer(t)
+	wp := server.AddWorkPackage(openprojecttest.WorkPackage{Subject: "Landing page", ProjectId: project.Id, AssigneeId: user.Id})
+	for i := 1; i < 150; i++ {
+		server.AddWorkPackage(openprojecttest.WorkPackage{Subject: fmt.Sprintf("Page %d", i), ProjectId: project.Id, AssigneeId: user.Id})
+	}
+	today := time.Now()
+	for i := 0; i < 250; i++ {
+		server.AddTimeEntry(openprojecttest.TimeEntry{WorkPackageId: wp.Id, UserId: user.Id, Hours: "PT1H", SpentOn: today.AddDate(0, 0, -i/10).Format("2006-01-02")})
+	}
+
+	workPackages, err := client.ListWorkPackages(user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if workPackages.Total != 150 || len(workPackages.Embedded.Elements) != 100 {
+		t.Errorf("ListWorkPackages = %d of %d, want the first 100 of 150", len(workPackages.Embedded.Elements), workPackages.Total)
+	}
+
+	timeEntries, err := client.ListTimeEntries(wp.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if timeEntries.Total != 250 || len(timeEntries.Embedded.Elements) != 100 {
+		t.Errorf("ListTimeEntries = %d of %d, want the first 100 of 250", len(timeEntries.Embedded.Elements), timeEntries.Total)
+	}
+	filters := fmt.Sprintf(filterTimeEntriesWorkPackage, wp.Id)
+	seen := make(map[int]bool)
+	previous := ""
+	for page := 1; page <= 3; page++ {
+		collection, err := client.fetchTimeEntriesPage(filters, page, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := []int{100, 100, 50}[page-1]; len(collection.Embedded.Elements) != want {
+			t.Errorf("page %d has %d time entries, want %d", page, len(collection.Embedded.Elements), want)
+		}
+		for _, te := range collection.Embedded.Elements {
+			if seen[te.Id] {
+				t.Errorf("time entry %d is on two pages", te.Id)
+			}
+			if te.Date < previous {
+				t.Errorf("time entry %d of %s comes after %s", te.Id, te.Date, previous)
+			}
+			seen[te.Id], previous = true, te.Date
+		}
+	}
+
+	// The mirror goes through all the pages.
+	mirrored := newStoreClient(t, server.BaseURL())
+	if _, err := mirrored.Sync(user.Id); err != nil {
+		t.Fatal(err)
+	}
+	all, err := mirrored.ListTimeEntriesBetween(user.Id, today.AddDate(0, 0, -30), today)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(all.Embedded.Elements) != 250 {
+		t.Errorf("%d time entries mirrored, want 250", len(all.Embedded.Elements))
+	}
+	assigned, err := mirrored.ListWorkPackages(user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(assigned.Embedded.Elements) != 150 {
+		t.Errorf("%d work packages mirrored, want 150", len(assigned.Embedded.Elements))
+	}
+}
+
+func TestClientFilters(t *testing.T) {
+	server, client, user, project := newTestServer(t)
+	// Each work package is updated after the previous one.
+	updatedAt := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	add := func(subject string, assigneeId, statusId int) openprojecttest.WorkPackage {
+		updatedAt = updatedAt.Add(time.Hour)
+		return server.AddWorkPackage(openprojecttest.WorkPackage{Subject: subject, ProjectId: project.Id, AssigneeId: assigneeId, StatusId: statusId, UpdatedAt: updatedAt})
+	}
+	landing := add("Landing page", user.Id, 2)
+	add("Closed page", user.Id, 4)
+	checkout := add("Checkout", user.Id, 0)
+	add("Sam's page", user.Id+1, 0)
+	add("Unassigned page", 0, 0)
+	for _, te := range []openprojecttest.TimeEntry{
+		{WorkPackageId: landing.Id, UserId: user.Id, Hours: "PT1H", SpentOn: "2024-04-30"},
+		{WorkPackageId: landing.Id, UserId: user.Id, Hours: "PT2H", SpentOn: "2024-05-01"},
+		{WorkPackageId: checkout.Id, UserId: user.Id, Hours: "PT3H", SpentOn: "2024-05-07"},
+		{WorkPackageId: landing.Id, UserId: user.Id + 1, Hours: "PT4H", SpentOn: "2024-05-02"},
+		{WorkPackageId: checkout.Id, UserId: user.Id, Hours: "PT5H", SpentOn: "2024-05-08"},
+	} {
+		server.AddTimeEntry(te)
+	}
+
+	subjects := func(collection *WorkPackageCollection, err error) string {
+		if err != nil {
+			return err.Error()
+		}
+		var subjects []string
+		for _, wp := range collection.Embedded.Elements {
+			subjects = append(subjects, wp.Subject)
+		}
+		return strings.Join(subjects, ", ")
+	}
+	hours := func(collection *TimeEntryCollection, err error) string {
+		if err != nil {
+			return err.Error()
+		}
+		var hours []string
+		for _, te := range collection.Embedded.Elements {
+			hours = append(hours, te.Hours)
+		}
+		return strings.Join(hours, " ")
+	}
+	day := func(date string) time.Time {
+		d, _ := time.Parse("2006-01-02", date)
+		return d
+	}
+
+	tests := []struct {
+		name, got, want string
+	}{
+		{"assigned and open", subjects(client.ListWorkPackages(user.Id)), "Checkout, Landing page"},
+		{"search", subjects(client.SearchWorkPackages("PAGE")), "Unassigned page, Sam's page, Closed page, Landing page"},
+		{"search quoted", subjects(client.SearchWorkPackages(`"page"`)), ""},
+		{"work package", hours(client.ListTimeEntries(landing.Id)), "PT1H PT2H PT4H"},
+		{"user and dates", hours(client.ListTimeEntriesBetween(user.Id, day("2024-05-01"), day("2024-05-07"))), "PT2H PT3H"},
+		{"other user", hours(client.ListTimeEntriesBetween(user.Id+1, day("2024-05-01"), day("2024-05-07"))), "PT4H"},
+	}
+	for _, test := range tests {
+		if test.got != test.want {
+			t.Errorf("%s = %q, want %q", test.name, test.got, test.want)
+		}
+	}
+}
+
+func TestClientLockVersions(t *testing.T) {
+	server, client, user, project := newTestServer(t)
+	wp := server.AddWorkPackage(openprojecttest.WorkPackage{Subject: "Landing page", ProjectId: project.Id, AssigneeId: user.Id})
+	endpoint := fmt.Sprintf("%swork_packages/%d", client.baseURL, wp.Id)
+	patch := func(body string) error {
+		_, err := client.doRequest("PATCH", endpoint, strings.NewReader(body))
+		return err
+	}
+
+	if err := patch(`{"lockVersion":0,"subject":"Home page"}`); err != nil {
+		t.Fatalf("update with the current lock version: %v", err)
+	}
+	for _, body := range []string{`{"lockVersion":0,"subject":"Stale"}`, `{"subject":"No lock version"}`} {
+		var statusErr *StatusError
+		if err := patch(body); !errors.As(err, &statusErr) || statusErr.Code != http.StatusConflict || !strings.Contains(statusErr.Body, "errors:UpdateConflict") {
+			t.Errorf("update %s: error = %v, want a 409 UpdateConflict", body, err)
+		}
+	}
+	if err := patch(`{"lockVersion":1,"subject":"Landing"}`); err != nil {
+		t.Errorf("update with the new lock version: %v", err)
+	}
+	if got := server.WorkPackages()[0]; got.Subject != "Landing" || got.LockVersion != 2 {
+		t.Errorf("work package = %q at lock version %d, want Landing at 2", got.Subject, got.LockVersion)
+	}
+}
+
+func TestClientChangeStatus(t *testing.T) {
+	server, client, user, project := newTestServer(t)
+	wp := server.AddWorkPackage(openprojecttest.WorkPackage{Subject: "Landing page", ProjectId: project.Id, AssigneeId: user.Id})
+	mirrored := newStoreClient(t, server.BaseURL())
+	if _, err := mirrored.Sync(user.Id); err != nil {
+		t.Fatal(err)
+	}
+
+	statuses, err := client.ListStatuses(wp.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, status := range statuses {
+		names = append(names, fmt.Sprintf("%d %s %v", status.Id, status.Name, status.IsClosed))
+	}
+	if got, want := strings.Join(names, ", "), "1 New false, 2 In progress false, 3 On hold false, 4 Closed true, 5 Rejected true"; got != want {
+		t.Errorf("ListStatuses = %s, want %s", got, want)
+	}
+
+	// Every change is made against the lock version of the previous one.
+	if err := client.UpdateWorkPackageStatus(wp.Id, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := mirrored.UpdateWorkPackageStatus(wp.Id, 3); err != nil {
+		t.Fatal(err)
+	}
+	if got := server.WorkPackages()[0]; got.StatusId != 3 || got.LockVersion != 2 {
+		t.Errorf("work package in status %d at lock version %d, want 3 at 2", got.StatusId, got.LockVersion)
+	}
+	if got, _ := mirrored.GetWorkPackage(wp.Id); got.Links.Status.Title != "On hold" {
+		t.Errorf("mirrored status = %q, want On hold", got.Links.Status.Title)
+	}
+
+	// A closed work package is no longer assigned, without waiting for the next sync.
+	if err := mirrored.UpdateWorkPackageStatus(wp.Id, 4); err != nil {
+		t.Fatal(err)
+	}
+	if assigned, _ := mirrored.ListWorkPackages(user.Id); len(assigned.Embedded.Elements) != 0 {
+		t.Errorf("%d work packages assigned after closing the only one", len(assigned.Embedded.Elements))
+	}
+	if err := client.UpdateWorkPackageStatus(wp.Id, 99); err == nil || !strings.Contains(err.Error(), "can't be set to status 99") {
+		t.Errorf("UpdateWorkPackageStatus to a missing status: error = %v", err)
+	}
+}
+
+func TestClientErrorBodies(t *testing.T) {
+	server, client, user, project := newTestServer(t)
+	wp := server.AddWorkPackage(openprojecttest.WorkPackage{Subject: "Landing page", ProjectId: project.Id, AssigneeId: user.Id})
+	hours := NewDurationFromSeconds(3600)
+	unauthorized := NewClient(server.BaseURL(), "apikey", "wrong")
+
+	tests := []struct {
+		name       string
+		call       func() error
+		code       int
+		identifier string
+		message    string
+	}{
+		{"missing work package", func() error { _, err := client.GetWorkPackage(99); return err }, http.StatusNotFound, "NotFound", "could not be found"},
+		{"missing time entry", func() error { _, err := client.GetTimeEntry(99); return err }, http.StatusNotFound, "NotFound", "could not be found"},
+		{"update missing time entry", func() error { return client.UpdateTimeEntry(99, map[string]interface{}{"hours": "PT1H"}) }, http.StatusNotFound, "NotFound", ""},
+		{"delete missing time entry", func() error { return client.DeleteTimeEntry(99) }, http.StatusNotFound, "NotFound", ""},
+		{"invalid work package", func() error {
+			return client.CreateTimeEntry(NewTimeEntryRequest(user.Id, 99, 3, &hours, "", "2024-05-06"))
+		}, http.StatusUnprocessableEntity, "PropertyConstraintViolation", "Work package is invalid."},
+		{"several violations", func() error {
+			return client.CreateTimeEntry(NewTimeEntryRequest(user.Id, 99, 99, &hours, "", "2024-05-06"))
+		}, http.StatusUnprocessableEntity, "MultipleErrors", "Activity is invalid."},
+		{"wrong API key", func() error { _, err := unauthorized.GetCurrentUser(); return err }, http.StatusUnauthorized, "Unauthenticated", "correct credentials"},
+	}
+	for _, test := range tests {
+		err := test.call()
+		var statusErr *StatusError
+		if !errors.As(err, &statusErr) {
+			t.Errorf("%s: error = %v, want a status error", test.name, err)
+			continue
+		}
+		if statusErr.Code != test.code || !strings.Contains(statusErr.Body, "urn:openproject-org:api:v3:errors:"+test.identifier) || !strings.Contains(statusErr.Body, test.message) {
+			t.Errorf("%s: error = %v, want %d %s %q", test.name, statusErr, test.code, test.identifier, test.message)
+		}
+		if isOffline(err) {
+			t.Errorf("%s: error %v is taken for being offline", test.name, err)
+		}
+	}
+	if got := len(server.TimeEntries()); got != 0 {
+		t.Errorf("%d time entries created by invalid requests", got)
+	}
+	if _, err := client.GetWorkPackage(wp.Id); err != nil {
+		t.Errorf("GetWorkPackage after the errors: %v", err)
+	}
+}
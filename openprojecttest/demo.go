@@ -0,0 +1,83 @@
+package openprojecttest
+
+import "time"
+
+// NewDemoServer starts a server with sample data for demos and screenshots: a few projects, open and closed work
+// packages assigned to the current user, a colleague's work, and two weeks of time entries up to today.
+func NewDemoServer(today time.Time) *Server {
+	s := NewServer()
+	s.InstanceName = "lazyop demo"
+
+	me := s.AddUser(User{Login: "demo", Name: "Dana Demo", Email: "dana@example.com"})
+	colleague := s.AddUser(User{Login: "sam", Name: "Sam Sample", Email: "sam@example.com"})
+
+	website := s.AddProject(Project{Name: "Website relaunch"})
+	app := s.AddProject(Project{Name: "Mobile app"})
+	internal := s.AddProject(Project{Name: "Internal"})
+
+	day := func(offset int) time.Time {
+		return today.AddDate(0, 0, offset)
+	}
+	date := func(offset int) string {
+		return day(offset).Format("2006-01-02")
+	}
+	// Statuses and activities of `NewServer`.
+	const (
+		statusNew, statusInProgress, statusOnHold, statusClosed  = 1, 2, 3, 4
+		management, specification, development, testing, support = 1, 2, 3, 4, 5
+	)
+
+	workPackages := []WorkPackage{
+		{Subject: "Design the new landing page", ProjectId: website.Id, StatusId: statusInProgress, AssigneeId: me.Id, StartDate: date(-12), DueDate: date(5), EstimatedTime: "PT24H", PercentageDone: 60, UpdatedAt: day(-1)},
+		{Subject: "Migrate the blog to the new CMS", ProjectId: website.Id, StatusId: statusNew, AssigneeId: me.Id, StartDate: date(2), DueDate: date(16), EstimatedTime: "PT16H", UpdatedAt: day(-3)},
+		{Subject: "Fix the login crash on Android 14", ProjectId: app.Id, StatusId: statusInProgress, AssigneeId: me.Id, StartDate: date(-4), DueDate: date(1), EstimatedTime: "PT6H", PercentageDone: 30, UpdatedAt: day(0)},
+		{Subject: "Push notifications for reminders", ProjectId: app.Id, StatusId: statusOnHold, AssigneeId: me.Id, EstimatedTime: "PT12H", UpdatedAt: day(-8)},
+		{Subject: "Weekly team meeting", ProjectId: internal.Id, StatusId: statusInProgress, AssigneeId: me.Id, UpdatedAt: day(-14)},
+		{Subject: "Customer support rotation", ProjectId: internal.Id, StatusId: statusInProgress, AssigneeId: me.Id, UpdatedAt: day(-10)},
+		{Subject: "Set up the staging environment", ProjectId: website.Id, StatusId: statusClosed, AssigneeId: me.Id, PercentageDone: 100, UpdatedAt: day(-9)},
+		{Subject: "App store screenshots", ProjectId: app.Id, StatusId: statusNew, AssigneeId: colleague.Id, UpdatedAt: day(-2)},
+	}
+	for i := range workPackages {
+		workPackages[i].Description = "Created for the lazyop demo."
+		workPackages[i].CreatedAt = day(-30)
+		workPackages[i] = s.AddWorkPackage(workPackages[i])
+	}
+	landingPage, blog, crash, notifications, meeting, rotation, staging := workPackages[0].Id, workPackages[1].Id, workPackages[2].Id, workPackages[3].Id, workPackages[4].Id, workPackages[5].Id, workPackages[6].Id
+
+	type entry struct {
+		workPackageId, activityId int
+		hours, comment            string
+	}
+	// The usual day of work, by weekday, from Monday.
+	week := [][]entry{
+		{{meeting, management, "PT1H", "Weekly planning"}, {landingPage, development, "PT5H", "Hero section"}, {rotation, support, "PT1H30M", "Tickets"}},
+		{{landingPage, development, "PT4H", "Responsive layout"}, {staging, development, "PT2H", "Deployment pipeline"}, {landingPage, specification, "PT1H30M", ""}},
+		{{crash, development, "PT3H", "Reproduce the crash"}, {crash, testing, "PT2H", "Regression tests"}, {notifications, specification, "PT2H", "Spec review"}},
+		{{landingPage, development, "PT6H", "Contact form"}, {rotation, support, "PT2H", "Tickets"}},
+		{{blog, specification, "PT2H", "Content inventory"}, {crash, development, "PT4H", "Fix null session"}, {meeting, management, "PT30M", "Retrospective"}},
+	}
+	for offset := -13; offset <= 0; offset++ {
+		weekday := day(offset).Weekday()
+		if weekday == time.Saturday || weekday == time.Sunday {
+			continue
+		}
+		entries := week[weekday-time.Monday]
+		if offset == 0 {
+			// Today's work isn't over yet.
+			entries = entries[:1]
+		}
+		for _, e := range entries {
+			s.AddTimeEntry(TimeEntry{
+				WorkPackageId: e.workPackageId,
+				UserId:        me.Id,
+				ActivityId:    e.activityId,
+				Hours:         e.hours,
+				SpentOn:       date(offset),
+				Comment:       e.comment,
+				CreatedAt:     day(offset),
+			})
+		}
+	}
+	s.AddTimeEntry(TimeEntry{WorkPackageId: workPackages[7].Id, UserId: colleague.Id, ActivityId: development, Hours: "PT3H", SpentOn: date(-1), Comment: "First drafts", CreatedAt: day(-1)})
+	return s
+}
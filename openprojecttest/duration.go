@@ -0,0 +1,46 @@
+package openprojecttest
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// durationPattern matches the ISO 8601 durations OpenProject accepts, e.g. `PT1H30M` or `P1DT2H`.
+var durationPattern = regexp.MustCompile(`^P(?:(\d+(?:\.\d+)?)D)?(?:T(?:(\d+(?:\.\d+)?)H)?(?:(\d+(?:\.\d+)?)M)?(?:(\d+(?:\.\d+)?)S)?)?$`)
+
+// parseDuration returns the number of seconds of an ISO 8601 duration.
+func parseDuration(duration string) (int64, bool) {
+	match := durationPattern.FindStringSubmatch(duration)
+	if match == nil || duration == "P" || strings.HasSuffix(duration, "T") {
+		return 0, false
+	}
+	seconds := 0.0
+	for i, unit := range []float64{24 * 3600, 3600, 60, 1} {
+		if match[i+1] != "" {
+			value, _ := strconv.ParseFloat(match[i+1], 64)
+			seconds += value * unit
+		}
+	}
+	return int64(math.Round(seconds)), true
+}
+
+// formatDuration returns a number of seconds as an ISO 8601 duration, the way OpenProject does.
+func formatDuration(seconds int64) string {
+	if seconds == 0 {
+		return "PT0S"
+	}
+	duration := "PT"
+	if hours := seconds / 3600; hours > 0 {
+		duration += fmt.Sprintf("%dH", hours)
+	}
+	if minutes := seconds % 3600 / 60; minutes > 0 {
+		duration += fmt.Sprintf("%dM", minutes)
+	}
+	if seconds%60 > 0 {
+		duration += fmt.Sprintf("%dS", seconds%60)
+	}
+	return duration
+}
@@ -0,0 +1,298 @@
+package openprojecttest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultPageSize and maxPageSize are the page sizes of a new OpenProject instance.
+	defaultPageSize = 20
+	maxPageSize     = 1000
+)
+
+// record is a resource of a collection: the values its filters and sort criteria apply to, by name, and its
+// representation.
+type record struct {
+	values map[string]string
+	json   interface{}
+}
+
+// filter is a filter of a query, e.g. `{"status":{"operator":"o","values":[]}}`.
+type filter struct {
+	name     string
+	operator string
+	values   []string
+}
+
+// sortCriterion is a sort criterion of a query, e.g. `["spent_on", "asc"]`.
+type sortCriterion struct {
+	name string
+	desc bool
+}
+
+// query holds the parameters of a request for a collection.
+type query struct {
+	filters  []filter
+	sortBy   []sortCriterion
+	pageSize int
+	offset   int
+}
+
+// queryError is an invalid query, reported with a 400 status.
+type queryError struct {
+	message string
+}
+
+func (e *queryError) Error() string {
+	return e.message
+}
+
+// parseQuery reads the `filters`, `sortBy`, `pageSize` and `offset` parameters of a request. fields are the names
+// that can be filtered and sorted by; sortBy is used if the request has none. The `select` and `groupBy` parameters
+// are ignored: the elements are always complete and not grouped.
+func parseQuery(params url.Values, fields []string, sortBy []sortCriterion) (*query, error) {
+	q := &query{sortBy: sortBy, pageSize: defaultPageSize, offset: 1}
+	known := make(map[string]bool)
+	for _, field := range fields {
+		known[field] = true
+	}
+
+	if raw := params.Get("filters"); raw != "" {
+		var filters []map[string]struct {
+			Operator string   `json:"operator"`
+			Values   []string `json:"values"`
+		}
+		if err := json.Unmarshal([]byte(raw), &filters); err != nil {
+			return nil, &queryError{fmt.Sprintf("Filters are not valid JSON: %v.", err)}
+		}
+		for _, f := range filters {
+			for name, condition := range f {
+				if !known[name] {
+					return nil, &queryError{fmt.Sprintf("Filter %s does not exist.", name)}
+				}
+				if !knownOperators[condition.Operator] {
+					return nil, &queryError{fmt.Sprintf("Operator %q is not valid for filter %s.", condition.Operator, name)}
+				}
+				if (condition.Operator == "o" || condition.Operator == "c") && name != "status" {
+					return nil, &queryError{fmt.Sprintf("Operator %q is only valid for the status filter.", condition.Operator)}
+				}
+				q.filters = append(q.filters, filter{name: name, operator: condition.Operator, values: condition.Values})
+			}
+		}
+	}
+
+	if raw := params.Get("sortBy"); raw != "" {
+		var criteria [][]string
+		if err := json.Unmarshal([]byte(raw), &criteria); err != nil {
+			return nil, &queryError{fmt.Sprintf("Sort criteria are not valid JSON: %v.", err)}
+		}
+		q.sortBy = nil
+		for _, criterion := range criteria {
+			if len(criterion) != 2 || !known[criterion[0]] || (criterion[1] != "asc" && criterion[1] != "desc") {
+				return nil, &queryError{fmt.Sprintf("Sort criterion %v is not valid.", criterion)}
+			}
+			q.sortBy = append(q.sortBy, sortCriterion{name: criterion[0], desc: criterion[1] == "desc"})
+		}
+	}
+
+	var err error
+	if q.pageSize, err = intParam(params, "pageSize", defaultPageSize); err != nil {
+		return nil, err
+	}
+	if q.pageSize > maxPageSize {
+		q.pageSize = maxPageSize
+	}
+	if q.offset, err = intParam(params, "offset", 1); err != nil {
+		return nil, err
+	}
+	return q, nil
+}
+
+// intParam returns a positive integer parameter.
+func intParam(params url.Values, name string, fallback int) (int, error) {
+	raw := params.Get(name)
+	if raw == "" {
+		return fallback, nil
+	}
+	value, err := strconv.Atoi(raw)
+	if err != nil || value < 1 {
+		return 0, &queryError{fmt.Sprintf("%s must be a positive integer.", name)}
+	}
+	return value, nil
+}
+
+// knownOperators are the filter operators the server understands.
+var knownOperators = map[string]bool{
+	"=": true, "!": true, "o": true, "c": true, "~": true, "!~": true, "*": true, "!*": true, "<>d": true, "=d": true,
+}
+
+// run filters, sorts and paginates records, and returns the page and the number of records matching the filters.
+func (s *Server) run(q *query, records []record) ([]interface{}, int) {
+	var matching []record
+	for _, r := range records {
+		if s.matches(r, q.filters) {
+			matching = append(matching, r)
+		}
+	}
+	sort.SliceStable(matching, func(i, j int) bool {
+		for _, criterion := range q.sortBy {
+			c := compareValues(criterion.name, matching[i].values[criterion.name], matching[j].values[criterion.name])
+			if c != 0 {
+				return (c < 0) != criterion.desc
+			}
+		}
+		return false
+	})
+
+	elements := []interface{}{}
+	start := (q.offset - 1) * q.pageSize
+	for i := start; i < len(matching) && i < start+q.pageSize; i++ {
+		elements = append(elements, matching[i].json)
+	}
+	return elements, len(matching)
+}
+
+// matches reports whether a record matches all the filters.
+func (s *Server) matches(r record, filters []filter) bool {
+	for _, f := range filters {
+		if !s.matchesFilter(r.values[f.name], f) {
+			return false
+		}
+	}
+	return true
+}
+
+func (s *Server) matchesFilter(value string, f filter) bool {
+	first := ""
+	if len(f.values) > 0 {
+		first = f.values[0]
+	}
+	switch f.operator {
+	case "=":
+		return s.contains(f.values, value)
+	case "!":
+		return !s.contains(f.values, value)
+	case "o", "c":
+		id, _ := strconv.Atoi(value)
+		status := s.status(id)
+		return status != nil && status.IsClosed == (f.operator == "c")
+	case "~":
+		return strings.Contains(strings.ToLower(value), strings.ToLower(first))
+	case "!~":
+		return !strings.Contains(strings.ToLower(value), strings.ToLower(first))
+	case "*":
+		return value != ""
+	case "!*":
+		return value == ""
+	case "=d":
+		return value != "" && inRange(value, first, first)
+	case "<>d":
+		second := ""
+		if len(f.values) > 1 {
+			second = f.values[1]
+		}
+		return value != "" && inRange(value, first, second)
+	}
+	return false
+}
+
+// contains reports whether a value is one of the values of a filter, where `me` stands for the current user.
+func (s *Server) contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == "me" {
+			v = strconv.Itoa(s.currentUser)
+		}
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// inRange reports whether a date or time is between two dates or times, inclusive. An empty bound is open.
+func inRange(value, from, to string) bool {
+	t, ok := parseTime(value, false)
+	if !ok {
+		return false
+	}
+	if from != "" {
+		if start, ok := parseTime(from, false); !ok || t.Before(start) {
+			return false
+		}
+	}
+	if to != "" {
+		if end, ok := parseTime(to, true); !ok || t.After(end) {
+			return false
+		}
+	}
+	return true
+}
+
+// parseTime parses a date or an RFC 3339 time. A date stands for its first second, or its last one if end is set.
+func parseTime(value string, end bool) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	t, err := time.Parse("2006-01-02", value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	if end {
+		t = t.Add(24*time.Hour - time.Second)
+	}
+	return t, true
+}
+
+// compareValues compares the values of a field of two records: numerically for IDs, as text otherwise.
+func compareValues(name, a, b string) int {
+	if name == "id" {
+		x, _ := strconv.Atoi(a)
+		y, _ := strconv.Atoi(b)
+		return x - y
+	}
+	return strings.Compare(a, b)
+}
+
+// collectionJSON returns a page of a collection, with the links to the next and previous pages.
+func collectionJSON(r *http.Request, elements []interface{}, total, pageSize, offset int) map[string]interface{} {
+	links := map[string]interface{}{"self": link(r.URL.RequestURI(), "")}
+	pageLink := func(offset int) map[string]interface{} {
+		params := r.URL.Query()
+		params.Set("offset", strconv.Itoa(offset))
+		params.Set("pageSize", strconv.Itoa(pageSize))
+		return link(r.URL.Path+"?"+params.Encode(), "")
+	}
+	if offset > 1 {
+		links["previousByOffset"] = pageLink(offset - 1)
+	}
+	if offset*pageSize < total {
+		links["nextByOffset"] = pageLink(offset + 1)
+	}
+	return map[string]interface{}{
+		"_type":     "Collection",
+		"total":     total,
+		"count":     len(elements),
+		"pageSize":  pageSize,
+		"offset":    offset,
+		"_embedded": map[string]interface{}{"elements": elements},
+		"_links":    links,
+	}
+}
+
+// writeCollection writes the page of a collection requested by a query.
+func (s *Server) writeCollection(w http.ResponseWriter, r *http.Request, fields []string, sortBy []sortCriterion, records []record) {
+	q, err := parseQuery(r.URL.Query(), fields, sortBy)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidQuery", err.Error())
+		return
+	}
+	elements, total := s.run(q, records)
+	writeJSON(w, http.StatusOK, collectionJSON(r, elements, total, q.pageSize, q.offset))
+}
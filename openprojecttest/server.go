@@ -0,0 +1,619 @@
+// Package openprojecttest provides a stand-in OpenProject server for integration tests and demos. It serves the parts
+// of the API v3 that lazyop uses, in HAL JSON, with the filters, sorting, pagination, validation, errors and lock
+// versions of the real thing, from data kept in memory.
+package openprojecttest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// APIKey is the API key the server accepts, with basic authentication as the user `apikey` or as a bearer token.
+const APIKey = "openprojecttest"
+
+// apiPrefix is the path of the API.
+const apiPrefix = "/api/v3/"
+
+// User is a user of the instance.
+type User struct {
+	Id    int
+	Login string
+	Name  string
+	Email string
+}
+
+// Project is a project of the instance.
+type Project struct {
+	Id         int
+	Identifier string
+	Name       string
+}
+
+// Status is a work package status.
+type Status struct {
+	Id        int
+	Name      string
+	IsClosed  bool
+	IsDefault bool
+}
+
+// Activity is a time entry activity.
+type Activity struct {
+	Id        int
+	Name      string
+	IsDefault bool
+}
+
+// WorkPackage is a work package. Dates are `YYYY-MM-DD` and the estimated time an ISO 8601 duration; empty values are
+// null in the API.
+type WorkPackage struct {
+	Id             int
+	Subject        string
+	Description    string
+	ProjectId      int
+	StatusId       int
+	AssigneeId     int
+	StartDate      string
+	DueDate        string
+	EstimatedTime  string
+	PercentageDone int
+	LockVersion    int
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+}
+
+// TimeEntry is a time entry. Hours is an ISO 8601 duration and SpentOn is `YYYY-MM-DD`.
+type TimeEntry struct {
+	Id            int
+	WorkPackageId int
+	UserId        int
+	ActivityId    int
+	Hours         string
+	SpentOn       string
+	Comment       string
+	CreatedAt     time.Time
+	UpdatedAt     time.Time
+
+	// Ongoing is set for the time entry of a running timer.
+	Ongoing bool
+}
+
+// Server is a running stand-in OpenProject server. Its base URL for clients is `URL + "/api/v3/"`.
+type Server struct {
+	*httptest.Server
+
+	// InstanceName is the name of the instance returned by the root resource.
+	InstanceName string
+
+	mu          sync.Mutex
+	now         func() time.Time
+	currentUser int
+	lastIds     map[string]int
+
+	users        []*User
+	projects     []*Project
+	statuses     []*Status
+	activities   []*Activity
+	workPackages []*WorkPackage
+	timeEntries  []*TimeEntry
+}
+
+// NewServer starts a server with the statuses and activities of a new OpenProject instance, and no users, projects,
+// work packages or time entries. The caller must call Close when done.
+func NewServer() *Server {
+	s := &Server{InstanceName: "OpenProject", now: time.Now, lastIds: make(map[string]int)}
+	for _, status := range []Status{
+		{Name: "New", IsDefault: true},
+		{Name: "In progress"},
+		{Name: "On hold"},
+		{Name: "Closed", IsClosed: true},
+		{Name: "Rejected", IsClosed: true},
+	} {
+		s.AddStatus(status)
+	}
+	for _, activity := range []Activity{
+		{Name: "Management"},
+		{Name: "Specification"},
+		{Name: "Development", IsDefault: true},
+		{Name: "Testing"},
+		{Name: "Support"},
+		{Name: "Other"},
+	} {
+		s.AddActivity(activity)
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.handle))
+	return s
+}
+
+// BaseURL returns the base URL of the API, as configured in lazyop.
+func (s *Server) BaseURL() string {
+	return s.URL + apiPrefix
+}
+
+// SetClock sets the function returning the current time, used for `createdAt` and `updatedAt`.
+func (s *Server) SetClock(now func() time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.now = now
+}
+
+// SetCurrentUser sets the user the API key belongs to. It is the first user added by default.
+func (s *Server) SetCurrentUser(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.currentUser = id
+}
+
+// nextId returns the ID of a new resource of a kind. The lock must be held.
+func (s *Server) nextId(kind string, id int) int {
+	if id == 0 {
+		id = s.lastIds[kind] + 1
+	}
+	if id > s.lastIds[kind] {
+		s.lastIds[kind] = id
+	}
+	return id
+}
+
+// AddUser adds a user, and returns it with its ID if it had none.
+func (s *Server) AddUser(user User) User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	user.Id = s.nextId("users", user.Id)
+	s.users = append(s.users, &user)
+	if s.currentUser == 0 {
+		s.currentUser = user.Id
+	}
+	return user
+}
+
+// AddProject adds a project, and returns it with its ID if it had none.
+func (s *Server) AddProject(project Project) Project {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	project.Id = s.nextId("projects", project.Id)
+	if project.Identifier == "" {
+		project.Identifier = strings.ToLower(strings.ReplaceAll(project.Name, " ", "-"))
+	}
+	s.projects = append(s.projects, &project)
+	return project
+}
+
+// AddStatus adds a work package status, and returns it with its ID if it had none.
+func (s *Server) AddStatus(status Status) Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	status.Id = s.nextId("statuses", status.Id)
+	s.statuses = append(s.statuses, &status)
+	return status
+}
+
+// AddActivity adds a time entry activity, and returns it with its ID if it had none.
+func (s *Server) AddActivity(activity Activity) Activity {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	activity.Id = s.nextId("activities", activity.Id)
+	s.activities = append(s.activities, &activity)
+	return activity
+}
+
+// AddWorkPackage adds a work package, and returns it with its ID, default status and timestamps if it had none.
+func (s *Server) AddWorkPackage(wp WorkPackage) WorkPackage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	wp.Id = s.nextId("work_packages", wp.Id)
+	if wp.StatusId == 0 {
+		wp.StatusId = s.defaultStatus().Id
+	}
+	s.stamp(&wp.CreatedAt, &wp.UpdatedAt)
+	s.workPackages = append(s.workPackages, &wp)
+	return wp
+}
+
+// AddTimeEntry adds a time entry without validating it, and returns it with its ID, the current user, the default
+// activity and timestamps if it had none.
+func (s *Server) AddTimeEntry(te TimeEntry) TimeEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	te.Id = s.nextId("time_entries", te.Id)
+	if te.UserId == 0 {
+		te.UserId = s.currentUser
+	}
+	if te.ActivityId == 0 {
+		te.ActivityId = s.defaultActivity().Id
+	}
+	s.stamp(&te.CreatedAt, &te.UpdatedAt)
+	s.timeEntries = append(s.timeEntries, &te)
+	return te
+}
+
+// WorkPackages returns a copy of the work packages, in the order they were added.
+func (s *Server) WorkPackages() []WorkPackage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	workPackages := make([]WorkPackage, 0, len(s.workPackages))
+	for _, wp := range s.workPackages {
+		workPackages = append(workPackages, *wp)
+	}
+	return workPackages
+}
+
+// TimeEntries returns a copy of the time entries, in the order they were added.
+func (s *Server) TimeEntries() []TimeEntry {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	timeEntries := make([]TimeEntry, 0, len(s.timeEntries))
+	for _, te := range s.timeEntries {
+		timeEntries = append(timeEntries, *te)
+	}
+	return timeEntries
+}
+
+// stamp sets the creation and update times that are not set yet. The lock must be held.
+func (s *Server) stamp(createdAt, updatedAt *time.Time) {
+	now := s.now().UTC().Truncate(time.Second)
+	if createdAt.IsZero() {
+		*createdAt = now
+	}
+	if updatedAt.IsZero() {
+		*updatedAt = *createdAt
+	}
+}
+
+// route maps a method and a path of the API, where `{id}` stands for a numeric ID, to a handler.
+type route struct {
+	method  string
+	pattern string
+	handle  func(s *Server, w http.ResponseWriter, r *http.Request, id int)
+}
+
+var routes = []route{
+	{"GET", "", (*Server).getRoot},
+	{"GET", "users/me", (*Server).getCurrentUser},
+	{"GET", "users/{id}", (*Server).getUser},
+	{"GET", "projects/{id}", (*Server).getProject},
+	{"GET", "statuses", (*Server).listStatuses},
+	{"GET", "statuses/{id}", (*Server).getStatus},
+	{"GET", "time_entries/activities/{id}", (*Server).getActivity},
+	{"GET", "work_packages", (*Server).listWorkPackages},
+	{"GET", "work_packages/{id}", (*Server).getWorkPackage},
+	{"PATCH", "work_packages/{id}", (*Server).updateWorkPackage},
+	{"POST", "work_packages/{id}/form", (*Server).workPackageForm},
+	{"GET", "time_entries", (*Server).listTimeEntries},
+	{"POST", "time_entries", (*Server).createTimeEntry},
+	{"POST", "time_entries/form", (*Server).timeEntryForm},
+	{"GET", "time_entries/{id}", (*Server).getTimeEntry},
+	{"PATCH", "time_entries/{id}", (*Server).updateTimeEntry},
+	{"DELETE", "time_entries/{id}", (*Server).deleteTimeEntry},
+	{"POST", "time_entries/{id}/form", (*Server).timeEntryForm},
+}
+
+// match returns the ID in a path if it matches a pattern.
+func (rt route) match(path string) (int, bool) {
+	segments, patternSegments := strings.Split(path, "/"), strings.Split(rt.pattern, "/")
+	if len(segments) != len(patternSegments) {
+		return 0, false
+	}
+	id := 0
+	for i, segment := range patternSegments {
+		if segment != "{id}" {
+			if segments[i] != segment {
+				return 0, false
+			}
+			continue
+		}
+		var err error
+		if id, err = strconv.Atoi(segments[i]); err != nil {
+			return 0, false
+		}
+	}
+	return id, true
+}
+
+func (s *Server) handle(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !strings.HasPrefix(r.URL.Path+"/", apiPrefix) {
+		writeNotFound(w)
+		return
+	}
+	if !s.authenticated(r) {
+		writeError(w, http.StatusUnauthorized, "Unauthenticated", "You did not provide the correct credentials.")
+		return
+	}
+	path := strings.Trim(strings.TrimPrefix(r.URL.Path+"/", apiPrefix), "/")
+	allowed := false
+	for _, rt := range routes {
+		id, ok := rt.match(path)
+		if !ok {
+			continue
+		}
+		if rt.method == r.Method {
+			rt.handle(s, w, r, id)
+			return
+		}
+		allowed = true
+	}
+	if allowed {
+		writeError(w, http.StatusMethodNotAllowed, "NotAllowed", "The requested HTTP method is not allowed for this resource.")
+		return
+	}
+	writeNotFound(w)
+}
+
+// authenticated reports whether a request carries the API key.
+func (s *Server) authenticated(r *http.Request) bool {
+	header := r.Header.Get("Authorization")
+	if strings.HasPrefix(header, "Bearer ") {
+		return strings.TrimPrefix(header, "Bearer ") == APIKey
+	}
+	username, password, ok := r.BasicAuth()
+	return ok && username == "apikey" && password == APIKey
+}
+
+func (s *Server) getRoot(w http.ResponseWriter, _ *http.Request, _ int) {
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"_type":        "Root",
+		"instanceName": s.InstanceName,
+		"coreVersion":  "13.0.0",
+		"_links": map[string]interface{}{
+			"self":     link(strings.TrimSuffix(apiPrefix, "/"), ""),
+			"user":     s.userLink(s.currentUser),
+			"statuses": link(apiPrefix+"statuses", ""),
+		},
+	})
+}
+
+func (s *Server) getCurrentUser(w http.ResponseWriter, r *http.Request, _ int) {
+	s.getUser(w, r, s.currentUser)
+}
+
+func (s *Server) getUser(w http.ResponseWriter, _ *http.Request, id int) {
+	user := s.user(id)
+	if user == nil {
+		writeNotFound(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.userJSON(user))
+}
+
+func (s *Server) getProject(w http.ResponseWriter, _ *http.Request, id int) {
+	project := s.project(id)
+	if project == nil {
+		writeNotFound(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"_type":      "Project",
+		"id":         project.Id,
+		"identifier": project.Identifier,
+		"name":       project.Name,
+		"_links":     map[string]interface{}{"self": s.projectLink(project.Id)},
+	})
+}
+
+func (s *Server) listStatuses(w http.ResponseWriter, r *http.Request, _ int) {
+	elements := make([]interface{}, 0, len(s.statuses))
+	for _, status := range s.statuses {
+		elements = append(elements, s.statusJSON(status))
+	}
+	writeJSON(w, http.StatusOK, collectionJSON(r, elements, len(elements), len(elements), 1))
+}
+
+func (s *Server) getStatus(w http.ResponseWriter, _ *http.Request, id int) {
+	status := s.status(id)
+	if status == nil {
+		writeNotFound(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.statusJSON(status))
+}
+
+func (s *Server) getActivity(w http.ResponseWriter, _ *http.Request, id int) {
+	activity := s.activity(id)
+	if activity == nil {
+		writeNotFound(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.activityJSON(activity))
+}
+
+func (s *Server) user(id int) *User {
+	for _, user := range s.users {
+		if user.Id == id {
+			return user
+		}
+	}
+	return nil
+}
+
+func (s *Server) project(id int) *Project {
+	for _, project := range s.projects {
+		if project.Id == id {
+			return project
+		}
+	}
+	return nil
+}
+
+func (s *Server) status(id int) *Status {
+	for _, status := range s.statuses {
+		if status.Id == id {
+			return status
+		}
+	}
+	return nil
+}
+
+func (s *Server) defaultStatus() *Status {
+	for _, status := range s.statuses {
+		if status.IsDefault {
+			return status
+		}
+	}
+	return s.statuses[0]
+}
+
+func (s *Server) activity(id int) *Activity {
+	for _, activity := range s.activities {
+		if activity.Id == id {
+			return activity
+		}
+	}
+	return nil
+}
+
+func (s *Server) defaultActivity() *Activity {
+	for _, activity := range s.activities {
+		if activity.IsDefault {
+			return activity
+		}
+	}
+	return s.activities[0]
+}
+
+func (s *Server) userJSON(user *User) map[string]interface{} {
+	return map[string]interface{}{
+		"_type":  "User",
+		"id":     user.Id,
+		"login":  user.Login,
+		"name":   user.Name,
+		"email":  user.Email,
+		"status": "active",
+		"_links": map[string]interface{}{"self": s.userLink(user.Id)},
+	}
+}
+
+func (s *Server) statusJSON(status *Status) map[string]interface{} {
+	return map[string]interface{}{
+		"_type":     "Status",
+		"id":        status.Id,
+		"name":      status.Name,
+		"isClosed":  status.IsClosed,
+		"isDefault": status.IsDefault,
+		"_links":    map[string]interface{}{"self": s.statusLink(status.Id)},
+	}
+}
+
+func (s *Server) activityJSON(activity *Activity) map[string]interface{} {
+	return map[string]interface{}{
+		"_type":   "TimeEntriesActivity",
+		"id":      activity.Id,
+		"name":    activity.Name,
+		"default": activity.IsDefault,
+		"_links":  map[string]interface{}{"self": s.activityLink(activity.Id)},
+	}
+}
+
+// link returns a HAL link.
+func link(href, title string) map[string]interface{} {
+	l := map[string]interface{}{"href": href}
+	if title != "" {
+		l["title"] = title
+	}
+	return l
+}
+
+// nullLink is a link to nothing, e.g. the assignee of an unassigned work package.
+func nullLink() map[string]interface{} {
+	return map[string]interface{}{"href": nil}
+}
+
+func (s *Server) userLink(id int) map[string]interface{} {
+	if user := s.user(id); user != nil {
+		return link(fmt.Sprintf("%susers/%d", apiPrefix, id), user.Name)
+	}
+	return nullLink()
+}
+
+func (s *Server) projectLink(id int) map[string]interface{} {
+	if project := s.project(id); project != nil {
+		return link(fmt.Sprintf("%sprojects/%d", apiPrefix, id), project.Name)
+	}
+	return nullLink()
+}
+
+func (s *Server) statusLink(id int) map[string]interface{} {
+	if status := s.status(id); status != nil {
+		return link(fmt.Sprintf("%sstatuses/%d", apiPrefix, id), status.Name)
+	}
+	return nullLink()
+}
+
+func (s *Server) activityLink(id int) map[string]interface{} {
+	if activity := s.activity(id); activity != nil {
+		return link(fmt.Sprintf("%stime_entries/activities/%d", apiPrefix, id), activity.Name)
+	}
+	return nullLink()
+}
+
+func (s *Server) workPackageLink(id int) map[string]interface{} {
+	if wp := s.workPackage(id); wp != nil {
+		return link(fmt.Sprintf("%swork_packages/%d", apiPrefix, id), wp.Subject)
+	}
+	return nullLink()
+}
+
+// idFromHref returns the numeric ID at the end of a link to a resource of a kind, e.g. `/api/v3/statuses/2`, or 0.
+func idFromHref(href, kind string) int {
+	prefix := apiPrefix + kind + "/"
+	if !strings.HasPrefix(href, prefix) {
+		return 0
+	}
+	id, err := strconv.Atoi(strings.TrimPrefix(href, prefix))
+	if err != nil {
+		return 0
+	}
+	return id
+}
+
+// formattable returns a formattable text, as used for descriptions and comments.
+func formattable(format, raw string) map[string]interface{} {
+	html := ""
+	if raw != "" {
+		html = "<p>" + strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(raw) + "</p>"
+	}
+	return map[string]interface{}{"format": format, "raw": raw, "html": html}
+}
+
+// nullable returns nil for an empty value, which is encoded as null.
+func nullable(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
+func timestamp(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func writeJSON(w http.ResponseWriter, status int, value interface{}) {
+	w.Header().Set("Content-Type", "application/hal+json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(value)
+}
+
+// writeError writes an error response. The identifier is the last part of the `errorIdentifier` URN.
+func writeError(w http.ResponseWriter, status int, identifier, message string) {
+	writeJSON(w, status, errorJSON(identifier, message))
+}
+
+func errorJSON(identifier, message string) map[string]interface{} {
+	return map[string]interface{}{
+		"_type":           "Error",
+		"errorIdentifier": "urn:openproject-org:api:v3:errors:" + identifier,
+		"message":         message,
+	}
+}
+
+func writeNotFound(w http.ResponseWriter) {
+	writeError(w, http.StatusNotFound, "NotFound", "The requested resource could not be found.")
+}
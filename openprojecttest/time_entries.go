@@ -0,0 +1,330 @@
+package openprojecttest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// timeEntryFields are the names the time entries can be filtered and sorted by.
+var timeEntryFields = []string{"id", "user", "work_package", "project", "activity", "spent_on", "created_at", "updated_at", "comment"}
+
+// timeEntryInput is the body of a request to create or update a time entry. Absent attributes are not changed.
+type timeEntryInput struct {
+	Comment *struct {
+		Raw string `json:"raw"`
+	} `json:"comment"`
+	Hours   *string `json:"hours"`
+	SpentOn *string `json:"spentOn"`
+	Links   struct {
+		WorkPackage *hrefLink `json:"workPackage"`
+		Activity    *hrefLink `json:"activity"`
+		User        *hrefLink `json:"user"`
+	} `json:"_links"`
+}
+
+// hrefLink is a link in the body of a request.
+type hrefLink struct {
+	Href *string `json:"href"`
+}
+
+// id returns the ID of the resource of a kind a link points to, 0 for a null link, or -1 for a link to something
+// else.
+func (l *hrefLink) id(kind string) int {
+	if l.Href == nil || *l.Href == "" {
+		return 0
+	}
+	if id := idFromHref(*l.Href, kind); id > 0 {
+		return id
+	}
+	return -1
+}
+
+// violation is a property of a resource that fails validation.
+type violation struct {
+	attribute string
+	message   string
+}
+
+func (s *Server) timeEntry(id int) *TimeEntry {
+	for _, te := range s.timeEntries {
+		if te.Id == id {
+			return te
+		}
+	}
+	return nil
+}
+
+func (s *Server) timeEntryJSON(te *TimeEntry) map[string]interface{} {
+	self := fmt.Sprintf("%stime_entries/%d", apiPrefix, te.Id)
+	projectId := 0
+	if wp := s.workPackage(te.WorkPackageId); wp != nil {
+		projectId = wp.ProjectId
+	}
+	return map[string]interface{}{
+		"_type":     "TimeEntry",
+		"id":        te.Id,
+		"comment":   formattable("plain", te.Comment),
+		"spentOn":   te.SpentOn,
+		"hours":     te.Hours,
+		"ongoing":   te.Ongoing,
+		"createdAt": timestamp(te.CreatedAt),
+		"updatedAt": timestamp(te.UpdatedAt),
+		"_links": map[string]interface{}{
+			"self":              link(self, ""),
+			"updateImmediately": map[string]interface{}{"href": self, "method": "patch"},
+			"delete":            map[string]interface{}{"href": self, "method": "delete"},
+			"project":           s.projectLink(projectId),
+			"workPackage":       s.workPackageLink(te.WorkPackageId),
+			"user":              s.userLink(te.UserId),
+			"activity":          s.activityLink(te.ActivityId),
+		},
+	}
+}
+
+func (s *Server) listTimeEntries(w http.ResponseWriter, r *http.Request, _ int) {
+	records := make([]record, 0, len(s.timeEntries))
+	for _, te := range s.timeEntries {
+		projectId := 0
+		if wp := s.workPackage(te.WorkPackageId); wp != nil {
+			projectId = wp.ProjectId
+		}
+		records = append(records, record{
+			values: map[string]string{
+				"id":           strconv.Itoa(te.Id),
+				"user":         strconv.Itoa(te.UserId),
+				"work_package": strconv.Itoa(te.WorkPackageId),
+				"project":      strconv.Itoa(projectId),
+				"activity":     strconv.Itoa(te.ActivityId),
+				"spent_on":     te.SpentOn,
+				"created_at":   timestamp(te.CreatedAt),
+				"updated_at":   timestamp(te.UpdatedAt),
+				"comment":      te.Comment,
+			},
+			json: s.timeEntryJSON(te),
+		})
+	}
+	s.writeCollection(w, r, timeEntryFields, []sortCriterion{{name: "id"}}, records)
+}
+
+func (s *Server) getTimeEntry(w http.ResponseWriter, _ *http.Request, id int) {
+	te := s.timeEntry(id)
+	if te == nil {
+		writeNotFound(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.timeEntryJSON(te))
+}
+
+// newTimeEntry returns a time entry with the defaults of OpenProject: logged today by the current user, with the
+// default activity.
+func (s *Server) newTimeEntry() TimeEntry {
+	return TimeEntry{UserId: s.currentUser, ActivityId: s.defaultActivity().Id, SpentOn: s.now().Format("2006-01-02")}
+}
+
+func (s *Server) createTimeEntry(w http.ResponseWriter, r *http.Request, _ int) {
+	var input timeEntryInput
+	if !decodeBody(w, r, &input) {
+		return
+	}
+	te := s.newTimeEntry()
+	input.apply(&te)
+	if violations := s.validateTimeEntry(&te); len(violations) > 0 {
+		writeViolations(w, violations)
+		return
+	}
+	te.Id = s.nextId("time_entries", 0)
+	te.Hours = normaliseDuration(te.Hours)
+	s.stamp(&te.CreatedAt, &te.UpdatedAt)
+	s.timeEntries = append(s.timeEntries, &te)
+	writeJSON(w, http.StatusCreated, s.timeEntryJSON(&te))
+}
+
+func (s *Server) updateTimeEntry(w http.ResponseWriter, r *http.Request, id int) {
+	existing := s.timeEntry(id)
+	if existing == nil {
+		writeNotFound(w)
+		return
+	}
+	var input timeEntryInput
+	if !decodeBody(w, r, &input) {
+		return
+	}
+	te := *existing
+	input.apply(&te)
+	if violations := s.validateTimeEntry(&te); len(violations) > 0 {
+		writeViolations(w, violations)
+		return
+	}
+	te.Hours = normaliseDuration(te.Hours)
+	te.UpdatedAt = s.now().UTC().Truncate(time.Second)
+	*existing = te
+	writeJSON(w, http.StatusOK, s.timeEntryJSON(existing))
+}
+
+func (s *Server) deleteTimeEntry(w http.ResponseWriter, _ *http.Request, id int) {
+	for i, te := range s.timeEntries {
+		if te.Id == id {
+			s.timeEntries = append(s.timeEntries[:i], s.timeEntries[i+1:]...)
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+	}
+	writeNotFound(w)
+}
+
+// timeEntryForm validates a time entry without saving it: a new one, or the changes to an existing one if id is set.
+func (s *Server) timeEntryForm(w http.ResponseWriter, r *http.Request, id int) {
+	te := s.newTimeEntry()
+	commit := map[string]interface{}{"href": apiPrefix + "time_entries", "method": "post"}
+	if id != 0 {
+		existing := s.timeEntry(id)
+		if existing == nil {
+			writeNotFound(w)
+			return
+		}
+		te = *existing
+		commit = map[string]interface{}{"href": fmt.Sprintf("%stime_entries/%d", apiPrefix, id), "method": "patch"}
+	}
+	var input timeEntryInput
+	if !decodeBody(w, r, &input) {
+		return
+	}
+	input.apply(&te)
+
+	validationErrors := map[string]interface{}{}
+	for _, v := range s.validateTimeEntry(&te) {
+		validationErrors[v.attribute] = violationJSON(v)
+	}
+	activities := make([]interface{}, 0, len(s.activities))
+	for _, activity := range s.activities {
+		activities = append(activities, s.activityJSON(activity))
+	}
+	field := func(fieldType, name string, required bool) map[string]interface{} {
+		return map[string]interface{}{"type": fieldType, "name": name, "required": required, "hasDefault": false, "writable": true}
+	}
+	activity := field("TimeEntriesActivity", "Activity", true)
+	activity["_embedded"] = map[string]interface{}{"allowedValues": activities}
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"_type": "Form",
+		"_embedded": map[string]interface{}{
+			"payload": map[string]interface{}{
+				"comment": formattable("plain", te.Comment),
+				"spentOn": nullable(te.SpentOn),
+				"hours":   nullable(te.Hours),
+				"_links": map[string]interface{}{
+					"workPackage": s.workPackageLink(te.WorkPackageId),
+					"activity":    s.activityLink(te.ActivityId),
+					"user":        s.userLink(te.UserId),
+				},
+			},
+			"schema": map[string]interface{}{
+				"_type":       "Schema",
+				"spentOn":     field("Date", "Date", true),
+				"hours":       field("Duration", "Hours", true),
+				"comment":     field("Formattable", "Comment", false),
+				"workPackage": field("WorkPackage", "Work package", true),
+				"user":        field("User", "User", true),
+				"activity":    activity,
+			},
+			"validationErrors": validationErrors,
+		},
+		"_links": map[string]interface{}{
+			"self":     map[string]interface{}{"href": r.URL.Path, "method": "post"},
+			"validate": map[string]interface{}{"href": r.URL.Path, "method": "post"},
+			"commit":   commit,
+		},
+	})
+}
+
+// apply sets the attributes and links of a time entry present in the input.
+func (input *timeEntryInput) apply(te *TimeEntry) {
+	if input.Comment != nil {
+		te.Comment = input.Comment.Raw
+	}
+	if input.Hours != nil {
+		te.Hours = *input.Hours
+	}
+	if input.SpentOn != nil {
+		te.SpentOn = *input.SpentOn
+	}
+	if input.Links.WorkPackage != nil {
+		te.WorkPackageId = input.Links.WorkPackage.id("work_packages")
+	}
+	if input.Links.Activity != nil {
+		te.ActivityId = input.Links.Activity.id("time_entries/activities")
+	}
+	if input.Links.User != nil {
+		te.UserId = input.Links.User.id("users")
+	}
+}
+
+// validateTimeEntry returns what OpenProject would reject in a time entry.
+func (s *Server) validateTimeEntry(te *TimeEntry) []violation {
+	var violations []violation
+	switch {
+	case te.WorkPackageId == 0:
+		violations = append(violations, violation{"workPackage", "Work package can't be blank."})
+	case s.workPackage(te.WorkPackageId) == nil:
+		violations = append(violations, violation{"workPackage", "Work package is invalid."})
+	}
+	if s.activity(te.ActivityId) == nil {
+		violations = append(violations, violation{"activity", "Activity is invalid."})
+	}
+	if s.user(te.UserId) == nil {
+		violations = append(violations, violation{"user", "User is invalid."})
+	}
+	if te.Hours == "" {
+		violations = append(violations, violation{"hours", "Hours can't be blank."})
+	} else if _, ok := parseDuration(te.Hours); !ok {
+		violations = append(violations, violation{"hours", "Hours is invalid."})
+	}
+	if te.SpentOn == "" {
+		violations = append(violations, violation{"spentOn", "Date can't be blank."})
+	} else if _, err := time.Parse("2006-01-02", te.SpentOn); err != nil {
+		violations = append(violations, violation{"spentOn", "Date is invalid."})
+	}
+	return violations
+}
+
+// normaliseDuration returns a valid duration the way OpenProject stores it, e.g. `PT1H30M` for `PT1.5H`.
+func normaliseDuration(duration string) string {
+	seconds, _ := parseDuration(duration)
+	return formatDuration(seconds)
+}
+
+// decodeBody reads the JSON body of a request, and writes the error if it isn't valid.
+func decodeBody(w http.ResponseWriter, r *http.Request, value interface{}) bool {
+	if r.ContentLength == 0 {
+		return true
+	}
+	if err := json.NewDecoder(r.Body).Decode(value); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidRequestBody", "The request body was not a single JSON object.")
+		return false
+	}
+	return true
+}
+
+func violationJSON(v violation) map[string]interface{} {
+	body := errorJSON("PropertyConstraintViolation", v.message)
+	body["_embedded"] = map[string]interface{}{"details": map[string]interface{}{"attribute": v.attribute}}
+	return body
+}
+
+// writeViolations writes the 422 response for a resource that fails validation.
+func writeViolations(w http.ResponseWriter, violations []violation) {
+	if len(violations) == 1 {
+		writeJSON(w, http.StatusUnprocessableEntity, violationJSON(violations[0]))
+		return
+	}
+	errors := make([]interface{}, 0, len(violations))
+	for _, v := range violations {
+		errors = append(errors, violationJSON(v))
+	}
+	body := errorJSON("MultipleErrors", "Multiple field constraints have been violated.")
+	body["_embedded"] = map[string]interface{}{"errors": errors}
+	writeJSON(w, http.StatusUnprocessableEntity, body)
+}
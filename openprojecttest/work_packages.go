@@ -0,0 +1,271 @@
+package openprojecttest
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+// workPackageFields are the names the work packages can be filtered and sorted by.
+var workPackageFields = []string{"id", "subject", "status", "assigned_to", "assignee", "project", "start_date", "due_date", "created_at", "updated_at"}
+
+// workPackageInput is the body of a request to update a work package. Absent attributes are not changed.
+type workPackageInput struct {
+	LockVersion *int    `json:"lockVersion"`
+	Subject     *string `json:"subject"`
+	Description *struct {
+		Raw string `json:"raw"`
+	} `json:"description"`
+	StartDate      *string `json:"startDate"`
+	DueDate        *string `json:"dueDate"`
+	EstimatedTime  *string `json:"estimatedTime"`
+	PercentageDone *int    `json:"percentageDone"`
+	Links          struct {
+		Status   *hrefLink `json:"status"`
+		Assignee *hrefLink `json:"assignee"`
+	} `json:"_links"`
+}
+
+func (s *Server) workPackage(id int) *WorkPackage {
+	for _, wp := range s.workPackages {
+		if wp.Id == id {
+			return wp
+		}
+	}
+	return nil
+}
+
+// spentTime returns the time logged on a work package.
+func (s *Server) spentTime(workPackageId int) string {
+	var seconds int64
+	for _, te := range s.timeEntries {
+		if te.WorkPackageId == workPackageId {
+			spent, _ := parseDuration(te.Hours)
+			seconds += spent
+		}
+	}
+	return formatDuration(seconds)
+}
+
+func (s *Server) workPackageJSON(wp *WorkPackage) map[string]interface{} {
+	self := fmt.Sprintf("%swork_packages/%d", apiPrefix, wp.Id)
+	timeEntriesFilter := fmt.Sprintf(`[{"work_package":{"operator":"=","values":["%d"]}}]`, wp.Id)
+	assignee := nullLink()
+	if wp.AssigneeId != 0 {
+		assignee = s.userLink(wp.AssigneeId)
+	}
+	return map[string]interface{}{
+		"_type":          "WorkPackage",
+		"id":             wp.Id,
+		"lockVersion":    wp.LockVersion,
+		"subject":        wp.Subject,
+		"description":    formattable("markdown", wp.Description),
+		"startDate":      nullable(wp.StartDate),
+		"dueDate":        nullable(wp.DueDate),
+		"estimatedTime":  nullable(wp.EstimatedTime),
+		"spentTime":      s.spentTime(wp.Id),
+		"percentageDone": wp.PercentageDone,
+		"createdAt":      timestamp(wp.CreatedAt),
+		"updatedAt":      timestamp(wp.UpdatedAt),
+		"_links": map[string]interface{}{
+			"self":              link(self, wp.Subject),
+			"update":            map[string]interface{}{"href": self + "/form", "method": "post"},
+			"updateImmediately": map[string]interface{}{"href": self, "method": "patch"},
+			"project":           s.projectLink(wp.ProjectId),
+			"status":            s.statusLink(wp.StatusId),
+			"assignee":          assignee,
+			"timeEntries":       link(apiPrefix+"time_entries?"+url.Values{"filters": {timeEntriesFilter}}.Encode(), "Time entries"),
+		},
+	}
+}
+
+func (s *Server) listWorkPackages(w http.ResponseWriter, r *http.Request, _ int) {
+	records := make([]record, 0, len(s.workPackages))
+	for _, wp := range s.workPackages {
+		assignee := ""
+		if wp.AssigneeId != 0 {
+			assignee = strconv.Itoa(wp.AssigneeId)
+		}
+		records = append(records, record{
+			values: map[string]string{
+				"id":          strconv.Itoa(wp.Id),
+				"subject":     wp.Subject,
+				"status":      strconv.Itoa(wp.StatusId),
+				"assigned_to": assignee,
+				"assignee":    assignee,
+				"project":     strconv.Itoa(wp.ProjectId),
+				"start_date":  wp.StartDate,
+				"due_date":    wp.DueDate,
+				"created_at":  timestamp(wp.CreatedAt),
+				"updated_at":  timestamp(wp.UpdatedAt),
+			},
+			json: s.workPackageJSON(wp),
+		})
+	}
+	s.writeCollection(w, r, workPackageFields, []sortCriterion{{name: "id", desc: true}}, records)
+}
+
+func (s *Server) getWorkPackage(w http.ResponseWriter, _ *http.Request, id int) {
+	wp := s.workPackage(id)
+	if wp == nil {
+		writeNotFound(w)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.workPackageJSON(wp))
+}
+
+// updateWorkPackage changes a work package. Like OpenProject, it requires the lock version of the work package the
+// changes were made to, and rejects them with a 409 status if it was changed since.
+func (s *Server) updateWorkPackage(w http.ResponseWriter, r *http.Request, id int) {
+	existing := s.workPackage(id)
+	if existing == nil {
+		writeNotFound(w)
+		return
+	}
+	var input workPackageInput
+	if !decodeBody(w, r, &input) {
+		return
+	}
+	if input.LockVersion == nil || *input.LockVersion != existing.LockVersion {
+		writeError(w, http.StatusConflict, "UpdateConflict", "Your changes could not be saved, because the work package was changed by someone else in the meantime.")
+		return
+	}
+	wp := *existing
+	input.apply(&wp)
+	if violations := s.validateWorkPackage(&wp); len(violations) > 0 {
+		writeViolations(w, violations)
+		return
+	}
+	if wp.EstimatedTime != "" {
+		wp.EstimatedTime = normaliseDuration(wp.EstimatedTime)
+	}
+	wp.LockVersion++
+	wp.UpdatedAt = s.now().UTC().Truncate(time.Second)
+	*existing = wp
+	writeJSON(w, http.StatusOK, s.workPackageJSON(existing))
+}
+
+// workPackageForm validates changes to a work package without saving them, and lists the statuses it can be set to.
+func (s *Server) workPackageForm(w http.ResponseWriter, r *http.Request, id int) {
+	existing := s.workPackage(id)
+	if existing == nil {
+		writeNotFound(w)
+		return
+	}
+	var input workPackageInput
+	if !decodeBody(w, r, &input) {
+		return
+	}
+	wp := *existing
+	input.apply(&wp)
+
+	validationErrors := map[string]interface{}{}
+	for _, v := range s.validateWorkPackage(&wp) {
+		validationErrors[v.attribute] = violationJSON(v)
+	}
+	statuses := make([]interface{}, 0, len(s.statuses))
+	for _, status := range s.statuses {
+		statuses = append(statuses, s.statusJSON(status))
+	}
+	assignee := nullLink()
+	if wp.AssigneeId != 0 {
+		assignee = s.userLink(wp.AssigneeId)
+	}
+	self := fmt.Sprintf("%swork_packages/%d", apiPrefix, id)
+
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"_type": "Form",
+		"_embedded": map[string]interface{}{
+			"payload": map[string]interface{}{
+				"lockVersion":    existing.LockVersion,
+				"subject":        wp.Subject,
+				"description":    formattable("markdown", wp.Description),
+				"startDate":      nullable(wp.StartDate),
+				"dueDate":        nullable(wp.DueDate),
+				"estimatedTime":  nullable(wp.EstimatedTime),
+				"percentageDone": wp.PercentageDone,
+				"_links": map[string]interface{}{
+					"status":   s.statusLink(wp.StatusId),
+					"assignee": assignee,
+				},
+			},
+			"schema": map[string]interface{}{
+				"_type":       "Schema",
+				"lockVersion": map[string]interface{}{"type": "Integer", "name": "Lock Version", "required": true, "writable": false},
+				"subject":     map[string]interface{}{"type": "String", "name": "Subject", "required": true, "writable": true},
+				"status": map[string]interface{}{
+					"type": "Status", "name": "Status", "required": true, "writable": true,
+					"_embedded": map[string]interface{}{"allowedValues": statuses},
+				},
+			},
+			"validationErrors": validationErrors,
+		},
+		"_links": map[string]interface{}{
+			"self":     map[string]interface{}{"href": r.URL.Path, "method": "post"},
+			"validate": map[string]interface{}{"href": r.URL.Path, "method": "post"},
+			"commit":   map[string]interface{}{"href": self, "method": "patch"},
+		},
+	})
+}
+
+// apply sets the attributes and links of a work package present in the input.
+func (input *workPackageInput) apply(wp *WorkPackage) {
+	if input.Subject != nil {
+		wp.Subject = *input.Subject
+	}
+	if input.Description != nil {
+		wp.Description = input.Description.Raw
+	}
+	if input.StartDate != nil {
+		wp.StartDate = *input.StartDate
+	}
+	if input.DueDate != nil {
+		wp.DueDate = *input.DueDate
+	}
+	if input.EstimatedTime != nil {
+		wp.EstimatedTime = *input.EstimatedTime
+	}
+	if input.PercentageDone != nil {
+		wp.PercentageDone = *input.PercentageDone
+	}
+	if input.Links.Status != nil {
+		wp.StatusId = input.Links.Status.id("statuses")
+	}
+	if input.Links.Assignee != nil {
+		wp.AssigneeId = input.Links.Assignee.id("users")
+	}
+}
+
+// validateWorkPackage returns what OpenProject would reject in a work package.
+func (s *Server) validateWorkPackage(wp *WorkPackage) []violation {
+	var violations []violation
+	if wp.Subject == "" {
+		violations = append(violations, violation{"subject", "Subject can't be blank."})
+	}
+	if s.status(wp.StatusId) == nil {
+		violations = append(violations, violation{"status", "Status is invalid."})
+	}
+	if wp.AssigneeId != 0 && s.user(wp.AssigneeId) == nil {
+		violations = append(violations, violation{"assignee", "Assignee is invalid."})
+	}
+	for _, date := range []struct{ attribute, name, value string }{
+		{"startDate", "Start date", wp.StartDate},
+		{"dueDate", "Finish date", wp.DueDate},
+	} {
+		if _, err := time.Parse("2006-01-02", date.value); date.value != "" && err != nil {
+			violations = append(violations, violation{date.attribute, date.name + " is invalid."})
+		}
+	}
+	if wp.StartDate != "" && wp.DueDate != "" && wp.DueDate < wp.StartDate {
+		violations = append(violations, violation{"dueDate", "Finish date must be greater than or equal to start date."})
+	}
+	if _, ok := parseDuration(wp.EstimatedTime); wp.EstimatedTime != "" && !ok {
+		violations = append(violations, violation{"estimatedTime", "Work is invalid."})
+	}
+	if wp.PercentageDone < 0 || wp.PercentageDone > 100 {
+		violations = append(violations, violation{"percentageDone", "% Complete must be between 0 and 100."})
+	}
+	return violations
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// paletteChoice is an item of the command palette: an action, or a value of one of its prompts.
+type paletteChoice struct {
+	value string
+	text  string
+
+	// search are the texts matched against what is typed.
+	search []string
+}
+
+// togglePalette shows the command palette, or hides it if it is shown. It lists the actions that can be run from
+// where it was opened; once one is picked, its prompts are asked for in turn, and it is run where the palette was
+// opened.
+func (tui *Tui) togglePalette() {
+	if tui.Pages.HasPage("palette") {
+		tui.closePalette()
+		return
+	}
+	tui.paletteReturnFocus = tui.App.GetFocus()
+
+	var commands []paletteChoice
+	for _, action := range keyActions {
+		if action.name == actionPalette || !tui.actionAvailable(action.name) {
+			continue
+		}
+		text := action.description
+		if keys := tui.keys.describe(action.name); keys != "" {
+			text += " " + styled(styleMuted, "("+tview.Escape(keys)+")")
+		}
+		commands = append(commands, paletteChoice{
+			value:  action.name,
+			text:   text,
+			search: []string{action.description, action.name},
+		})
+	}
+
+	input := tview.NewInputField()
+	list := tview.NewList().ShowSecondaryText(false)
+	list.SetDoneFunc(tui.closePalette)
+	flex := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(input, 1, 0, true).
+		AddItem(list, 0, 1, false)
+	flex.SetBorder(true).SetTitle("Commands").SetTitleAlign(tview.AlignCenter)
+
+	// The choices of the current step, those shown, and what to do with the one picked or, if freeText, with the
+	// typed text.
+	var choices, shown []paletteChoice
+	var freeText bool
+	var done func(value string)
+
+	show := func(text string) {
+		shown = filterChoices(choices, text)
+		list.Clear()
+		for _, choice := range shown {
+			choice := choice
+			list.AddItem(choice.text, "", 0, func() {
+				done(choice.value)
+			})
+		}
+	}
+	input.SetChangedFunc(show)
+	input.SetDoneFunc(func(key tcell.Key) {
+		switch {
+		case key == tcell.KeyEscape:
+			tui.closePalette()
+		case key != tcell.KeyEnter:
+		case freeText:
+			done(input.GetText())
+		case len(shown) > 0:
+			done(shown[list.GetCurrentItem()].value)
+		}
+	})
+	input.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyUp, tcell.KeyDown, tcell.KeyPgUp, tcell.KeyPgDn:
+			// Move in the list while typing.
+			list.InputHandler()(event, nil)
+			return nil
+		}
+		return event
+	})
+
+	// ask shows a step of the palette.
+	ask := func(title, label string, stepChoices []paletteChoice, stepFreeText bool, stepDone func(value string)) {
+		choices, freeText, done = stepChoices, stepFreeText, stepDone
+		flex.SetTitle(title)
+		input.SetLabel(label + ": ").SetText("")
+		show("")
+		tui.App.SetFocus(input)
+	}
+
+	ask("Commands", "Command", commands, false, func(name string) {
+		handler := tui.actions[name]
+		title := name
+		for _, action := range keyActions {
+			if action.name == name {
+				title = action.description
+			}
+		}
+
+		var args []string
+		var next func()
+		next = func() {
+			if len(args) == len(handler.prompts) {
+				tui.closePalette()
+				tui.runAction(name, args)
+				return
+			}
+			prompt := handler.prompts[len(args)]
+			var promptChoices []paletteChoice
+			if prompt.choices != nil {
+				for _, value := range prompt.choices() {
+					promptChoices = append(promptChoices, paletteChoice{
+						value:  value,
+						text:   tview.Escape(value),
+						search: []string{value},
+					})
+				}
+			}
+			ask(title, prompt.label, promptChoices, prompt.choices == nil, func(value string) {
+				args = append(args, value)
+				next()
+			})
+		}
+		next()
+	})
+
+	tui.Pages.AddPage("palette", tui.Modal(flex, 70, 20), true, true)
+	tui.App.SetFocus(input)
+}
+
+func (tui *Tui) closePalette() {
+	tui.Pages.RemovePage("palette")
+	tui.App.SetFocus(tui.paletteReturnFocus)
+}
+
+// filterChoices returns the choices matching what is typed, best matches first, in their order otherwise.
+func filterChoices(choices []paletteChoice, typed string) []paletteChoice {
+	pattern := strings.Join(strings.Fields(typed), "")
+	type match struct {
+		choice paletteChoice
+		score  int
+	}
+	var matches []match
+	for _, choice := range choices {
+		best, found := 0, false
+		for _, text := range choice.search {
+			if score, ok := fuzzyScore(pattern, text); ok && (!found || score > best) {
+				best, found = score, true
+			}
+		}
+		if found {
+			matches = append(matches, match{choice, best})
+		}
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].score > matches[j].score
+	})
+	filtered := make([]paletteChoice, len(matches))
+	for i, m := range matches {
+		filtered[i] = m.choice
+	}
+	return filtered
+}
+
+// fuzzyScore reports whether the characters of a pattern appear in a text in the same order, ignoring case, and how
+// well they do: characters that follow each other or start words score higher. An empty pattern matches everything.
+func fuzzyScore(pattern, text string) (int, bool) {
+	p := []rune(strings.ToLower(pattern))
+	t := []rune(strings.ToLower(text))
+	score, matched, previous := 0, 0, -2
+	for i := 0; i < len(t) && matched < len(p); i++ {
+		if t[i] != p[matched] {
+			continue
+		}
+		score++
+		if i == previous+1 {
+			score += 2
+		}
+		if i == 0 || !unicode.IsLetter(t[i-1]) && !unicode.IsDigit(t[i-1]) {
+			score += 3
+		}
+		previous = i
+		matched++
+	}
+	return score, matched == len(p)
+}
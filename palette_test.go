@@ -0,0 +1,70 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestFuzzyScore(t *testing.T) {
+	for _, test := range []struct {
+		pattern, text string
+		score         int
+		ok            bool
+	}{
+		{"", "Log time", 0, true},
+		{"", "", 0, true},
+		{"log", "Log time", 10, true},
+		{"LOG", "log time", 10, true},
+		{"lt", "Log time", 8, true},
+		{"lt", "Delete", 2, true},
+		{"time", "Log time", 13, true},
+		{"é", "Café", 1, true},
+		{"xyz", "Log time", 0, false},
+		{"tl", "Log time", 5, false},
+		{"logs", "Log", 10, false},
+		{"log", "", 0, false},
+	} {
+		score, ok := fuzzyScore(test.pattern, test.text)
+		if ok != test.ok || ok && score != test.score {
+			t.Errorf("fuzzyScore(%q, %q) = %d, %v, want %d, %v", test.pattern, test.text, score, ok, test.score, test.ok)
+		}
+	}
+}
+
+func TestFilterChoicesRanking(t *testing.T) {
+	choices := func(texts ...string) []paletteChoice {
+		var choices []paletteChoice
+		for _, text := range texts {
+			choices = append(choices, paletteChoice{value: text, text: text, search: []string{text}})
+		}
+		return choices
+	}
+	for _, test := range []struct {
+		typed   string
+		choices []paletteChoice
+		want    string
+	}{
+		// Characters starting words, then following each other, rank first.
+		{"cs", choices("Bulk actions", "Copy yesterday", "Change status", "Help"), "Change status, Copy yesterday, Bulk actions"},
+		{"copy", choices("Copy last week", "Copy entry", "Compare"), "Copy last week, Copy entry"},
+		{"de", choices("Mode", "Delete entry", "Hide"), "Delete entry, Mode, Hide"},
+		// Spaces are ignored.
+		{"log t", choices("Templates", "Log time", "Delete entry"), "Log time"},
+		// Without a pattern, the order is kept.
+		{"", choices("Refresh", "Help", "Back"), "Refresh, Help, Back"},
+		{"zz", choices("Refresh", "Help"), ""},
+		// The best of the texts searched counts.
+		{"st", []paletteChoice{
+			{value: "Last", text: "Last", search: []string{"Last"}},
+			{value: "Closed", text: "Closed", search: []string{"Closed", "Status"}},
+		}, "Closed, Last"},
+	} {
+		var got []string
+		for _, choice := range filterChoices(test.choices, test.typed) {
+			got = append(got, choice.value)
+		}
+		if strings.Join(got, ", ") != test.want {
+			t.Errorf("filterChoices(%q) = %q, want %q", test.typed, strings.Join(got, ", "), test.want)
+		}
+	}
+}
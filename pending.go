@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// syncStatusText returns the part of the status bar that tells whether lazyop is offline and what is pending.
+func syncStatusText(status SyncStatus) string {
+	text := ""
+	if status.Offline {
+		text += " — " + styled(styleError, "offline")
+	}
+	if status.Pending > 0 {
+		text += fmt.Sprintf(" — %s (F5)", styled(styleWarning, fmt.Sprintf("%d pending", status.Pending)))
+	}
+	if status.Conflicts > 0 {
+		text += " — " + styled(styleError, fmt.Sprintf("%d conflicts", status.Conflicts))
+	}
+	return text
+}
+
+// SetupSyncStatus shows the connection status and the number of pending operations in the status bar.
+func (tui *Tui) SetupSyncStatus(status SyncStatus) {
+	tui.syncStatus = status
+	tui.renderStatusBar()
+}
+
+// showPendingOperations shows the changes made offline. Conflicts are resolved by selecting them; `r` replays the
+// queue at once. onChange is called after the queue changed, to reload the time entries.
+func (tui *Tui) showPendingOperations(client Backend, onChange func()) {
+	list := tview.NewList().ShowSecondaryText(true)
+	list.SetBorder(true).SetTitle("Pending Changes (Enter: resolve conflict, R: replay now, ESC: close)").SetTitleAlign(tview.AlignCenter)
+
+	closePage := func() {
+		tui.Pages.RemovePage("pending")
+		tui.App.SetFocus(tui.WorkPackageList)
+	}
+
+	var refresh func()
+	refresh = func() {
+		tui.SetupSyncStatus(client.SyncStatus())
+		current := list.GetCurrentItem()
+		list.Clear()
+		ops := client.PendingOperations()
+		if len(ops) == 0 {
+			list.AddItem("Nothing pending, all changes are on the server.", "", 0, nil)
+			return
+		}
+		for _, op := range ops {
+			op := op
+			secondary := styled(styleMuted, fmt.Sprintf("queued %s", op.QueuedAt.Format("2006-01-02 15:04")))
+			if op.Conflict != "" {
+				secondary = styled(styleError, "conflict: "+tview.Escape(op.Conflict))
+			}
+			list.AddItem(tview.Escape(describePending(op)), secondary, 0, func() {
+				if op.Conflict == "" {
+					return
+				}
+				tui.showResolveConflict(client, op, func() {
+					refresh()
+					onChange()
+				})
+			})
+		}
+		list.SetCurrentItem(current)
+	}
+
+	list.SetDoneFunc(closePage)
+	list.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyRune && (event.Rune() == 'r' || event.Rune() == 'R') {
+			replayed, err := client.ReplayPending()
+			refresh()
+			if replayed > 0 {
+				onChange()
+			}
+			if err != nil {
+				tui.NotifyError(fmt.Errorf("replayed %d changes, the server is still unreachable: %v", replayed, err))
+			}
+			return nil
+		}
+		return event
+	})
+
+	refresh()
+	tui.Pages.AddPage("pending", tui.Modal(list, 100, 20), true, true)
+}
+
+// showResolveConflict asks whether to apply a conflicting operation anyway or to discard it.
+func (tui *Tui) showResolveConflict(client Backend, op PendingOperation, onResolved func()) {
+	modal := tview.NewModal().
+		SetText(fmt.Sprintf("%s\n\n%s", describePending(op), op.Conflict)).
+		AddButtons([]string{"Keep mine", "Discard mine", "Cancel"}).
+		SetDoneFunc(func(index int, _ string) {
+			tui.Pages.RemovePage("resolveConflict")
+			if index != 0 && index != 1 {
+				return
+			}
+			if err := client.ResolvePending(op, index == 0); err != nil {
+				tui.ShowError(err)
+				return
+			}
+			onResolved()
+		})
+	theme.apply(modal)
+	tui.Pages.AddPage("resolveConflict", modal, true, true)
+}
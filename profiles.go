@@ -0,0 +1,31 @@
+package main
+
+import "github.com/rivo/tview"
+
+// showProfileSwitcher lists the profiles of the config file and calls onSelect with the one the user picks.
+func (tui *Tui) showProfileSwitcher(names []string, current string, onSelect func(name string)) {
+	returnFocus := tui.App.GetFocus()
+	closeList := func() {
+		tui.Pages.RemovePage("profiles")
+		tui.App.SetFocus(returnFocus)
+	}
+
+	list := tview.NewList().ShowSecondaryText(false)
+	for i, name := range names {
+		name := name
+		list.AddItem(name, "", 0, func() {
+			closeList()
+			if name != current {
+				onSelect(name)
+			}
+		})
+		if name == current {
+			list.SetCurrentItem(i)
+		}
+	}
+	list.SetDoneFunc(closeList)
+
+	list.SetBorder(true).SetTitle("Switch Profile").SetTitleAlign(tview.AlignCenter)
+
+	tui.Pages.AddPage("profiles", tui.Modal(list, 45, len(names)+2), true, true)
+}
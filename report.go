@@ -0,0 +1,140 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"github.com/rivo/tview"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Periods of the reports.
+const (
+	reportThisWeek  = "this week"
+	reportLastWeek  = "last week"
+	reportThisMonth = "this month"
+	reportLastMonth = "last month"
+)
+
+var reportPeriods = []string{reportThisWeek, reportLastWeek, reportThisMonth, reportLastMonth}
+
+// reportPeriod returns the first and last days of a period of the reports, relative to today.
+func reportPeriod(period string, today time.Time) (time.Time, time.Time, error) {
+	monday := startOfWeek(today)
+	year, month, _ := today.Date()
+	firstOfMonth := time.Date(year, month, 1, 0, 0, 0, 0, today.Location())
+	switch period {
+	case reportThisWeek:
+		return monday, monday.AddDate(0, 0, 6), nil
+	case reportLastWeek:
+		return monday.AddDate(0, 0, -7), monday.AddDate(0, 0, -1), nil
+	case reportThisMonth:
+		return firstOfMonth, firstOfMonth.AddDate(0, 1, -1), nil
+	case reportLastMonth:
+		return firstOfMonth.AddDate(0, -1, 0), firstOfMonth.AddDate(0, 0, -1), nil
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("unknown period %q, expected one of %s", period, strings.Join(reportPeriods, ", "))
+}
+
+// reportFileName is the file a report is written to unless another one is given.
+func reportFileName(start, end time.Time) string {
+	return fmt.Sprintf("lazyop-report-%s-%s.csv", start.Format("2006-01-02"), end.Format("2006-01-02"))
+}
+
+// WriteReport writes time entries as CSV, by day and work package, with the durations in decimal hours. The columns
+// are those read by `lazyop time import`, plus the activity.
+func WriteReport(w io.Writer, timeEntries []TimeEntry) error {
+	timeEntries = append([]TimeEntry(nil), timeEntries...)
+	sort.SliceStable(timeEntries, func(i, j int) bool {
+		if timeEntries[i].Date != timeEntries[j].Date {
+			return timeEntries[i].Date < timeEntries[j].Date
+		}
+		return idFromHref(timeEntries[i].Links.WorkPackage.Href) < idFromHref(timeEntries[j].Links.WorkPackage.Href)
+	})
+
+	writer := csv.NewWriter(w)
+	writer.Write([]string{"work_package", "subject", "date", "duration", "activity", "comment"})
+	for _, te := range timeEntries {
+		hours, err := ParseIso8601(te.Hours)
+		if err != nil {
+			return fmt.Errorf("time entry %d: %v", te.Id, err)
+		}
+		writer.Write([]string{
+			strconv.Itoa(idFromHref(te.Links.WorkPackage.Href)),
+			te.Links.WorkPackage.Title,
+			te.Date,
+			strconv.FormatFloat(float64(hours.Seconds())/3600, 'f', 2, 64),
+			te.Links.Activity.Title,
+			te.Comment.Raw,
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// exportReport writes the time entries of a user over a period to a file, by default `reportFileName`. It returns
+// the file and the number of time entries written.
+func exportReport(client Backend, userId int, period, path string, today time.Time) (string, int, error) {
+	start, end, err := reportPeriod(period, today)
+	if err != nil {
+		return "", 0, err
+	}
+	timeEntries, err := client.ListTimeEntriesBetween(userId, start, end)
+	if err != nil {
+		return "", 0, err
+	}
+	if path == "" {
+		path = reportFileName(start, end)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return "", 0, err
+	}
+	if err := WriteReport(file, timeEntries.Embedded.Elements); err != nil {
+		file.Close()
+		return "", 0, err
+	}
+	if err := file.Close(); err != nil {
+		return "", 0, err
+	}
+	return path, len(timeEntries.Embedded.Elements), nil
+}
+
+// runExportReport exports a report and tells the user where it was written.
+func (tui *Tui) runExportReport(client Backend, userId int, period, path string) {
+	path, count, err := exportReport(client, userId, period, strings.TrimSpace(path), time.Now())
+	if err != nil {
+		tui.ShowError(err)
+		return
+	}
+	tui.Notify(fmt.Sprintf("Exported %d time entries from %s to %s.", count, period, path))
+}
+
+// showExportReportForm asks for the period and the file of a report, and exports it.
+func (tui *Tui) showExportReportForm(client Backend, userId int) {
+	returnFocus := tui.App.GetFocus()
+	closeForm := func() {
+		tui.Pages.RemovePage("exportReport")
+		tui.App.SetFocus(returnFocus)
+	}
+
+	form := tview.NewForm()
+	form.AddDropDown("Period", reportPeriods, 0, nil).
+		AddInputField("File", "", 0, nil, nil).
+		AddButton("Export", func() {
+			_, period := form.GetFormItem(0).(*tview.DropDown).GetCurrentOption()
+			path := form.GetFormItem(1).(*tview.InputField).GetText()
+			closeForm()
+			tui.runExportReport(client, userId, period, path)
+		}).
+		AddButton("Quit", closeForm)
+
+	form.SetBorder(true).SetTitle("Export Report").SetTitleAlign(tview.AlignCenter)
+	form.SetCancelFunc(closeForm)
+
+	tui.Pages.AddPage("exportReport", tui.Modal(form, 60, 9), true, true)
+}
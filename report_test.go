@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReportPeriod(t *testing.T) {
+	// A Wednesday.
+	today := time.Date(2024, 3, 13, 15, 0, 0, 0, time.UTC)
+	tests := []struct {
+		period     string
+		start, end string
+	}{
+		{reportThisWeek, "2024-03-11", "2024-03-17"},
+		{reportLastWeek, "2024-03-04", "2024-03-10"},
+		{reportThisMonth, "2024-03-01", "2024-03-31"},
+		{reportLastMonth, "2024-02-01", "2024-02-29"},
+	}
+	for _, test := range tests {
+		start, end, err := reportPeriod(test.period, today)
+		if err != nil {
+			t.Errorf("%s: %v", test.period, err)
+			continue
+		}
+		if got := start.Format("2006-01-02") + " " + end.Format("2006-01-02"); got != test.start+" "+test.end {
+			t.Errorf("%s = %s, want %s %s", test.period, got, test.start, test.end)
+		}
+	}
+	if _, _, err := reportPeriod("yesterday", today); err == nil {
+		t.Error("reportPeriod accepted an unknown period")
+	}
+}
+
+// reportTimeEntry returns a time entry as returned by OpenProject, with the titles of its links.
+func reportTimeEntry(workPackageId int, subject, date, hours, activity, comment string) TimeEntry {
+	var te TimeEntry
+	te.Links.WorkPackage.Href, te.Links.WorkPackage.Title = workPackageHref(workPackageId), subject
+	te.Links.Activity.Href, te.Links.Activity.Title = activityHref(3), activity
+	te.Date, te.Hours, te.Comment.Raw = date, hours, comment
+	return te
+}
+
+func TestWriteReport(t *testing.T) {
+	timeEntries := []TimeEntry{
+		reportTimeEntry(2, "Checkout", "2024-05-07", "PT1H30M", "Development", "Payment, cards"),
+		reportTimeEntry(1, "Landing page", "2024-05-07", "PT45M", "Development", "Design"),
+		reportTimeEntry(1, "Landing page", "2024-05-06", "PT1H", "Testing", ""),
+	}
+
+	var report bytes.Buffer
+	if err := WriteReport(&report, timeEntries); err != nil {
+		t.Fatal(err)
+	}
+	want := "work_package,subject,date,duration,activity,comment\n" +
+		"1,Landing page,2024-05-06,1.00,Testing,\n" +
+		"1,Landing page,2024-05-07,0.75,Development,Design\n" +
+		"2,Checkout,2024-05-07,1.50,Development,\"Payment, cards\"\n"
+	if report.String() != want {
+		t.Errorf("report:\n%s\nwant:\n%s", report.String(), want)
+	}
+
+	// A report can be imported again.
+	rows, err := ReadImportFile(strings.NewReader(report.String()), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != 3 || rows[2].WorkPackageId != 2 || rows[2].Duration.Seconds() != 5400 || rows[2].Comment != "Payment, cards" || rows[2].Err != nil {
+		t.Errorf("imported rows = %+v", rows)
+	}
+
+	timeEntries[0].Hours = "1h"
+	if err := WriteReport(&report, timeEntries); err == nil {
+		t.Error("WriteReport accepted an invalid duration")
+	}
+}
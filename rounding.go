@@ -0,0 +1,178 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/rivo/tview"
+	"os"
+	"text/tabwriter"
+	"time"
+)
+
+// Rounding modes.
+const (
+	roundingNearest = "nearest"
+	roundingUp      = "up"
+	roundingDown    = "down"
+)
+
+// Rounding are the rules applied to the durations of new and edited time entries, e.g. to bill in 15-minute
+// increments. The zero value doesn't change any duration.
+type Rounding struct {
+	// Mode is `nearest` (the default), `up` or `down`.
+	Mode string `json:"mode"`
+	// Increment is the number of minutes durations are rounded to a multiple of. 0 disables rounding.
+	Increment int `json:"increment"`
+	// Minimum is the shortest duration of an entry, in minutes.
+	Minimum int `json:"minimum"`
+}
+
+// Validate checks that the rules are consistent.
+func (r *Rounding) Validate() error {
+	switch r.Mode {
+	case "", roundingNearest, roundingUp, roundingDown:
+	default:
+		return fmt.Errorf("invalid rounding mode %q: must be %s, %s or %s", r.Mode, roundingNearest, roundingUp, roundingDown)
+	}
+	if r.Increment < 0 || r.Minimum < 0 {
+		return fmt.Errorf("rounding increment and minimum must not be negative")
+	}
+	return nil
+}
+
+// Round returns the duration rounded according to the rules. Zero and negative durations are left unchanged.
+func (r *Rounding) Round(d *Duration) *Duration {
+	seconds := d.Seconds()
+	if seconds <= 0 {
+		return d
+	}
+	if increment := int64(r.Increment) * 60; increment > 0 {
+		remainder := seconds % increment
+		switch {
+		case remainder == 0:
+		case r.Mode == roundingDown:
+			seconds -= remainder
+		case r.Mode == roundingUp || remainder*2 >= increment:
+			seconds += increment - remainder
+		default:
+			seconds -= remainder
+		}
+	}
+	if minimum := int64(r.Minimum) * 60; seconds < minimum {
+		seconds = minimum
+	}
+	rounded := NewDurationFromSeconds(seconds)
+	return &rounded
+}
+
+// roundingPreview returns how a duration typed in a form will be logged, e.g. `1h7m → 1h15m`.
+func roundingPreview(input string, rounding *Rounding) string {
+	duration, err := Parse(input)
+	if err != nil {
+		return styled(styleError, "invalid duration")
+	}
+	rounded := rounding.Round(duration)
+	if rounded.Compare(duration) == 0 {
+		return duration.ToString()
+	}
+	return fmt.Sprintf("%s → %s", duration.ToString(), rounded.ToString())
+}
+
+// addDurationField adds the hours input of the time entry forms, followed by a preview of the rounded duration.
+func (tui *Tui) addDurationField(form *tview.Form, value string) *tview.Form {
+	preview := tview.NewTextView().
+		SetLabel("Logged as").
+		SetSize(1, 0).
+		SetDynamicColors(true).
+		SetText(roundingPreview(value, &tui.rounding))
+	return form.
+		AddInputField("Hours", value, 0, nil, func(text string) {
+			preview.SetText(roundingPreview(text, &tui.rounding))
+		}).
+		AddFormItem(preview)
+}
+
+// SetupRounding sets the rules applied to the durations logged from the forms.
+func (tui *Tui) SetupRounding(rounding Rounding) {
+	tui.rounding = rounding
+}
+
+// normaliseTimeEntries applies the rounding rules to existing time entries. It returns the ones that change, with
+// their new duration.
+func normaliseTimeEntries(timeEntries []TimeEntry, rounding *Rounding) ([]TimeEntry, error) {
+	var changed []TimeEntry
+	for _, te := range timeEntries {
+		hours, err := ParseIso8601(te.Hours)
+		if err != nil {
+			return nil, err
+		}
+		if rounded := rounding.Round(hours); rounded.Compare(hours) != 0 {
+			te.Hours = rounded.ToIso8601String()
+			changed = append(changed, te)
+		}
+	}
+	return changed, nil
+}
+
+// runTimeNormalise implements `lazyop time normalise [flags]`.
+func runTimeNormalise(client Backend, config *Config, args []string) error {
+	flags := flag.NewFlagSet("time normalise", flag.ExitOnError)
+	today := time.Now().Format("2006-01-02")
+	from := flags.String("from", today, "first day of the time entries to normalise")
+	to := flags.String("to", today, "last day of the time entries to normalise")
+	dryRun := flags.Bool("dry-run", false, "only show what would change")
+	yes := flags.Bool("yes", false, "change without asking for confirmation")
+	flags.Parse(args)
+
+	if config.Rounding.Increment == 0 && config.Rounding.Minimum == 0 {
+		return fmt.Errorf("no rounding rules defined in the config file")
+	}
+	start, err := time.Parse("2006-01-02", *from)
+	if err != nil {
+		return fmt.Errorf("invalid date: %v", err)
+	}
+	end, err := time.Parse("2006-01-02", *to)
+	if err != nil {
+		return fmt.Errorf("invalid date: %v", err)
+	}
+
+	timeEntries, err := client.ListTimeEntriesBetween(config.UserID, start, end)
+	if err != nil {
+		return err
+	}
+	changed, err := normaliseTimeEntries(timeEntries.Embedded.Elements, &config.Rounding)
+	if err != nil {
+		return err
+	}
+
+	original := make(map[int]string)
+	for _, te := range timeEntries.Embedded.Elements {
+		original[te.Id] = te.Hours
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tWORK PACKAGE\tDATE\tDURATION\tCOMMENT")
+	for _, te := range changed {
+		fmt.Fprintf(tw, "%d\t#%d\t%s\t%s → %s\t%s\n", te.Id, idFromHref(te.Links.WorkPackage.Href), te.Date, formatHours(original[te.Id]), formatHours(te.Hours), te.Comment.Raw)
+	}
+	tw.Flush()
+
+	if *dryRun || len(changed) == 0 {
+		return nil
+	}
+	if !*yes && !confirm(fmt.Sprintf("Update %d time entries?", len(changed))) {
+		return nil
+	}
+
+	failed := 0
+	for _, te := range changed {
+		if err := client.UpdateTimeEntry(te.Id, map[string]interface{}{"hours": te.Hours}); err != nil {
+			fmt.Fprintf(os.Stderr, "time entry %d: %v\n", te.Id, err)
+			failed++
+		}
+	}
+	fmt.Printf("Updated %d time entries, %d failed.\n", len(changed)-failed, failed)
+	if failed > 0 {
+		return fmt.Errorf("%d of %d time entries could not be updated", failed, len(changed))
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import "testing"
+
+func TestRound(t *testing.T) {
+	for _, test := range []struct {
+		rounding Rounding
+		seconds  int64
+		want     int64
+	}{
+		// Disabled.
+		{Rounding{}, 7*60 + 30, 7*60 + 30},
+		{Rounding{Mode: roundingUp}, 7*60 + 30, 7*60 + 30},
+
+		// Nearest, the default.
+		{Rounding{Increment: 15}, 7 * 60, 0},
+		{Rounding{Increment: 15}, 7*60 + 30, 15 * 60},
+		{Rounding{Mode: roundingNearest, Increment: 15}, 7*60 + 30, 15 * 60},
+		{Rounding{Mode: roundingNearest, Increment: 15}, 68 * 60, 75 * 60},
+		{Rounding{Mode: roundingNearest, Increment: 15}, 82 * 60, 75 * 60},
+		{Rounding{Mode: roundingNearest, Increment: 15}, 90 * 60, 90 * 60},
+
+		// Up.
+		{Rounding{Mode: roundingUp, Increment: 15}, 7*60 + 30, 15 * 60},
+		{Rounding{Mode: roundingUp, Increment: 15}, 61 * 60, 75 * 60},
+		{Rounding{Mode: roundingUp, Increment: 15}, 1, 15 * 60},
+		{Rounding{Mode: roundingUp, Increment: 15}, 60 * 60, 60 * 60},
+
+		// Down.
+		{Rounding{Mode: roundingDown, Increment: 15}, 7*60 + 30, 0},
+		{Rounding{Mode: roundingDown, Increment: 15}, 74 * 60, 60 * 60},
+		{Rounding{Mode: roundingDown, Increment: 15}, 60 * 60, 60 * 60},
+
+		// Zero is left unchanged, even with a minimum.
+		{Rounding{Increment: 15}, 0, 0},
+		{Rounding{Mode: roundingUp, Increment: 15, Minimum: 30}, 0, 0},
+
+		// Values below the minimum are raised to it, after rounding.
+		{Rounding{Minimum: 30}, 10 * 60, 30 * 60},
+		{Rounding{Minimum: 30}, 45 * 60, 45 * 60},
+		{Rounding{Mode: roundingDown, Increment: 15, Minimum: 15}, 7*60 + 30, 15 * 60},
+		{Rounding{Mode: roundingUp, Increment: 15, Minimum: 30}, 7*60 + 30, 30 * 60},
+		{Rounding{Increment: 15, Minimum: 20}, 22 * 60, 20 * 60},
+	} {
+		d := NewDurationFromSeconds(test.seconds)
+		if got := test.rounding.Round(&d).Seconds(); got != test.want {
+			t.Errorf("%+v.Round(%ds) = %ds, want %ds", test.rounding, test.seconds, got, test.want)
+		}
+	}
+}
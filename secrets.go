@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// keyringService is the service name under which API keys are stored in the Secret Service keyring.
+const keyringService = "lazyop"
+
+// resolveAPIKey returns the API key of a profile from the first source configured, in this order: the
+// `api_key_env` environment variable (if set), the output of `api_key_cmd`, the content of `api_key_file`, the
+// Secret Service keyring if `api_key_keyring` is set, and finally `api_key`.
+func (p *Profile) resolveAPIKey(profileName string) (string, error) {
+	if p.APIKeyEnv != "" {
+		if key := strings.TrimSpace(os.Getenv(p.APIKeyEnv)); key != "" {
+			return key, nil
+		}
+	}
+	if p.APIKeyCmd != "" {
+		return apiKeyFromCommand(p.APIKeyCmd)
+	}
+	if p.APIKeyFile != "" {
+		return apiKeyFromFile(os.ExpandEnv(p.APIKeyFile))
+	}
+	if p.APIKeyKeyring {
+		return apiKeyFromKeyring(profileName)
+	}
+	if p.APIKey == "" {
+		return "", fmt.Errorf("no API key configured")
+	}
+	return p.APIKey, nil
+}
+
+// apiKeyFromCommand runs a shell command, e.g. `pass show openproject`, and returns the first line of its output.
+func apiKeyFromCommand(command string) (string, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		return "", fmt.Errorf("error running api_key_cmd: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	key := strings.TrimSpace(strings.SplitN(string(output), "\n", 2)[0])
+	if key == "" {
+		return "", fmt.Errorf("api_key_cmd returned an empty key")
+	}
+	return key, nil
+}
+
+// apiKeyFromFile reads the key from a file, which must not be accessible to other users.
+func apiKeyFromFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading api_key_file: %v", err)
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		return "", fmt.Errorf("api_key_file %s is accessible to other users (mode %v), run `chmod go-rwx %s`", path, info.Mode().Perm(), path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("error reading api_key_file: %v", err)
+	}
+	key := strings.TrimSpace(string(data))
+	if key == "" {
+		return "", fmt.Errorf("api_key_file %s is empty", path)
+	}
+	return key, nil
+}
+
+// apiKeyFromKeyring looks the key up in the Secret Service keyring (e.g. GNOME Keyring or KWallet) with
+// `secret-tool`. Store it with `secret-tool store --label=lazyop service lazyop profile <name>`.
+func apiKeyFromKeyring(profileName string) (string, error) {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return "", fmt.Errorf("the keyring is not available: secret-tool not found")
+	}
+	output, err := exec.Command("secret-tool", "lookup", "service", keyringService, "profile", profileName).Output()
+	if err != nil {
+		return "", fmt.Errorf("no API key found in the keyring for profile %s: %v", profileName, err)
+	}
+	key := strings.TrimSpace(string(output))
+	if key == "" {
+		return "", fmt.Errorf("no API key found in the keyring for profile %s", profileName)
+	}
+	return key, nil
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// fakeSecretTool puts a `secret-tool` that prints key first in the PATH, or fails if key is empty.
+func fakeSecretTool(t *testing.T, key string) {
+	t.Helper()
+	dir := t.TempDir()
+	script := "#!/bin/sh\nexit 1\n"
+	if key != "" {
+		script = "#!/bin/sh\n[ \"$*\" = \"lookup service lazyop profile work\" ] || exit 2\necho " + key + "\n"
+	}
+	if err := os.WriteFile(filepath.Join(dir, "secret-tool"), []byte(script), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir+string(os.PathListSeparator)+os.Getenv("PATH"))
+}
+
+func TestResolveAPIKeyOrder(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "api_key")
+	if err := os.WriteFile(file, []byte("file-key\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	fakeSecretTool(t, "keyring-key")
+	t.Setenv("OP_KEY", "env-key")
+	t.Setenv("EMPTY_KEY", " ")
+	t.Setenv("KEY_DIR", filepath.Dir(file))
+
+	env := Profile{APIKeyEnv: "OP_KEY"}
+	cmd := Profile{APIKeyCmd: "printf 'cmd-key\\nsecond line\\n'"}
+	fromFile := Profile{APIKeyFile: file}
+	keyring := Profile{APIKeyKeyring: true}
+	for _, test := range []struct {
+		name    string
+		profile Profile
+		want    string
+	}{
+		{"api_key", Profile{APIKey: "config-key"}, "config-key"},
+		{"keyring", Profile{APIKey: "config-key", APIKeyKeyring: true}, "keyring-key"},
+		{"file", Profile{APIKey: "config-key", APIKeyKeyring: true, APIKeyFile: file}, "file-key"},
+		{"file with variables", Profile{APIKeyFile: "$KEY_DIR/api_key"}, "file-key"},
+		{"command", Profile{APIKey: "config-key", APIKeyKeyring: true, APIKeyFile: file, APIKeyCmd: cmd.APIKeyCmd}, "cmd-key"},
+		{"env", Profile{APIKey: "config-key", APIKeyKeyring: true, APIKeyFile: file, APIKeyCmd: cmd.APIKeyCmd, APIKeyEnv: "OP_KEY"}, "env-key"},
+		{"only env", env, "env-key"},
+		{"only command", cmd, "cmd-key"},
+		{"only file", fromFile, "file-key"},
+		{"only keyring", keyring, "keyring-key"},
+
+		// An empty or unset variable falls through to the next source.
+		{"empty env", Profile{APIKeyEnv: "EMPTY_KEY", APIKeyCmd: cmd.APIKeyCmd}, "cmd-key"},
+		{"unset env", Profile{APIKeyEnv: "UNSET_KEY", APIKeyFile: file}, "file-key"},
+		{"unset env before api_key", Profile{APIKeyEnv: "UNSET_KEY", APIKey: "config-key"}, "config-key"},
+	} {
+		key, err := test.profile.resolveAPIKey("work")
+		if err != nil || key != test.want {
+			t.Errorf("%s: key = %q, %v, want %q", test.name, key, err, test.want)
+		}
+	}
+}
+
+func TestResolveAPIKeyErrors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string, mode os.FileMode) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), mode); err != nil {
+			t.Fatal(err)
+		}
+		// The mode given to WriteFile is reduced by the umask.
+		if err := os.Chmod(path, mode); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	fakeSecretTool(t, "")
+	t.Setenv("EMPTY_KEY", "")
+
+	for _, test := range []struct {
+		name    string
+		profile Profile
+		want    string
+	}{
+		{"nothing", Profile{}, "no API key configured"},
+		{"empty env only", Profile{APIKeyEnv: "EMPTY_KEY"}, "no API key configured"},
+		{"failing command", Profile{APIKeyCmd: "echo locked >&2; exit 1", APIKey: "config-key"}, "error running api_key_cmd: exit status 1: locked"},
+		{"empty command", Profile{APIKeyCmd: "true"}, "api_key_cmd returned an empty key"},
+		{"missing file", Profile{APIKeyFile: filepath.Join(dir, "missing")}, "error reading api_key_file"},
+		{"empty file", Profile{APIKeyFile: write("empty", "\n", 0o600)}, "is empty"},
+		{"group-readable file", Profile{APIKeyFile: write("group", "key", 0o640)}, "is accessible to other users"},
+		{"world-readable file", Profile{APIKeyFile: write("world", "key", 0o604)}, "is accessible to other users"},
+		{"group-writable file", Profile{APIKeyFile: write("group-writable", "key", 0o620)}, "is accessible to other users"},
+		{"keyring without the key", Profile{APIKeyKeyring: true, APIKey: "config-key"}, "no API key found in the keyring for profile work"},
+	} {
+		key, err := test.profile.resolveAPIKey("work")
+		if err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("%s: key = %q, error = %v, want %q", test.name, key, err, test.want)
+		}
+	}
+
+	// The keyring needs secret-tool.
+	t.Setenv("PATH", t.TempDir())
+	_, err := (&Profile{APIKeyKeyring: true}).resolveAPIKey("work")
+	if err == nil || !strings.Contains(err.Error(), "secret-tool not found") {
+		t.Errorf("keyring without secret-tool: error = %v", err)
+	}
+}
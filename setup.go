@@ -0,0 +1,238 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"golang.org/x/term"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// apiPath is the path of the OpenProject API v3, relative to the address of the instance.
+const apiPath = "api/v3/"
+
+// NormalizeBaseURL returns the base URL of the API given the address of an instance, e.g. `openproject.example.com`
+// becomes `https://openproject.example.com/api/v3/`. Endpoints are appended to it, so it always ends with a slash.
+func NormalizeBaseURL(raw string) (string, error) {
+	raw = strings.TrimSpace(raw)
+	if raw == "" {
+		return "", fmt.Errorf("missing base_url")
+	}
+	if !strings.Contains(raw, "://") {
+		raw = "https://" + raw
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", fmt.Errorf("invalid base_url %q: %v", raw, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return "", fmt.Errorf("invalid base_url %q: the scheme must be http or https", raw)
+	}
+	if u.Host == "" {
+		return "", fmt.Errorf("invalid base_url %q: missing host", raw)
+	}
+	path := strings.TrimSuffix(u.Path, "/") + "/"
+	if !strings.HasSuffix(path, "/"+apiPath) {
+		path += apiPath
+	}
+	u.Path, u.RawPath, u.RawQuery, u.Fragment = path, "", "", ""
+	return u.String(), nil
+}
+
+// Validate returns all the problems found in the config. If online is set, the connection to every profile is
+// tested too.
+func (c *Config) Validate(online bool) []error {
+	var problems []error
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Errorf(format, args...))
+	}
+
+	if len(c.Profiles) == 0 {
+		add("no profile defined")
+	}
+	if _, ok := c.Profiles[c.DefaultProfile]; c.DefaultProfile != "" && !ok {
+		add("default_profile %q is not defined in profiles", c.DefaultProfile)
+	}
+	for _, name := range c.ProfileNames() {
+		for _, err := range c.Profiles[name].validate(name, online) {
+			add("profile %s: %v", name, err)
+		}
+	}
+
+	if c.HoursPerDay < 0 || c.HoursPerDay > 24 {
+		add("hours_per_day must be between 0 and 24")
+	}
+	if err := c.Rounding.Validate(); err != nil {
+		add("rounding: %v", err)
+	}
+	if err := c.Keymap.Validate(); err != nil {
+		add("keymap: %v", err)
+	}
+	if err := ValidateThemes(c.Theme, c.Themes); err != nil {
+		add("theme: %v", err)
+	}
+	for i, template := range c.Templates {
+		if template.WorkPackageId == 0 {
+			add("template %d: missing work_package", i+1)
+		}
+		if _, err := parseImportDuration(template.Duration); err != nil {
+			add("template %d: %v", i+1, err)
+		}
+		if _, err := parseRecurrence(template.Recurrence); err != nil {
+			add("template %d: %v", i+1, err)
+		}
+	}
+	return problems
+}
+
+// validate returns the problems found in a profile.
+func (p Profile) validate(name string, online bool) []error {
+	var problems []error
+	baseURL, err := NormalizeBaseURL(p.BaseURL)
+	if err != nil {
+		problems = append(problems, err)
+	}
+	if p.UserID < 0 {
+		problems = append(problems, fmt.Errorf("user_id must not be negative"))
+	}
+	if err := p.HTTP.Validate(); err != nil {
+		problems = append(problems, fmt.Errorf("http: %v", err))
+	}
+	authErr := p.Auth.Validate()
+	if authErr != nil {
+		problems = append(problems, authErr)
+	} else if !p.Auth.UsesOAuth2() {
+		p.APIKey, authErr = p.resolveAPIKey(name)
+		if authErr != nil {
+			problems = append(problems, authErr)
+		}
+	}
+	if !online || err != nil || authErr != nil {
+		return problems
+	}
+
+	p.BaseURL = baseURL
+	client, err := NewClientFromProfile(name, &p, false)
+	if err != nil {
+		return append(problems, err)
+	}
+	if _, err := client.GetRoot(); err != nil {
+		return append(problems, fmt.Errorf("cannot connect to %s: %v", baseURL, err))
+	}
+	if _, err := resolveCurrentUser(client, &p); err != nil {
+		problems = append(problems, err)
+	}
+	return problems
+}
+
+// runConfigValidate implements `lazyop config validate [flags]`.
+func runConfigValidate(configPath string, args []string) error {
+	flags := flag.NewFlagSet("config validate", flag.ExitOnError)
+	offline := flags.Bool("offline", false, "don't test the connection to the profiles")
+	flags.Parse(args)
+
+	path, err := findConfigFile(configPath)
+	if err != nil {
+		return err
+	}
+	data, err := readConfigFile(path)
+	if err != nil {
+		return err
+	}
+	config, err := parseConfig(path, data)
+	if err != nil {
+		return err
+	}
+
+	problems := config.Validate(!*offline)
+	// Misspelled settings would otherwise be silently ignored.
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&Config{}); err != nil {
+		problems = append(problems, err)
+	}
+
+	if len(problems) == 0 {
+		fmt.Printf("%s: OK\n", path)
+		return nil
+	}
+	for _, problem := range problems {
+		fmt.Printf("%s: %v\n", path, problem)
+	}
+	return fmt.Errorf("%d problems found", len(problems))
+}
+
+// runInit implements `lazyop init`, which asks for the address of the instance and an API key, checks them and
+// writes the config file.
+func runInit(configPath string, args []string) error {
+	if configPath == "" {
+		configPath = os.Getenv(configEnv)
+	}
+	if configPath == "" {
+		configPath = defaultConfigPath()
+	}
+	flags := flag.NewFlagSet("init", flag.ExitOnError)
+	path := flags.String("path", configPath, "where to write the config file")
+	flags.Parse(args)
+
+	if fileExists(*path) && !confirm(fmt.Sprintf("%s already exists. Overwrite it?", *path)) {
+		return nil
+	}
+
+	fmt.Print("OpenProject URL (e.g. https://openproject.example.com): ")
+	address, _ := stdin.ReadString('\n')
+	baseURL, err := NormalizeBaseURL(address)
+	if err != nil {
+		return err
+	}
+
+	fmt.Print("API key (see My account > Access tokens): ")
+	var apiKey string
+	if term.IsTerminal(int(os.Stdin.Fd())) {
+		key, err := term.ReadPassword(int(os.Stdin.Fd()))
+		fmt.Println()
+		if err != nil {
+			return fmt.Errorf("error reading API key: %v", err)
+		}
+		apiKey = string(key)
+	} else {
+		apiKey, _ = stdin.ReadString('\n')
+	}
+	apiKey = strings.TrimSpace(apiKey)
+	if apiKey == "" {
+		return fmt.Errorf("missing API key")
+	}
+
+	client := NewClient(baseURL, "apikey", apiKey)
+	root, err := client.GetRoot()
+	if err != nil {
+		return fmt.Errorf("cannot connect to %s: %v", baseURL, err)
+	}
+	var profile Profile
+	user, err := resolveCurrentUser(client, &profile)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Connected to %s as %s.\n", root.InstanceName, user.Name)
+
+	data, err := json.MarshalIndent(map[string]interface{}{
+		"base_url": baseURL,
+		"user_id":  user.Id,
+		"api_key":  apiKey,
+	}, "", "    ")
+	if err != nil {
+		return fmt.Errorf("error marshalling config: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(*path), 0o700); err != nil {
+		return fmt.Errorf("error creating config directory: %v", err)
+	}
+	if err := os.WriteFile(*path, append(data, '\n'), 0o600); err != nil {
+		return fmt.Errorf("error writing config file: %v", err)
+	}
+	fmt.Printf("Config written to %s.\n", *path)
+	return nil
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"github.com/rivo/tview"
+)
+
+// registerWorkPackageActions sets the actions on the selected work package.
+func (tui *Tui) registerWorkPackageActions(client Backend, userId int, wp *WorkPackage) {
+	tui.registerAction(actionChangeStatus, actionHandler{
+		run: func(args []string) {
+			if len(args) == 0 {
+				tui.showStatusSwitcher(client, userId, wp)
+				return
+			}
+			statuses, err := client.ListStatuses(wp.Id)
+			if err != nil {
+				tui.NotifyError(err)
+				return
+			}
+			for _, status := range statuses {
+				if status.Name == args[0] {
+					tui.changeStatus(client, userId, wp, status)
+					return
+				}
+			}
+			tui.NotifyError(fmt.Errorf("%s can't be set to %q", wp.Subject, args[0]))
+		},
+		prompts: []actionPrompt{{label: "Status", choices: func() []string {
+			statuses, err := client.ListStatuses(wp.Id)
+			if err != nil {
+				tui.NotifyError(err)
+				return nil
+			}
+			names := make([]string, len(statuses))
+			for i, status := range statuses {
+				names[i] = status.Name
+			}
+			return names
+		}}},
+		available: tui.navigationShown,
+	})
+}
+
+// showStatusSwitcher lists the statuses a work package can be set to, and sets the one the user picks.
+func (tui *Tui) showStatusSwitcher(client Backend, userId int, wp *WorkPackage) {
+	statuses, err := client.ListStatuses(wp.Id)
+	if err != nil {
+		tui.NotifyError(err)
+		return
+	}
+	returnFocus := tui.App.GetFocus()
+	closeList := func() {
+		tui.Pages.RemovePage("statuses")
+		tui.App.SetFocus(returnFocus)
+	}
+
+	list := tview.NewList().ShowSecondaryText(false)
+	for i, status := range statuses {
+		status := status
+		list.AddItem(tview.Escape(status.Name), "", 0, func() {
+			closeList()
+			tui.changeStatus(client, userId, wp, status)
+		})
+		if status.Name == wp.Links.Status.Title {
+			list.SetCurrentItem(i)
+		}
+	}
+	list.SetDoneFunc(closeList)
+
+	list.SetBorder(true).SetTitle("Change Status").SetTitleAlign(tview.AlignCenter)
+
+	tui.Pages.AddPage("statuses", tui.Modal(list, 45, len(statuses)+2), true, true)
+}
+
+// changeStatus sets the status of a work package and lists the work packages again: a closed one is no longer listed.
+func (tui *Tui) changeStatus(client Backend, userId int, wp *WorkPackage, status Status) {
+	if status.Name == wp.Links.Status.Title {
+		return
+	}
+	if err := client.UpdateWorkPackageStatus(wp.Id, status.Id); err != nil {
+		tui.ShowError(err)
+		return
+	}
+	tui.refreshWorkPackages(client, userId)
+	tui.Notify(fmt.Sprintf("%s is now %s.", wp.Subject, status.Name))
+}
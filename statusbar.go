@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"github.com/rivo/tview"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	// notificationDuration is how long a notification stays in the status bar.
+	notificationDuration = 5 * time.Second
+
+	// lastErrorLength is the number of characters of the last error shown in the status bar.
+	lastErrorLength = 40
+)
+
+// SetupStatusBar shows who is logged in, on which instance and with which profile, in the status bar.
+func (tui *Tui) SetupStatusBar(user *User, profileName, instance string) {
+	tui.user, tui.profileName, tui.instance = user, profileName, instance
+	tui.renderStatusBar()
+}
+
+func (tui *Tui) renderStatusBar() {
+	if tui.user == nil {
+		// The work packages are set up before the status bar.
+		return
+	}
+	parts := []string{
+		fmt.Sprintf("%s @ %s", styled(styleLabel, tview.Escape(tui.user.Name)), tview.Escape(tui.instance)),
+		"profile " + styled(styleAccent, tview.Escape(tui.profileName)),
+		fmt.Sprintf("today %s", styled(styleAccent, tui.todayTotal.ToString())),
+		fmt.Sprintf("week %s", styled(styleAccent, tui.weekTotal.ToString())),
+	}
+	if tui.timer != "" {
+		parts = append(parts, styled(styleWarning, "timer on "+tview.Escape(tui.timer)))
+	}
+	tui.StatusBar.SetText(" " + strings.Join(parts, " — ") + syncStatusText(tui.syncStatus))
+}
+
+// updateTotals shows the time logged today and this week in the status bar, and the work package of the timer
+// started in OpenProject, if any.
+func (tui *Tui) updateTotals(client Backend, userId int) {
+	now := time.Now()
+	monday := startOfWeek(now)
+	timeEntries, err := client.ListTimeEntriesBetween(userId, monday, monday.AddDate(0, 0, 6))
+	if err != nil {
+		// Keep the previous totals.
+		return
+	}
+	today := now.Format("2006-01-02")
+	var todays []TimeEntry
+	tui.timer = ""
+	for _, te := range timeEntries.Embedded.Elements {
+		if te.Date == today {
+			todays = append(todays, te)
+		}
+		if te.Ongoing {
+			tui.timer = fmt.Sprintf("#%d", idFromHref(te.Links.WorkPackage.Href))
+			if title := te.Links.WorkPackage.Title; title != "" {
+				tui.timer += " " + title
+			}
+		}
+	}
+	tui.todayTotal, tui.weekTotal = totalHours(todays), totalHours(timeEntries.Embedded.Elements)
+	tui.renderStatusBar()
+}
+
+// Notify shows a message in the status bar for a few seconds.
+func (tui *Tui) Notify(message string) {
+	tui.showNotification(styled(styleAccent, tview.Escape(singleLine(message))))
+}
+
+// NotifyError shows an error that doesn't need to be acknowledged in the status bar for a few seconds, then as the last
+// error.
+func (tui *Tui) NotifyError(err error) {
+	tui.setLastError(err)
+	tui.showNotification(styled(styleError, tview.Escape(singleLine(err.Error()))))
+}
+
+func (tui *Tui) showNotification(text string) {
+	tui.notificationId++
+	id := tui.notificationId
+	tui.notification = text
+	tui.renderStatusMessage()
+	time.AfterFunc(notificationDuration, func() {
+		tui.App.QueueUpdateDraw(func() {
+			if tui.notificationId == id {
+				tui.notification = ""
+				tui.renderStatusMessage()
+			}
+		})
+	})
+}
+
+// setLastError keeps an error to show it in the status bar once the notifications are gone. It is shortened not to hide
+// the rest of the status bar.
+func (tui *Tui) setLastError(err error) {
+	message := []rune(singleLine(err.Error()))
+	if len(message) > lastErrorLength {
+		message = append(message[:lastErrorLength-1], '…')
+	}
+	tui.lastError = fmt.Sprintf("last error at %s: %s", time.Now().Format("15:04"), string(message))
+	tui.renderStatusMessage()
+}
+
+// renderStatusMessage shows the current notification, or else the last error, at the right of the status bar.
+func (tui *Tui) renderStatusMessage() {
+	text := tui.notification
+	if text == "" && tui.lastError != "" {
+		text = styled(styleMuted, tview.Escape(tui.lastError))
+	}
+	if text != "" {
+		text = " " + text + " "
+	}
+	tui.statusMessage.SetText(text)
+	tui.statusFlex.ResizeItem(tui.statusMessage, tview.TaggedStringWidth(text), 0)
+}
+
+// singleLine joins the lines of a message, for the status bar.
+func singleLine(message string) string {
+	return strings.Join(strings.Fields(message), " ")
+}
+
+// instanceHost returns the host of an instance, as shown in the status bar.
+func instanceHost(baseURL string) string {
+	u, err := url.Parse(baseURL)
+	if err != nil || u.Host == "" {
+		return baseURL
+	}
+	return u.Host
+}
@@ -0,0 +1,467 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of pending operations.
+const (
+	pendingCreate = "create"
+	pendingUpdate = "update"
+	pendingDelete = "delete"
+)
+
+// PendingOperation is a change to a time entry made while offline, waiting to be replayed on the server.
+type PendingOperation struct {
+	Id   int    `json:"id"`
+	Kind string `json:"kind"`
+	// TimeEntryId is negative for time entries created offline.
+	TimeEntryId int                    `json:"time_entry_id"`
+	Request     *TimeEntryRequest      `json:"request,omitempty"`
+	Update      map[string]interface{} `json:"update,omitempty"`
+	// Base is the time entry as it was last fetched, to detect whether it changed on the server in the meantime.
+	Base     *TimeEntry `json:"base,omitempty"`
+	QueuedAt time.Time  `json:"queued_at"`
+	// Conflict is why the operation couldn't be replayed. It waits until the user resolves it.
+	Conflict string `json:"conflict,omitempty"`
+}
+
+// Store is the local copy of the data fetched last for a profile, used when the server can't be reached, and the
+// queue of the changes made in the meantime. It is saved in `$XDG_STATE_HOME/lazyop/store/<profile>.json`.
+//
+// Several processes may use the store of a profile at once, e.g. the UI and `lazyop sync`: each change is made under
+// a lock on `<profile>.json.lock`, to what the file holds at the time, so that none is lost.
+type Store struct {
+	path string
+
+	// mu guards data and loaded, as requests may be made concurrently.
+	mu   sync.Mutex
+	data storeData
+	// loaded is the file data was read from or written to last, to tell whether another process changed it since.
+	loaded os.FileInfo
+}
+
+type storeData struct {
+	User                 *User               `json:"user"`
+	AssignedWorkPackages []WorkPackage       `json:"assigned_work_packages"`
+	WorkPackages         map[int]WorkPackage `json:"work_packages"`
+	TimeEntries          map[int]TimeEntry   `json:"time_entries"`
+	Pending              []PendingOperation  `json:"pending"`
+	// Mirror is what the store mirrors, see `Client.Sync`.
+	Mirror *mirrorState `json:"mirror"`
+	// LastId is the last ID given to a pending operation or, negated, to a time entry created offline.
+	LastId int `json:"last_id"`
+}
+
+// OpenStore loads the store of a profile. A missing store is empty.
+func OpenStore(profileName string) (*Store, error) {
+	s := &Store{path: filepath.Join(stateHome(), "lazyop", "store", profileName+".json")}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return nil, fmt.Errorf("error reading local store: %v", err)
+	}
+	unlock, err := lockFile(s.path + ".lock")
+	if err != nil {
+		return nil, fmt.Errorf("error locking local store: %v", err)
+	}
+	defer unlock()
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// load reads the store from its file, unless it is unchanged since it was last read or written. A missing file is an
+// empty store. The store must be locked.
+func (s *Store) load() error {
+	info, err := os.Stat(s.path)
+	unchanged := err == nil && s.loaded != nil && os.SameFile(info, s.loaded) &&
+		info.ModTime().Equal(s.loaded.ModTime()) && info.Size() == s.loaded.Size()
+	if unchanged {
+		return nil
+	}
+	var data storeData
+	if err == nil {
+		content, err := os.ReadFile(s.path)
+		if err != nil {
+			return fmt.Errorf("error reading local store: %v", err)
+		}
+		if err := json.Unmarshal(content, &data); err != nil {
+			return fmt.Errorf("error reading local store %s: %v", s.path, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("error reading local store: %v", err)
+	}
+	if data.WorkPackages == nil {
+		data.WorkPackages = make(map[int]WorkPackage)
+	}
+	if data.TimeEntries == nil {
+		data.TimeEntries = make(map[int]TimeEntry)
+	}
+	s.data, s.loaded = data, info
+	return nil
+}
+
+// lock gives exclusive access to the store, to the goroutines of this process and to the other processes, and
+// reloads it if another process changed it. It returns the function that releases it. If the file can't be locked or
+// read, the store is used as it is in memory.
+func (s *Store) lock() func() {
+	s.mu.Lock()
+	unlock, err := lockFile(s.path + ".lock")
+	if err != nil {
+		return s.mu.Unlock
+	}
+	s.load()
+	return func() {
+		unlock()
+		s.mu.Unlock()
+	}
+}
+
+// lockReplay serialises the replays of the pending operations across processes, so that an operation is never sent
+// twice. It returns the function that releases the lock.
+func (s *Store) lockReplay() func() {
+	unlock, err := lockFile(s.path + ".replay.lock")
+	if err != nil {
+		return func() {}
+	}
+	return unlock
+}
+
+// isPending reports whether an operation is still queued, e.g. not replayed by another process in the meantime.
+func (s *Store) isPending(id int) bool {
+	defer s.lock()()
+	for _, op := range s.data.Pending {
+		if op.Id == id {
+			return true
+		}
+	}
+	return false
+}
+
+// save writes the store, replacing the file atomically. The store must be locked.
+func (s *Store) save() error {
+	data, err := json.Marshal(&s.data)
+	if err != nil {
+		return fmt.Errorf("error saving local store: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0o700); err != nil {
+		return fmt.Errorf("error saving local store: %v", err)
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("error saving local store: %v", err)
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		return fmt.Errorf("error saving local store: %v", err)
+	}
+	s.loaded, _ = os.Stat(s.path)
+	return nil
+}
+
+// The local copy is only a cache, so failing to save it is not an error for the caller.
+
+func (s *Store) saveUser(user *User) {
+	defer s.lock()()
+	s.data.User = user
+	s.save()
+}
+
+func (s *Store) user() *User {
+	defer s.lock()()
+	return s.data.User
+}
+
+// saveAssignedWorkPackages replaces the assigned work packages. None is saved as an empty list, not to be taken for
+// work packages that were never fetched.
+func (s *Store) saveAssignedWorkPackages(workPackages []WorkPackage) {
+	defer s.lock()()
+	if workPackages == nil {
+		workPackages = []WorkPackage{}
+	}
+	s.data.AssignedWorkPackages = workPackages
+	for _, wp := range workPackages {
+		s.data.WorkPackages[wp.Id] = wp
+	}
+	s.save()
+}
+
+// assignedWorkPackages returns the stored assigned work packages, and whether they were ever fetched. The collection
+// is empty, not nil, if they weren't.
+func (s *Store) assignedWorkPackages() (*WorkPackageCollection, bool) {
+	defer s.lock()()
+	collection := &WorkPackageCollection{Total: len(s.data.AssignedWorkPackages)}
+	collection.Embedded.Elements = append([]WorkPackage{}, s.data.AssignedWorkPackages...)
+	return collection, s.data.AssignedWorkPackages != nil
+}
+
+func (s *Store) saveWorkPackages(workPackages []WorkPackage) {
+	defer s.lock()()
+	for _, wp := range workPackages {
+		s.data.WorkPackages[wp.Id] = wp
+	}
+	s.save()
+}
+
+func (s *Store) workPackage(id int) *WorkPackage {
+	defer s.lock()()
+	wp, ok := s.data.WorkPackages[id]
+	if !ok {
+		return nil
+	}
+	return &wp
+}
+
+// replaceWorkPackage saves a work package changed by the user, e.g. its status. It is kept among the assigned work
+// packages only if it is still open, as it would be by the next sync.
+func (s *Store) replaceWorkPackage(wp WorkPackage, open bool) {
+	defer s.lock()()
+	s.data.WorkPackages[wp.Id] = wp
+	assigned := s.data.AssignedWorkPackages[:0:0]
+	for _, stored := range s.data.AssignedWorkPackages {
+		switch {
+		case stored.Id != wp.Id:
+			assigned = append(assigned, stored)
+		case open:
+			assigned = append(assigned, wp)
+		}
+	}
+	if s.data.AssignedWorkPackages != nil {
+		s.data.AssignedWorkPackages = assigned
+	}
+	s.save()
+}
+
+// searchWorkPackages returns the stored work packages whose subject contains a text, ignoring case.
+func (s *Store) searchWorkPackages(subject string) *WorkPackageCollection {
+	defer s.lock()()
+	var collection WorkPackageCollection
+	for _, wp := range s.data.WorkPackages {
+		if strings.Contains(strings.ToLower(wp.Subject), strings.ToLower(subject)) {
+			collection.Embedded.Elements = append(collection.Embedded.Elements, wp)
+		}
+	}
+	sort.Slice(collection.Embedded.Elements, func(i, j int) bool {
+		return collection.Embedded.Elements[i].UpdatedAt > collection.Embedded.Elements[j].UpdatedAt
+	})
+	return &collection
+}
+
+// saveTimeEntries replaces the stored time entries selected by match with the fetched ones, so that the entries
+// deleted on the server are forgotten.
+func (s *Store) saveTimeEntries(timeEntries []TimeEntry, match func(te *TimeEntry) bool) {
+	defer s.lock()()
+	for id, te := range s.data.TimeEntries {
+		if match(&te) {
+			delete(s.data.TimeEntries, id)
+		}
+	}
+	for _, te := range timeEntries {
+		s.data.TimeEntries[te.Id] = te
+	}
+	s.save()
+}
+
+// timeEntries returns the stored time entries selected by match, sorted by date.
+func (s *Store) timeEntries(match func(te *TimeEntry) bool) []TimeEntry {
+	defer s.lock()()
+	var timeEntries []TimeEntry
+	for _, te := range s.data.TimeEntries {
+		if match(&te) {
+			timeEntries = append(timeEntries, te)
+		}
+	}
+	sort.Slice(timeEntries, func(i, j int) bool {
+		if timeEntries[i].Date != timeEntries[j].Date {
+			return timeEntries[i].Date < timeEntries[j].Date
+		}
+		return timeEntries[i].Id < timeEntries[j].Id
+	})
+	return timeEntries
+}
+
+// applyPending returns the time entries as they will be once the pending operations are replayed: entries created
+// offline selected by match are added, updates are applied and deleted entries are removed. Changed entries are
+// flagged as pending.
+func (s *Store) applyPending(timeEntries []TimeEntry, match func(te *TimeEntry) bool) []TimeEntry {
+	defer s.lock()()
+	for _, op := range s.data.Pending {
+		switch op.Kind {
+		case pendingCreate:
+			te := op.Request.timeEntry(op.TimeEntryId)
+			if match(&te) {
+				timeEntries = append(timeEntries, te)
+			}
+		case pendingUpdate, pendingDelete:
+			for i := range timeEntries {
+				if timeEntries[i].Id != op.TimeEntryId {
+					continue
+				}
+				if op.Kind == pendingDelete {
+					timeEntries = append(timeEntries[:i], timeEntries[i+1:]...)
+					break
+				}
+				mergeJSON(&timeEntries[i], op.Update)
+				timeEntries[i].Pending = true
+				break
+			}
+		}
+	}
+	sort.SliceStable(timeEntries, func(i, j int) bool {
+		return timeEntries[i].Date < timeEntries[j].Date
+	})
+	return timeEntries
+}
+
+// hasPending reports whether a time entry has pending operations, in which case further changes must be queued
+// after them.
+func (s *Store) hasPending(timeEntryId int) bool {
+	defer s.lock()()
+	for _, op := range s.data.Pending {
+		if op.TimeEntryId == timeEntryId {
+			return true
+		}
+	}
+	return false
+}
+
+// queue adds an operation to the queue and saves it. The store must be locked.
+func (s *Store) queue(op PendingOperation) error {
+	s.data.LastId++
+	op.Id = s.data.LastId
+	op.QueuedAt = time.Now()
+	if base, ok := s.data.TimeEntries[op.TimeEntryId]; ok && op.Kind != pendingCreate {
+		op.Base = &base
+	}
+	s.data.Pending = append(s.data.Pending, op)
+	return s.save()
+}
+
+func (s *Store) queueCreate(te *TimeEntryRequest) error {
+	defer s.lock()()
+	return s.queue(PendingOperation{Kind: pendingCreate, TimeEntryId: -(s.data.LastId + 1), Request: te})
+}
+
+// queueUpdate queues an update. The update of a time entry created offline is merged into its creation.
+func (s *Store) queueUpdate(timeEntryId int, update map[string]interface{}) error {
+	defer s.lock()()
+	if create := s.pendingCreation(timeEntryId); create != nil {
+		if err := mergeJSON(create.Request, update); err != nil {
+			return err
+		}
+		return s.save()
+	}
+	return s.queue(PendingOperation{Kind: pendingUpdate, TimeEntryId: timeEntryId, Update: update})
+}
+
+// queueDelete queues a deletion. A time entry created offline is simply dropped from the queue.
+func (s *Store) queueDelete(timeEntryId int) error {
+	defer s.lock()()
+	if s.pendingCreation(timeEntryId) != nil {
+		s.removePending(func(op *PendingOperation) bool { return op.TimeEntryId == timeEntryId })
+		return s.save()
+	}
+	return s.queue(PendingOperation{Kind: pendingDelete, TimeEntryId: timeEntryId})
+}
+
+// pendingCreation returns the queued creation of a time entry created offline. The store must be locked.
+func (s *Store) pendingCreation(timeEntryId int) *PendingOperation {
+	for i := range s.data.Pending {
+		if op := &s.data.Pending[i]; op.Kind == pendingCreate && op.TimeEntryId == timeEntryId {
+			return op
+		}
+	}
+	return nil
+}
+
+// removePending removes the operations selected by match. The store must be locked.
+func (s *Store) removePending(match func(op *PendingOperation) bool) {
+	kept := s.data.Pending[:0]
+	for _, op := range s.data.Pending {
+		if !match(&op) {
+			kept = append(kept, op)
+		}
+	}
+	s.data.Pending = kept
+}
+
+// pending returns a copy of the queue.
+func (s *Store) pending() []PendingOperation {
+	defer s.lock()()
+	return append([]PendingOperation(nil), s.data.Pending...)
+}
+
+// resolvePending removes a replayed or discarded operation from the queue.
+func (s *Store) resolvePending(id int) error {
+	defer s.lock()()
+	s.removePending(func(op *PendingOperation) bool { return op.Id == id })
+	return s.save()
+}
+
+// setConflict flags an operation that couldn't be replayed.
+func (s *Store) setConflict(id int, conflict string) error {
+	defer s.lock()()
+	for i := range s.data.Pending {
+		if s.data.Pending[i].Id == id {
+			s.data.Pending[i].Conflict = conflict
+		}
+	}
+	return s.save()
+}
+
+// timeEntry returns the time entry that the request will create, with a local ID.
+func (r *TimeEntryRequest) timeEntry(id int) TimeEntry {
+	var te TimeEntry
+	te.Id = id
+	te.Comment.Raw = r.Comment.Raw
+	te.Hours = r.Hours
+	te.Date = r.Date
+	te.Links.WorkPackage.Href = r.Links.WorkPackage.Href
+	te.Links.Activity.Href = r.Links.Activity.Href
+	te.Links.User.Href = r.User.Href
+	te.Pending = true
+	return te
+}
+
+// mergeJSON applies a partial update, as sent to the API, to a value: the attributes present in the update replace
+// those of the value, recursively.
+func mergeJSON(value interface{}, update map[string]interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(data, &document); err != nil {
+		return err
+	}
+	mergeMaps(document, update)
+	if data, err = json.Marshal(document); err != nil {
+		return err
+	}
+	return json.Unmarshal(data, value)
+}
+
+func mergeMaps(target, update map[string]interface{}) {
+	for key, value := range update {
+		// Updates are built with various map types, normalise them through JSON first.
+		if data, err := json.Marshal(value); err == nil {
+			var normalised interface{}
+			if json.Unmarshal(data, &normalised) == nil {
+				value = normalised
+			}
+		}
+		nested, isMap := value.(map[string]interface{})
+		existing, wasMap := target[key].(map[string]interface{})
+		if isMap && wasMap {
+			mergeMaps(existing, nested)
+		} else {
+			target[key] = value
+		}
+	}
+}
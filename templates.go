@@ -0,0 +1,264 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"github.com/rivo/tview"
+	"strings"
+	"time"
+)
+
+// Template is a recurring time entry defined in the config file.
+type Template struct {
+	WorkPackageId int    `json:"work_package"`
+	ActivityId    int    `json:"activity"`
+	Duration      string `json:"duration"`
+	Comment       string `json:"comment"`
+	// Recurrence is `daily`, `weekdays`, `weekends` or `every` followed by weekday names or ranges, e.g.
+	// `every Monday, Thursday` or `every Mon-Wed`.
+	Recurrence string `json:"recurrence"`
+}
+
+// parseRecurrence returns the weekdays on which a recurrence rule applies.
+func parseRecurrence(rule string) (map[time.Weekday]bool, error) {
+	days := make(map[time.Weekday]bool)
+	normalized := strings.ToLower(strings.TrimSpace(rule))
+	switch normalized {
+	case "daily", "every day":
+		for day := time.Sunday; day <= time.Saturday; day++ {
+			days[day] = true
+		}
+	case "weekdays", "every weekday":
+		for day := time.Monday; day <= time.Friday; day++ {
+			days[day] = true
+		}
+	case "weekends", "every weekend":
+		days[time.Saturday] = true
+		days[time.Sunday] = true
+	default:
+		if !strings.HasPrefix(normalized, "every ") {
+			return nil, fmt.Errorf("invalid recurrence %q", rule)
+		}
+		names := strings.FieldsFunc(strings.TrimPrefix(normalized, "every "), func(r rune) bool {
+			return r == ',' || r == ' '
+		})
+		for _, name := range names {
+			if name == "and" {
+				continue
+			}
+			first, last, isRange := strings.Cut(name, "-")
+			if !isRange {
+				last = first
+			}
+			from, ok := parseWeekday(first)
+			to, ok2 := parseWeekday(last)
+			if !ok || !ok2 {
+				return nil, fmt.Errorf("invalid weekday %q in recurrence %q", name, rule)
+			}
+			// A range may wrap around the end of the week, e.g. `fri-mon`.
+			for day := from; ; day = (day + 1) % 7 {
+				days[day] = true
+				if day == to {
+					break
+				}
+			}
+		}
+		if len(days) == 0 {
+			return nil, fmt.Errorf("invalid recurrence %q", rule)
+		}
+	}
+	return days, nil
+}
+
+// parseWeekday parses a lower-case weekday name, either in full (`monday`, `mondays`) or abbreviated (`mon`).
+func parseWeekday(name string) (time.Weekday, bool) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		full := strings.ToLower(day.String())
+		if name == full || name == full+"s" || name == full[:3] {
+			return day, true
+		}
+	}
+	return 0, false
+}
+
+// materializeTemplates returns the time entries that the templates define between two dates (inclusive). Invalid
+// templates are returned as a single row with an error.
+func materializeTemplates(templates []Template, start, end time.Time) []ImportRow {
+	var rows []ImportRow
+	for i, template := range templates {
+		row := ImportRow{
+			Line:          i + 1,
+			WorkPackageId: template.WorkPackageId,
+			ActivityId:    template.ActivityId,
+			Comment:       template.Comment,
+		}
+		if row.ActivityId == 0 {
+			row.ActivityId = defaultActivityId
+		}
+
+		days, err := parseRecurrence(template.Recurrence)
+		if err == nil && template.WorkPackageId == 0 {
+			err = fmt.Errorf("missing work package")
+		}
+		var duration *Duration
+		if err == nil {
+			duration, err = parseImportDuration(template.Duration)
+		}
+		if err != nil {
+			row.Err = err
+			rows = append(rows, row)
+			continue
+		}
+		row.Duration = *duration
+
+		for date := start; !date.After(end); date = date.AddDate(0, 0, 1) {
+			if days[date.Weekday()] {
+				row.Date = date.Format("2006-01-02")
+				rows = append(rows, row)
+			}
+		}
+	}
+	return rows
+}
+
+// templateEntryKey identifies the time entry a template logs on a day. The duration is left out: it may have been
+// edited since the template was logged.
+func templateEntryKey(workPackageId int, date string, activityId int, comment string) string {
+	return fmt.Sprintf("%d|%s|%d|%s", workPackageId, date, activityId, strings.TrimSpace(comment))
+}
+
+// markLoggedTemplateRows flags the rows of templates that have already been logged by the user, whatever their
+// duration.
+func markLoggedTemplateRows(client Backend, userId int, rows []ImportRow) error {
+	start, end := importRowsPeriod(rows)
+	if start.IsZero() {
+		return nil
+	}
+
+	timeEntries, err := client.ListTimeEntriesBetween(userId, start, end)
+	if err != nil {
+		return err
+	}
+	logged := make(map[string]bool)
+	for _, te := range timeEntries.Embedded.Elements {
+		workPackageId, activityId := idFromHref(te.Links.WorkPackage.Href), idFromHref(te.Links.Activity.Href)
+		logged[templateEntryKey(workPackageId, te.Date, activityId, te.Comment.Raw)] = true
+	}
+	for i := range rows {
+		row := &rows[i]
+		if row.Err == nil {
+			row.Exists = logged[templateEntryKey(row.WorkPackageId, row.Date, row.ActivityId, row.Comment)]
+		}
+	}
+	return nil
+}
+
+// timeEntry returns the time entry that would be created for the row.
+func (row *ImportRow) timeEntry() TimeEntry {
+	var te TimeEntry
+	te.Hours = row.Duration.ToIso8601String()
+	te.Date = row.Date
+	te.Comment.Raw = row.Comment
+	te.Links.WorkPackage.Href = workPackageHref(row.WorkPackageId)
+	te.Links.Activity.Href = activityHref(row.ActivityId)
+	return te
+}
+
+// runTimeTemplates implements `lazyop time templates [flags]`.
+func runTimeTemplates(client Backend, config *Config, args []string) error {
+	flags := flag.NewFlagSet("time templates", flag.ExitOnError)
+	date := flags.String("date", time.Now().Format("2006-01-02"), "day to log the templates on")
+	week := flags.Bool("week", false, "log the templates for the whole week (Monday to Sunday) of -date")
+	dryRun := flags.Bool("dry-run", false, "only show what would be logged")
+	yes := flags.Bool("yes", false, "log without asking for confirmation")
+	flags.Parse(args)
+
+	if len(config.Templates) == 0 {
+		return fmt.Errorf("no templates defined in the config file")
+	}
+	start, err := time.Parse("2006-01-02", *date)
+	if err != nil {
+		return fmt.Errorf("invalid date: %v", err)
+	}
+	end := start
+	if *week {
+		start = startOfWeek(start)
+		end = start.AddDate(0, 0, 6)
+	}
+
+	rows := materializeTemplates(config.Templates, start, end)
+	roundImportRows(rows, &config.Rounding)
+	if err := markLoggedTemplateRows(client, config.UserID, rows); err != nil {
+		return err
+	}
+	return createImportRows(client, config.UserID, "template", rows, *dryRun, *yes)
+}
+
+// SetupTemplates sets the recurring time entries that can be logged from the time entries table.
+func (tui *Tui) SetupTemplates(templates []Template) {
+	tui.templates = templates
+}
+
+func (tui *Tui) showApplyTemplatesMenu(client Backend, userId int, workPackageIndex int) {
+	if len(tui.templates) == 0 {
+		tui.Notify("No templates defined in the config file.")
+		return
+	}
+
+	closeMenu := func() {
+		tui.Pages.RemovePage("templatesMenu")
+		tui.App.SetFocus(tui.TimeEntriesTable)
+	}
+
+	list := tview.NewList().ShowSecondaryText(false)
+	list.AddItem("Today", "", 't', func() {
+		closeMenu()
+		today := time.Now()
+		tui.applyTemplates(client, userId, workPackageIndex, "today", today, today)
+	})
+	list.AddItem("This week", "", 'w', func() {
+		closeMenu()
+		monday := startOfWeek(time.Now())
+		tui.applyTemplates(client, userId, workPackageIndex, "this week", monday, monday.AddDate(0, 0, 6))
+	})
+	list.SetDoneFunc(closeMenu)
+
+	list.SetBorder(true).SetTitle("Log Templates").SetTitleAlign(tview.AlignCenter)
+
+	tui.Pages.AddPage("templatesMenu", tui.Modal(list, 45, 4), true, true)
+}
+
+// applyTemplates logs, after confirmation, the templated time entries between two dates that don't exist yet.
+func (tui *Tui) applyTemplates(client Backend, userId int, workPackageIndex int, label string, start, end time.Time) {
+	rows := materializeTemplates(tui.templates, start, end)
+	roundImportRows(rows, &tui.rounding)
+	if err := markLoggedTemplateRows(client, userId, rows); err != nil {
+		tui.NotifyError(err)
+		return
+	}
+
+	var entries []TimeEntry
+	invalid := 0
+	for _, row := range rows {
+		switch {
+		case row.Err != nil:
+			invalid++
+		case !row.Exists:
+			entries = append(entries, row.timeEntry())
+		}
+	}
+	if len(entries) == 0 {
+		tui.Notify(fmt.Sprintf("All templates for %s are already logged (%d invalid).", label, invalid))
+		return
+	}
+
+	text := fmt.Sprintf("Log %d time entries from templates for %s?", len(entries), label)
+	if invalid > 0 {
+		text += fmt.Sprintf(" %d invalid templates will be skipped.", invalid)
+	}
+	tui.showBulkConfirm(text, func() {
+		tui.runBulk("Logging templates", entries, workPackageIndex, func(te TimeEntry) error {
+			return createTimeEntryCopy(client, userId, te)
+		})
+	})
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRecurrence(t *testing.T) {
+	for _, test := range []struct {
+		rule string
+		// want are the first letters of the days, from Sunday to Saturday, or the error.
+		want string
+	}{
+		{"daily", "SMTWTFS"},
+		{"Every Day", "SMTWTFS"},
+		{"weekdays", "-MTWTF-"},
+		{" every weekday ", "-MTWTF-"},
+		{"weekends", "S-----S"},
+		{"every Monday", "-M-----"},
+		{"every mondays", "-M-----"},
+		{"every Monday, Thursday", "-M--T--"},
+		{"every tue,thu and sat", "--T-T-S"},
+		{"every Mon-Wed", "-MTW---"},
+		{"every mon-wed, fri", "-MTW-F-"},
+		{"every fri-mon", "SM---FS"},
+		{"every sunday-saturday", "SMTWTFS"},
+		{"every wed-wed", "---W---"},
+		{"", `invalid recurrence ""`},
+		{"monthly", `invalid recurrence "monthly"`},
+		{"monday", `invalid recurrence "monday"`},
+		{"every", `invalid recurrence "every"`},
+		{"every and", `invalid recurrence "every and"`},
+		{"every funday", `invalid weekday "funday" in recurrence "every funday"`},
+		{"every mon-funday", `invalid weekday "mon-funday" in recurrence "every mon-funday"`},
+		{"every mon-", `invalid weekday "mon-" in recurrence "every mon-"`},
+	} {
+		days, err := parseRecurrence(test.rule)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		} else {
+			for day := time.Sunday; day <= time.Saturday; day++ {
+				if days[day] {
+					got += day.String()[:1]
+				} else {
+					got += "-"
+				}
+			}
+		}
+		if got != test.want {
+			t.Errorf("parseRecurrence(%q) = %s, want %s", test.rule, got, test.want)
+		}
+	}
+}
+
+func TestTimeTemplatesCommandAfterEditingHours(t *testing.T) {
+	silenceOutput(t)
+	backend := newTestMemoryBackend()
+	wp := backend.AddWorkPackage(WorkPackage{Subject: "Standup"}, 1, false)
+	config := &Config{
+		Profile:   Profile{UserID: 1},
+		Templates: []Template{{WorkPackageId: wp.Id, ActivityId: 3, Duration: "15m", Comment: "Daily", Recurrence: "weekdays"}},
+	}
+	args := []string{"time", "templates", "-yes", "-week", "-date", "2024-05-06"}
+	if err := runCommand(backend, config, args); err != nil {
+		t.Fatal(err)
+	}
+
+	// The standup ran long on Monday.
+	timeEntries, _ := backend.ListTimeEntries(wp.Id)
+	if len(timeEntries.Embedded.Elements) != 5 {
+		t.Fatalf("%d time entries logged from the template, want 5", len(timeEntries.Embedded.Elements))
+	}
+	var monday TimeEntry
+	for _, te := range timeEntries.Embedded.Elements {
+		if te.Date == "2024-05-06" {
+			monday = te
+		}
+	}
+	if err := backend.UpdateTimeEntry(monday.Id, map[string]interface{}{"hours": "PT30M"}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := runCommand(backend, config, args); err != nil {
+		t.Fatal(err)
+	}
+	all, _ := backend.ListTimeEntriesBetween(1, time.Date(2024, 5, 6, 0, 0, 0, 0, time.UTC), time.Date(2024, 5, 12, 0, 0, 0, 0, time.UTC))
+	if len(all.Embedded.Elements) != 5 {
+		t.Errorf("%d time entries after applying the templates again, want 5", len(all.Embedded.Elements))
+	}
+}
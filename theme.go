@@ -0,0 +1,330 @@
+package main
+
+import (
+	"fmt"
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Style names.
+const (
+	styleText           = "text"
+	styleHeader         = "header"
+	styleAccent         = "accent"
+	styleLabel          = "label"
+	styleMuted          = "muted"
+	styleWarning        = "warning"
+	styleError          = "error"
+	styleMissingComment = "missing_comment"
+	styleSelection      = "selection"
+	styleField          = "field"
+)
+
+// defaultThemeName is the theme used when the config file doesn't select one.
+const defaultThemeName = "dark"
+
+// noColorThemeName is the theme forced by the `NO_COLOR` environment variable, see https://no-color.org.
+const noColorThemeName = "no-color"
+
+// builtinThemes are the themes that can be selected without defining them. Every style is set.
+var builtinThemes = map[string]map[string]string{
+	"dark": {
+		styleText:           "white:black",
+		styleHeader:         "white:navy",
+		styleAccent:         "yellow",
+		styleLabel:          "green",
+		styleMuted:          "gray",
+		styleWarning:        "orange",
+		styleError:          "red",
+		styleMissingComment: "red",
+		styleSelection:      "black:white",
+		styleField:          "white:blue",
+	},
+	"light": {
+		styleText:           "black:white",
+		styleHeader:         "black:silver",
+		styleAccent:         "darkblue",
+		styleLabel:          "darkgreen",
+		styleMuted:          "gray",
+		styleWarning:        "darkorange",
+		styleError:          "red",
+		styleMissingComment: "red",
+		styleSelection:      "white:blue",
+		styleField:          "black:lightgray",
+	},
+	"high-contrast": {
+		styleText:           "white:black",
+		styleHeader:         "white:navy:b",
+		styleAccent:         "yellow::b",
+		styleLabel:          "aqua::b",
+		styleMuted:          "white",
+		styleWarning:        "fuchsia::b",
+		styleError:          "red::b",
+		styleMissingComment: "red::bu",
+		styleSelection:      "black:yellow",
+		styleField:          "black:white",
+	},
+	noColorThemeName: {
+		styleText:           "-:-",
+		styleHeader:         "::r",
+		styleAccent:         "::b",
+		styleLabel:          "::b",
+		styleMuted:          "::d",
+		styleWarning:        "::b",
+		styleError:          "::bu",
+		styleMissingComment: "::u",
+		styleSelection:      "::r",
+		styleField:          "::u",
+	},
+}
+
+// Theme is a user theme, defined in the `themes` section of the config file.
+//
+// Styles are written like the color tags of tview, `foreground:background:attributes`, each part being optional: a
+// color name (`yellow`, `darkblue`…), a `#rrggbb` value or `-` for the terminal's default, and attribute letters (`b`
+// bold, `d` dim, `i` italic, `l` blink, `r` reverse, `s` strikethrough, `u` underline). For example `yellow`,
+// `black:white` or `::b`.
+type Theme struct {
+	// Base is the built-in theme the styles that aren't set are taken from, `dark` by default.
+	Base string `json:"base"`
+
+	// Styles maps style names to styles.
+	Styles map[string]string `json:"styles"`
+}
+
+// palette holds the styles of all the names of a theme.
+type palette map[string]themeStyle
+
+type themeStyle struct {
+	// spec is the style as written in the theme, and used as a color tag.
+	spec  string
+	style tcell.Style
+}
+
+// theme is the palette the UI is drawn with.
+var theme = mustPalette(builtinThemes[defaultThemeName])
+
+// UseTheme selects the theme the UI is drawn with: one of themes, a built-in theme, or `dark` if name is empty. The
+// `no-color` theme is used instead if the `NO_COLOR` environment variable is set. It must be called before the UI is
+// built, as tview reads its default colors when creating the views.
+func UseTheme(name string, themes map[string]Theme) error {
+	if os.Getenv("NO_COLOR") != "" {
+		name, themes = noColorThemeName, nil
+	}
+	p, err := resolveTheme(name, themes)
+	if err != nil {
+		return err
+	}
+	theme = p
+
+	textFg, textBg, _ := p.style(styleText).Decompose()
+	_, fieldBg, _ := p.style(styleField).Decompose()
+	selectionFg, selectionBg, _ := p.style(styleSelection).Decompose()
+	tview.Styles = tview.Theme{
+		PrimitiveBackgroundColor:    textBg,
+		ContrastBackgroundColor:     fieldBg,
+		MoreContrastBackgroundColor: selectionBg,
+		BorderColor:                 textFg,
+		TitleColor:                  textFg,
+		GraphicsColor:               textFg,
+		PrimaryTextColor:            textFg,
+		SecondaryTextColor:          p.color(styleAccent),
+		TertiaryTextColor:           p.color(styleLabel),
+		InverseTextColor:            selectionFg,
+		ContrastSecondaryTextColor:  p.color(styleMuted),
+	}
+	return nil
+}
+
+// ValidateThemes checks the user themes and that the selected theme exists.
+func ValidateThemes(name string, themes map[string]Theme) error {
+	names := make([]string, 0, len(themes))
+	for themeName := range themes {
+		names = append(names, themeName)
+	}
+	sort.Strings(names)
+	for _, themeName := range names {
+		if _, err := resolveTheme(themeName, themes); err != nil {
+			return err
+		}
+	}
+	_, err := resolveTheme(name, themes)
+	return err
+}
+
+// resolveTheme returns the styles of a theme, user themes taking precedence over the built-in ones.
+func resolveTheme(name string, themes map[string]Theme) (palette, error) {
+	if name == "" {
+		name = defaultThemeName
+	}
+	user, ok := themes[name]
+	if !ok {
+		styles, ok := builtinThemes[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown theme %q", name)
+		}
+		return newPalette(styles)
+	}
+
+	base := user.Base
+	if base == "" {
+		base = defaultThemeName
+	}
+	styles := make(map[string]string)
+	baseStyles, ok := builtinThemes[base]
+	if !ok {
+		return nil, fmt.Errorf("theme %s: unknown base theme %q", name, base)
+	}
+	for style, spec := range baseStyles {
+		styles[style] = spec
+	}
+	for style, spec := range user.Styles {
+		if _, ok := styles[style]; !ok {
+			return nil, fmt.Errorf("theme %s: unknown style %q", name, style)
+		}
+		styles[style] = spec
+	}
+	p, err := newPalette(styles)
+	if err != nil {
+		return nil, fmt.Errorf("theme %s: %v", name, err)
+	}
+	return p, nil
+}
+
+// newPalette parses the styles of a theme.
+func newPalette(styles map[string]string) (palette, error) {
+	p := make(palette)
+	for style, spec := range styles {
+		parsed, err := parseStyle(spec)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", style, err)
+		}
+		p[style] = themeStyle{spec: spec, style: parsed}
+	}
+	return p, nil
+}
+
+func mustPalette(styles map[string]string) palette {
+	p, err := newPalette(styles)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// styleAttributes are the attribute letters of the styles.
+var styleAttributes = map[rune]tcell.AttrMask{
+	'b': tcell.AttrBold,
+	'd': tcell.AttrDim,
+	'i': tcell.AttrItalic,
+	'l': tcell.AttrBlink,
+	'r': tcell.AttrReverse,
+	's': tcell.AttrStrikeThrough,
+	'u': tcell.AttrUnderline,
+}
+
+// parseStyle parses a style written as `foreground:background:attributes`.
+func parseStyle(spec string) (tcell.Style, error) {
+	parts := strings.Split(spec, ":")
+	if len(parts) > 3 {
+		return tcell.StyleDefault, fmt.Errorf("invalid style %q", spec)
+	}
+	colors := [2]tcell.Color{tcell.ColorDefault, tcell.ColorDefault}
+	for i := 0; i < len(parts) && i < 2; i++ {
+		color, err := parseColor(parts[i])
+		if err != nil {
+			return tcell.StyleDefault, err
+		}
+		colors[i] = color
+	}
+	var attributes tcell.AttrMask
+	if len(parts) == 3 && parts[2] != "-" {
+		for _, letter := range parts[2] {
+			attribute, ok := styleAttributes[letter]
+			if !ok {
+				return tcell.StyleDefault, fmt.Errorf("invalid attribute %q in style %q", letter, spec)
+			}
+			attributes |= attribute
+		}
+	}
+	return tcell.StyleDefault.Foreground(colors[0]).Background(colors[1]).Attributes(attributes), nil
+}
+
+// parseColor parses a color name, a `#rrggbb` value, or `-` or nothing for the default color.
+func parseColor(name string) (tcell.Color, error) {
+	switch {
+	case name == "" || name == "-":
+		return tcell.ColorDefault, nil
+	case strings.HasPrefix(name, "#") && len(name) == 7:
+		if color := tcell.GetColor(name); color != tcell.ColorDefault {
+			return color, nil
+		}
+	default:
+		if color, ok := tcell.ColorNames[strings.ToLower(name)]; ok {
+			return color, nil
+		}
+	}
+	return tcell.ColorDefault, fmt.Errorf("invalid color %q", name)
+}
+
+// style returns a style of the palette.
+func (p palette) style(name string) tcell.Style {
+	return p[name].style
+}
+
+// color returns the foreground color of a style.
+func (p palette) color(name string) tcell.Color {
+	fg, _, _ := p[name].style.Decompose()
+	return fg
+}
+
+// textStyle returns a style for text drawn on the background of the views, e.g. in table cells: unless the style has
+// its own background, that of the views is kept.
+func (p palette) textStyle(name string) tcell.Style {
+	style := p[name].style
+	if _, bg, _ := style.Decompose(); bg == tcell.ColorDefault {
+		style = style.Background(tview.Styles.PrimitiveBackgroundColor)
+	}
+	return style
+}
+
+// styled returns text with the color tags of a style of the current theme, for views with dynamic colors.
+func styled(name, text string) string {
+	spec := theme[name].spec
+	if spec == "" {
+		return text
+	}
+	return "[" + spec + "]" + text + "[-:-:-]"
+}
+
+// apply sets the styles of a view, and of the views it contains, that tview doesn't take from `tview.Styles`. The
+// pages of a `tview.Pages` can't be reached from it, so they must be styled before they are added, as `Modal` does.
+func (p palette) apply(view tview.Primitive) {
+	switch v := view.(type) {
+	case *tview.List:
+		v.SetSelectedStyle(p.style(styleSelection))
+	case *tview.Table:
+		v.SetSelectedStyle(p.style(styleSelection))
+	case *tview.InputField:
+		v.SetFieldStyle(p.style(styleField))
+	case *tview.Form:
+		fieldFg, fieldBg, _ := p.style(styleField).Decompose()
+		v.SetLabelColor(p.color(styleAccent)).
+			SetFieldTextColor(fieldFg).
+			SetFieldBackgroundColor(fieldBg).
+			SetButtonStyle(p.style(styleField)).
+			SetButtonActivatedStyle(p.style(styleSelection))
+	case *tview.Modal:
+		v.SetButtonStyle(p.style(styleField)).
+			SetButtonActivatedStyle(p.style(styleSelection))
+	case *tview.Flex:
+		for i := 0; i < v.GetItemCount(); i++ {
+			p.apply(v.GetItem(i))
+		}
+	case *tview.Frame:
+		p.apply(v.GetPrimitive())
+	}
+}
@@ -5,9 +5,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"strconv"
+	"strings"
 	"time"
 )
 
+// defaultActivityId is the activity of new time entries unless another one is chosen.
+const defaultActivityId = 1
+
 var (
 	// filterTimeEntriesBefore is a filter to get time entries between two dates.
 	filterTimeEntriesBefore = "[{\"user\":{\"operator\":\"=\",\"values\":[\"%d\"]}},{\"spent_on\":{\"operator\":\"<>d\",\"values\": [\"%s\",\"%s\"]}}]\n"
@@ -18,6 +23,8 @@ var (
 
 // TimeEntryCollection represents a collection of time entries.
 type TimeEntryCollection struct {
+	// Total is the number of time entries matching the filters, across all pages.
+	Total    int `json:"total"`
 	Embedded struct {
 		Elements []TimeEntry `json:"elements"`
 	} `json:"_embedded"`
@@ -29,14 +36,28 @@ type TimeEntry struct {
 	Comment struct {
 		Raw string `json:"raw"`
 	} `json:"comment"`
-	Hours string `json:"hours"`
-	Date  string `json:"spentOn"`
-	Links struct {
+	Hours     string `json:"hours"`
+	Date      string `json:"spentOn"`
+	UpdatedAt string `json:"updatedAt"`
+	Links     struct {
 		WorkPackage struct {
 			Href  string `json:"href"`
 			Title string `json:"title"`
-		}
+		} `json:"workPackage"`
+		Activity struct {
+			Href  string `json:"href"`
+			Title string `json:"title"`
+		} `json:"activity"`
+		User struct {
+			Href string `json:"href"`
+		} `json:"user"`
 	} `json:"_links"`
+
+	// Ongoing is set for the time entry of a timer started in OpenProject that is still running.
+	Ongoing bool `json:"ongoing"`
+
+	// Pending is set for time entries with changes made offline that are not on the server yet.
+	Pending bool `json:"-"`
 }
 
 // TimeEntryRequest represents a request to create a new time entry.
@@ -44,87 +65,214 @@ type TimeEntryRequest struct {
 	Comment struct {
 		Raw string `json:"raw"`
 	} `json:"comment"`
-	Hours    string `json:"hours"`
-	Date     string `json:"spentOn"`
-	Activity struct {
-		Href  string `json:"href"`
-		Title string `json:"title"`
-	} `json:"activity"`
+	Hours string `json:"hours"`
+	Date  string `json:"spentOn"`
 	Links struct {
 		WorkPackage struct {
 			Href  string `json:"href"`
 			Title string `json:"title"`
 		} `json:"workPackage"`
+		Activity struct {
+			Href  string `json:"href"`
+			Title string `json:"title"`
+		} `json:"activity"`
 	} `json:"_links"`
 	User struct {
 		Href string `json:"href"`
 	} `json:"user"`
 }
 
-// DeleteTimeEntry deletes a time entry.
+// NewTimeEntryRequest returns a request to log the given duration on a work package.
+func NewTimeEntryRequest(userId, workPackageId, activityId int, hours *Duration, comment, spentOn string) *TimeEntryRequest {
+	te := &TimeEntryRequest{}
+	te.Comment.Raw = comment
+	te.Hours = hours.ToIso8601String()
+	te.Date = spentOn
+	te.Links.WorkPackage.Href = workPackageHref(workPackageId)
+	te.User.Href = userHref(userId)
+	te.Links.Activity.Href = activityHref(activityId)
+	return te
+}
+
+// DeleteTimeEntry deletes a time entry. Offline, the deletion is queued.
 func (c *Client) DeleteTimeEntry(id int) error {
+	if c.store != nil && c.store.hasPending(id) {
+		return c.store.queueDelete(id)
+	}
+	err := c.deleteTimeEntry(id)
+	if c.store != nil && isOffline(err) {
+		return c.store.queueDelete(id)
+	}
+	return err
+}
+
+func (c *Client) deleteTimeEntry(id int) error {
 	endpoint := fmt.Sprintf("%stime_entries/%d", c.baseURL, id)
 	_, err := c.doRequest("DELETE", endpoint, nil)
 	if err != nil {
-		return fmt.Errorf("error making request: %v", err)
+		return fmt.Errorf("error making request: %w", err)
+	}
+	if c.store != nil {
+		workPackageIds := c.storedWorkPackageIds(id)
+		c.store.removeTimeEntries([]int{id})
+		c.refreshSpentTime(workPackageIds...)
 	}
 	return nil
 }
 
-// CreateTimeEntry creates a new time entry for a given user.
+// CreateTimeEntry creates a new time entry for a given user. Offline, the creation is queued.
 func (c *Client) CreateTimeEntry(te *TimeEntryRequest) error {
+	err := c.createTimeEntry(te)
+	if c.store != nil && isOffline(err) {
+		return c.store.queueCreate(te)
+	}
+	return err
+}
+
+func (c *Client) createTimeEntry(te *TimeEntryRequest) error {
 	endpoint := fmt.Sprintf("%stime_entries", c.baseURL)
 	jsonValue, err := json.Marshal(te)
 	if err != nil {
 		return fmt.Errorf("error marshalling request: %v", err)
 	}
-	_, err = c.doRequest("POST", endpoint, bytes.NewBuffer(jsonValue))
+	body, err := c.doRequest("POST", endpoint, bytes.NewBuffer(jsonValue))
 	if err != nil {
-		return fmt.Errorf("error making request: %v", err)
+		return fmt.Errorf("error making request: %w", err)
 	}
+	c.saveTimeEntryResponse(body)
 	return nil
 }
 
 // UpdateTimeEntryDuration updates the duration of a time entry.
 func (c *Client) UpdateTimeEntryDuration(timeEntryId int, duration string, comment string, spendOn string) error {
-	endpoint := fmt.Sprintf("%stime_entries/%d", c.baseURL, timeEntryId)
 	update := map[string]interface{}{"hours": duration, "comment": map[string]string{"raw": comment}, "spentOn": spendOn}
+	return c.UpdateTimeEntry(timeEntryId, update)
+}
+
+// UpdateTimeEntry applies a partial update to a time entry. Only the given attributes and links are changed.
+// Offline, the update is queued.
+func (c *Client) UpdateTimeEntry(timeEntryId int, update map[string]interface{}) error {
+	if c.store != nil && c.store.hasPending(timeEntryId) {
+		return c.store.queueUpdate(timeEntryId, update)
+	}
+	err := c.updateTimeEntry(timeEntryId, update)
+	if c.store != nil && isOffline(err) {
+		return c.store.queueUpdate(timeEntryId, update)
+	}
+	return err
+}
+
+func (c *Client) updateTimeEntry(timeEntryId int, update map[string]interface{}) error {
+	endpoint := fmt.Sprintf("%stime_entries/%d", c.baseURL, timeEntryId)
 	jsonValue, err := json.Marshal(update)
 	if err != nil {
 		return fmt.Errorf("error marshalling request: %v", err)
 	}
-	_, err = c.doRequest("PATCH", endpoint, bytes.NewBuffer(jsonValue))
+	body, err := c.doRequest("PATCH", endpoint, bytes.NewBuffer(jsonValue))
 	if err != nil {
-		return fmt.Errorf("error making request: %v", err)
+		return fmt.Errorf("error making request: %w", err)
 	}
+	c.saveTimeEntryResponse(body)
 	return nil
 }
 
+// saveTimeEntryResponse keeps the time entry returned by a change in the local store, so that it is up to date
+// without waiting for the next sync, as is the spent time of its work package.
+func (c *Client) saveTimeEntryResponse(body []byte) {
+	var te TimeEntry
+	if c.store == nil || json.Unmarshal(body, &te) != nil || te.Id == 0 {
+		return
+	}
+	// The time entry may have been moved from another work package.
+	workPackageIds := c.storedWorkPackageIds(te.Id)
+	c.store.upsertTimeEntries([]TimeEntry{te})
+	c.refreshSpentTime(append(workPackageIds, idFromHref(te.Links.WorkPackage.Href))...)
+}
+
 // ListTimeEntries returns a collection of time entries for a given work package.
 func (c *Client) ListTimeEntries(workPackageId int) (*TimeEntryCollection, error) {
 	filters := fmt.Sprintf(filterTimeEntriesWorkPackage, workPackageId)
-	return c.listTimeEntries(filters)
+	return c.listTimeEntries(filters, func(te *TimeEntry) bool {
+		return idFromHref(te.Links.WorkPackage.Href) == workPackageId
+	})
 }
 
 // ListTimeEntriesBefore returns a collection of time entries from the last n days.
 func (c *Client) ListTimeEntriesBefore(userId int, days int) (*TimeEntryCollection, error) {
-	start := time.Now().AddDate(0, 0, -days).Format("2006-01-02")
-	end := time.Now().Format("2006-01-02")
-	filters := fmt.Sprintf(filterTimeEntriesBefore, userId, start, end)
-	return c.listTimeEntries(filters)
+	return c.ListTimeEntriesBetween(userId, time.Now().AddDate(0, 0, -days), time.Now())
+}
+
+// ListTimeEntriesBetween returns a collection of time entries spent between two dates (inclusive).
+func (c *Client) ListTimeEntriesBetween(userId int, start, end time.Time) (*TimeEntryCollection, error) {
+	from, to := start.Format("2006-01-02"), end.Format("2006-01-02")
+	match := func(te *TimeEntry) bool {
+		return idFromHref(te.Links.User.Href) == userId && te.Date >= from && te.Date <= to
+	}
+	if c.store != nil && c.store.mirrors(userId, from) {
+		// The mirror is kept up to date by `Sync`.
+		var collection TimeEntryCollection
+		collection.Embedded.Elements = c.store.applyPending(c.store.timeEntries(match), match)
+		return &collection, nil
+	}
+	filters := fmt.Sprintf(filterTimeEntriesBefore, userId, from, to)
+	return c.listTimeEntries(filters, match)
 }
 
-// listTimeEntries is a helper function to get time entries based on filters.
-func (c *Client) listTimeEntries(filters string) (*TimeEntryCollection, error) {
+// listTimeEntries is a helper function to get time entries based on filters. match must select the same time
+// entries as the filters: it is used to find them in the local store, which is used offline, and to show the pending
+// changes.
+func (c *Client) listTimeEntries(filters string, match func(te *TimeEntry) bool) (*TimeEntryCollection, error) {
+	collection, err := c.fetchTimeEntries(filters)
+	if c.store == nil {
+		return collection, err
+	}
+	switch {
+	case err == nil:
+		c.store.saveTimeEntries(collection.Embedded.Elements, match)
+	case isOffline(err):
+		collection = &TimeEntryCollection{}
+		collection.Embedded.Elements = c.store.timeEntries(match)
+	default:
+		return nil, err
+	}
+	collection.Embedded.Elements = c.store.applyPending(collection.Embedded.Elements, match)
+	return collection, nil
+}
+
+// GetTimeEntry returns a single time entry based on its ID.
+func (c *Client) GetTimeEntry(id int) (*TimeEntry, error) {
+	endpoint := fmt.Sprintf("%stime_entries/%d", c.baseURL, id)
+	body, err := c.doRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	var te TimeEntry
+	if err := json.Unmarshal(body, &te); err != nil {
+		return nil, fmt.Errorf("error unmarshalling response: %v", err)
+	}
+	return &te, nil
+}
+
+func (c *Client) fetchTimeEntries(filters string) (*TimeEntryCollection, error) {
+	return c.fetchTimeEntriesPage(filters, 1, "")
+}
+
+// fetchTimeEntriesPage returns a page of 100 time entries, starting at 1. Unless properties is empty, only those
+// properties are returned, e.g. `total,elements/id`.
+func (c *Client) fetchTimeEntriesPage(filters string, page int, properties string) (*TimeEntryCollection, error) {
 	params := url.Values{}
 	params.Add("pageSize", "100")
+	params.Add("offset", strconv.Itoa(page))
 	params.Add("sortBy", "[[\"spent_on\", \"asc\"]]")
+	if properties != "" {
+		params.Add("select", properties)
+	}
 	params.Add("filters", filters)
 	endpoint := fmt.Sprintf("%stime_entries?%s", c.baseURL, params.Encode())
 
 	body, err := c.doRequest("GET", endpoint, nil)
 	if err != nil {
-		return nil, fmt.Errorf("error making request: %v", err)
+		return nil, fmt.Errorf("error making request: %w", err)
 	}
 
 	var collection TimeEntryCollection
@@ -133,3 +281,25 @@ func (c *Client) listTimeEntries(filters string) (*TimeEntryCollection, error) {
 	}
 	return &collection, nil
 }
+
+// timeEntryKey identifies the time entries that log the same duration and comment on a work package and day.
+func timeEntryKey(workPackageId int, date string, hours *Duration, comment string) string {
+	return fmt.Sprintf("%d|%s|%s|%s", workPackageId, date, hours.ToIso8601String(), strings.TrimSpace(comment))
+}
+
+// existingTimeEntries returns the keys (see `timeEntryKey`) of the time entries logged by a user between two dates.
+func existingTimeEntries(client Backend, userId int, start, end time.Time) (map[string]bool, error) {
+	timeEntries, err := client.ListTimeEntriesBetween(userId, start, end)
+	if err != nil {
+		return nil, err
+	}
+	existing := make(map[string]bool)
+	for _, te := range timeEntries.Embedded.Elements {
+		hours, err := ParseIso8601(te.Hours)
+		if err != nil {
+			return nil, err
+		}
+		existing[timeEntryKey(idFromHref(te.Links.WorkPackage.Href), te.Date, hours, te.Comment.Raw)] = true
+	}
+	return existing, nil
+}
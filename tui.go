@@ -10,14 +10,15 @@ import (
 	"time"
 )
 
-const (
-	help = "<[yellow]N[green]>ew Entry <[yellow]E[green]>dit Entry <[red]D[green]>elete Entry <[yellow]ESC[green]> Return to the list"
-)
-
 type Tui struct {
 	App   *tview.Application
 	Pages *tview.Pages
 
+	// StatusBar line at the bottom, below the pages, with the notifications at its right in statusMessage.
+	StatusBar     *tview.TextView
+	statusMessage *tview.TextView
+	statusFlex    *tview.Flex
+
 	// WorkPackageList view on the left side.
 	WorkPackageList *tview.List
 
@@ -33,10 +34,60 @@ type Tui struct {
 
 	// wp is the currently selected work package.
 	wp *WorkPackage
+
+	// selectWorkPackage shows the details and time entries of the work package at an index of the list.
+	selectWorkPackage func(index int)
+
+	// refreshTotals updates the time logged today and this week in the status bar.
+	refreshTotals func()
+
+	// timeEntries are the time entries shown in `TimeEntriesTable`, in the same order as its rows.
+	timeEntries []TimeEntry
+
+	// marked holds the IDs of the time entries marked for a bulk action.
+	marked map[int]bool
+
+	// templates are the recurring time entries from the config file.
+	templates []Template
+
+	// rounding are the rules applied to the durations logged from the forms.
+	rounding Rounding
+
+	// user, instance and profileName are shown in the status bar, with the totals, the timer and syncStatus.
+	user        *User
+	instance    string
+	profileName string
+	todayTotal  Duration
+	weekTotal   Duration
+	timer       string
+	syncStatus  SyncStatus
+
+	// notification is shown in the status bar until another one replaces it or it expires; lastError is shown when
+	// there is none.
+	notification   string
+	notificationId int
+	lastError      string
+
+	// keys are the keys bound to the actions.
+	keys *keyBindings
+
+	// actions are what the actions do, whether run from their keys or from the command palette.
+	actions map[string]actionHandler
+
+	// helpReturnFocus is the view focused before the help was shown.
+	helpReturnFocus tview.Primitive
+
+	// paletteReturnFocus is the view focused before the command palette was shown.
+	paletteReturnFocus tview.Primitive
 }
 
-func NewTui() *Tui {
-	tui := tview.NewApplication()
+// NewTui builds the UI. It is drawn on screen, or on the terminal if screen is nil; a `tcell.SimulationScreen` runs
+// it headless.
+func NewTui(screen tcell.Screen) *Tui {
+	app := tview.NewApplication()
+	if screen != nil {
+		app.SetScreen(screen)
+	}
 
 	workPackageList := tview.NewList()
 	workPackageList.ShowSecondaryText(false)
@@ -50,7 +101,6 @@ func NewTui() *Tui {
 	timeEntriesTable.SetSelectable(true, false)
 
 	timeEntriesFrame := tview.NewFrame(timeEntriesTable)
-	timeEntriesFrame.AddText(help, false, tview.AlignCenter, tview.Styles.PrimaryTextColor)
 	timeEntriesFrame.SetBorder(true).SetTitle("Time Entries")
 
 	flex := tview.NewFlex().
@@ -61,47 +111,92 @@ func NewTui() *Tui {
 
 	calendarFlex := tview.NewFlex()
 
+	_, statusBackground, _ := theme.style(styleHeader).Decompose()
+	statusBar := tview.NewTextView().SetDynamicColors(true).SetWrap(false)
+	statusBar.SetTextStyle(theme.style(styleHeader)).SetBackgroundColor(statusBackground)
+	statusMessage := tview.NewTextView().SetDynamicColors(true).SetWrap(false).SetTextAlign(tview.AlignRight)
+	statusMessage.SetTextStyle(theme.style(styleHeader)).SetBackgroundColor(statusBackground)
+	statusFlex := tview.NewFlex().
+		AddItem(statusBar, 0, 1, false).
+		AddItem(statusMessage, 0, 0, false)
+
+	theme.apply(flex)
 	pages := tview.NewPages().
 		AddPage("navigation", flex, true, true).
 		AddPage("calendar", calendarFlex, true, false)
 
-	// Navigation.
-	workPackageList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		if event.Key() == tcell.KeyEnter {
-			tui.SetFocus(timeEntriesTable)
-		}
-		return event
-	})
+	root := tview.NewFlex().SetDirection(tview.FlexRow).
+		AddItem(pages, 0, 1, true).
+		AddItem(statusFlex, 1, 0, false)
+	theme.apply(root)
+	app.SetRoot(root, true).EnableMouse(true)
 
-	timeEntriesFrame.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-		if event.Key() == tcell.KeyEscape {
-			tui.SetFocus(workPackageList)
-		}
-		return event
-	})
-
-	return &Tui{
-		App:                 tui,
+	tui := &Tui{
+		App:                 app,
 		Pages:               pages,
+		StatusBar:           statusBar,
+		statusMessage:       statusMessage,
+		statusFlex:          statusFlex,
 		WorkPackageList:     workPackageList,
 		WorkPackageTextView: workPackageTextView,
 		TimeEntriesFrame:    timeEntriesFrame,
 		TimeEntriesTable:    timeEntriesTable,
 		CalendarFlex:        calendarFlex,
 		wp:                  nil,
+		marked:              make(map[int]bool),
+		actions:             make(map[string]actionHandler),
+	}
+	if err := tui.SetupKeymap(nil); err != nil {
+		panic(err)
 	}
+
+	// Navigation.
+	tui.registerAction(actionOpen, actionHandler{
+		run:       func([]string) { app.SetFocus(timeEntriesTable) },
+		available: tui.navigationShown,
+	})
+	tui.registerAction(actionBack, actionHandler{
+		run:       func([]string) { app.SetFocus(workPackageList) },
+		available: tui.navigationShown,
+	})
+	tui.onAction(actionHelp, tui.toggleHelp)
+	tui.onAction(actionPalette, tui.togglePalette)
+
+	workPackageList.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		return tui.runKeyAction(event, "Work packages")
+	})
+	timeEntriesFrame.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		return tui.runKeyAction(event, "Time entries")
+	})
+	app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		if event.Key() == tcell.KeyRune && isTextInput(app.GetFocus()) {
+			// Typed text.
+			return event
+		}
+		if key, ok := navigationKeys[tui.keys.action(event)]; ok {
+			return tcell.NewEventKey(key, 0, tcell.ModNone)
+		}
+		return tui.runKeyAction(event, "Global")
+	})
+
+	return tui
 }
 
-func (tui *Tui) SetupWorkPackages(client *Client, userId int, workPackages *WorkPackageCollection) {
-	tui.WorkPackageList.SetChangedFunc(func(idx int, mainText string, secondaryText string, shortcut rune) {
-		// A work package was selected. Show its details.
+func (tui *Tui) SetupWorkPackages(client Backend, userId int, workPackages *WorkPackageCollection) {
+	// The list is set up again when switching profiles.
+	tui.WorkPackageList.Clear()
+	tui.WorkPackageTextView.Clear()
+	tui.TimeEntriesTable.Clear()
+	tui.timeEntries = nil
+
+	tui.selectWorkPackage = func(idx int) {
 		tui.WorkPackageTextView.Clear()
 		tui.TimeEntriesTable.Clear()
 
 		wp := workPackages.Embedded.Elements[idx]
 		details, err := client.GetWorkPackage(wp.Id)
 		if err != nil {
-			tui.ShowError(err)
+			tui.NotifyError(err)
 			return
 		}
 		tui.wp = details
@@ -109,58 +204,51 @@ func (tui *Tui) SetupWorkPackages(client *Client, userId int, workPackages *Work
 
 		timeEntries, err := client.ListTimeEntries(wp.Id)
 		if err != nil {
-			tui.ShowError(err)
+			tui.NotifyError(err)
 			return
 		}
 		tui.SetupTimeEntries(timeEntries, wp.Id)
+		tui.SetupSyncStatus(client.SyncStatus())
 
-		tui.TimeEntriesTable.
-			SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
-				if event.Key() == tcell.KeyRune {
-					switch event.Rune() {
-					case 'n':
-						tui.showNewTimeEntryForm(client, userId, wp.Id, idx)
-					case 'e':
-						tui.showEditTimeEntryForm(client, idx)
-					case 'd':
-						tui.showDeleteTimeEntryForm(client, idx)
-					}
-				}
-				return event
-			})
-
-		total := NewDuration()
-		for _, te := range timeEntries.Embedded.Elements {
-			hours, err := ParseIso8601(te.Hours)
-			if err != nil {
-				return
-			}
-			total.Add(hours)
-		}
+		tui.registerWorkPackageActions(client, userId, details)
+		tui.registerTimeEntryActions(client, userId, wp.Id, idx)
+
+		total := totalHours(timeEntries.Embedded.Elements)
 
 		tui.TimeEntriesFrame.Clear()
-		tui.TimeEntriesFrame.AddText(help, false, tview.AlignCenter, tview.Styles.PrimaryTextColor)
+		tui.TimeEntriesFrame.AddText(tui.helpLine(), false, tview.AlignCenter, tview.Styles.PrimaryTextColor)
 		// We also need to reset the help text, because it's cleared by the `Clear` call above.
-		tui.TimeEntriesFrame.AddText(fmt.Sprintf("Total: %s", total.ToString()), false, tview.AlignCenter, tcell.ColorYellow)
+		tui.TimeEntriesFrame.AddText(styled(styleAccent, fmt.Sprintf("Total: %s", total.ToString())), false, tview.AlignCenter, tview.Styles.PrimaryTextColor)
+	}
+	tui.WorkPackageList.SetChangedFunc(func(idx int, mainText string, secondaryText string, shortcut rune) {
+		// A work package was selected. Show its details.
+		tui.selectWorkPackage(idx)
 	})
 	for _, wp := range workPackages.Embedded.Elements {
-		title := fmt.Sprintf("[green]%s[white]: %s", wp.Links.Project.Title, wp.Subject)
+		title := fmt.Sprintf("%s: %s", styled(styleLabel, wp.Links.Project.Title), wp.Subject)
 		tui.WorkPackageList.AddItem(title, "", 0, nil)
 	}
+
+	tui.refreshTotals = func() {
+		tui.updateTotals(client, userId)
+	}
+	tui.refreshTotals()
 }
 
 func (tui *Tui) SetupWorkPackage(wp *WorkPackage) {
 	var builder strings.Builder
-	builder.WriteString(fmt.Sprintf("[green]ID[white]: %d\n", wp.Id))
-	builder.WriteString(fmt.Sprintf("[green]Type[white]: %s\n", wp.Type))
-	builder.WriteString(fmt.Sprintf("[green]Status[white]: %s\n", wp.Links.Status.Title))
-	builder.WriteString(fmt.Sprintf("[green]Subject[white]: %s\n", wp.Subject))
-	estimatedTime, err := ParseIso8601(wp.EstimatedTime)
-	if err == nil {
-		builder.WriteString(fmt.Sprintf("[green]Estimated Time[white]: %s\n", estimatedTime.ToString()))
+	builder.WriteString(fmt.Sprintf("%s: %d\n", styled(styleLabel, "ID"), wp.Id))
+	builder.WriteString(fmt.Sprintf("%s: %s\n", styled(styleLabel, "Type"), wp.Type))
+	builder.WriteString(fmt.Sprintf("%s: %s\n", styled(styleLabel, "Status"), wp.Links.Status.Title))
+	builder.WriteString(fmt.Sprintf("%s: %s\n", styled(styleLabel, "Subject"), wp.Subject))
+	if estimatedTime, err := ParseNullableIso8601(wp.EstimatedTime); err == nil && estimatedTime != nil {
+		builder.WriteString(fmt.Sprintf("%s: %s\n", styled(styleLabel, "Estimated Time"), estimatedTime.ToString()))
+	}
+	if spentTime, err := ParseNullableIso8601(wp.SpentTime); err == nil && spentTime != nil {
+		builder.WriteString(fmt.Sprintf("%s: %s\n", styled(styleLabel, "Spent Time"), spentTime.ToString()))
 	}
 	if wp.Description.Raw != "" {
-		builder.WriteString(fmt.Sprintf("[green]Description[white]: %s\n", wp.Description.Raw))
+		builder.WriteString(fmt.Sprintf("%s: %s\n", styled(styleLabel, "Description"), wp.Description.Raw))
 	}
 	tui.WorkPackageTextView.SetText(builder.String())
 }
@@ -168,21 +256,23 @@ func (tui *Tui) SetupWorkPackage(wp *WorkPackage) {
 func (tui *Tui) SetupTimeEntries(timeEntries *TimeEntryCollection, workPackageId int) {
 	headers := []string{"Work Package", "ID", "Duration", "Date", "Comment"}
 	for i, header := range headers {
-		tui.TimeEntriesTable.SetCell(0, i, tview.NewTableCell(header).SetTextColor(tcell.ColorYellow).SetSelectable(false))
+		tui.TimeEntriesTable.SetCell(0, i, tview.NewTableCell(header).SetStyle(theme.textStyle(styleAccent)).SetSelectable(false))
 	}
+	tui.timeEntries = timeEntries.Embedded.Elements
+	// The marks of the time entries still shown are kept, as the time entries are reloaded by the background sync.
+	marked := make(map[int]bool)
 	for i, te := range timeEntries.Embedded.Elements {
-		hours, err := ParseIso8601(te.Hours)
-		if err != nil {
-			tui.ShowError(err)
-			return
+		if tui.marked[te.Id] {
+			marked[te.Id] = true
 		}
-		cellColor := color(te.Comment.Raw)
-		tui.TimeEntriesTable.SetCell(i+1, 0, tview.NewTableCell(fmt.Sprintf("%d", workPackageId)).SetTextColor(cellColor))
-		tui.TimeEntriesTable.SetCell(i+1, 1, tview.NewTableCell(fmt.Sprintf("%d", te.Id)).SetTextColor(cellColor))
-		tui.TimeEntriesTable.SetCell(i+1, 2, tview.NewTableCell(hours.ToString()).SetTextColor(cellColor))
-		tui.TimeEntriesTable.SetCell(i+1, 3, tview.NewTableCell(te.Date).SetTextColor(cellColor))
-		tui.TimeEntriesTable.SetCell(i+1, 4, tview.NewTableCell(te.Comment.Raw).SetExpansion(1).SetTextColor(cellColor))
+		cellStyle := timeEntryStyle(te)
+		tui.TimeEntriesTable.SetCell(i+1, 0, tview.NewTableCell(markText(marked[te.Id], te)).SetStyle(cellStyle))
+		tui.TimeEntriesTable.SetCell(i+1, 1, tview.NewTableCell(fmt.Sprintf("%d", te.Id)).SetStyle(cellStyle))
+		tui.TimeEntriesTable.SetCell(i+1, 2, tview.NewTableCell(formatHours(te.Hours)).SetStyle(cellStyle))
+		tui.TimeEntriesTable.SetCell(i+1, 3, tview.NewTableCell(te.Date).SetStyle(cellStyle))
+		tui.TimeEntriesTable.SetCell(i+1, 4, tview.NewTableCell(te.Comment.Raw).SetExpansion(1).SetStyle(cellStyle))
 	}
+	tui.marked = marked
 	tui.TimeEntriesTable.ScrollToBeginning()
 }
 
@@ -202,37 +292,74 @@ func (tui *Tui) SetupCalendar(timeEntries *TimeEntryCollection) {
 		table.SetBorder(true).SetTitle(date)
 		headers := []string{"ID", "Duration", "Comment"}
 		for i, header := range headers {
-			table.SetCell(0, i, tview.NewTableCell(header).SetTextColor(tcell.ColorYellow).SetSelectable(false))
+			table.SetCell(0, i, tview.NewTableCell(header).SetStyle(theme.textStyle(styleAccent)).SetSelectable(false))
 		}
 		for i, te := range tes {
-			hours, err := ParseIso8601(te.Hours)
-			if err != nil {
-				tui.ShowError(err)
-				return
-			}
-			cellColor := color(te.Comment.Raw)
-			table.SetCell(i+1, 0, tview.NewTableCell(fmt.Sprintf("%d", te.Id)).SetTextColor(cellColor))
-			table.SetCell(i+1, 1, tview.NewTableCell(hours.ToString()).SetTextColor(cellColor))
-			table.SetCell(i+1, 2, tview.NewTableCell(te.Comment.Raw).SetExpansion(1).SetTextColor(cellColor))
+			cellStyle := timeEntryStyle(te)
+			table.SetCell(i+1, 0, tview.NewTableCell(fmt.Sprintf("%d", te.Id)).SetStyle(cellStyle))
+			table.SetCell(i+1, 1, tview.NewTableCell(formatHours(te.Hours)).SetStyle(cellStyle))
+			table.SetCell(i+1, 2, tview.NewTableCell(te.Comment.Raw).SetExpansion(1).SetStyle(cellStyle))
 		}
 
-		total := NewDuration()
-		for _, te := range tes {
-			hours, err := ParseIso8601(te.Hours)
-			if err != nil {
-				return
-			}
-			total.Add(hours)
-		}
+		total := totalHours(tes)
 
 		frame := tview.NewFrame(table).
-			AddText(fmt.Sprintf("Total: %s", total.ToString()), false, tview.AlignCenter, tcell.ColorYellow).
+			AddText(styled(styleAccent, fmt.Sprintf("Total: %s", total.ToString())), false, tview.AlignCenter, tview.Styles.PrimaryTextColor).
 			SetBorders(0, 0, 0, 0, 0, 0)
+		theme.apply(frame)
 		tui.CalendarFlex.AddItem(frame, 0, 1, false)
 	}
 }
 
+// reloadWorkPackage reloads the details and time entries of the work package at the given index of the list, and the
+// totals of the status bar.
+func (tui *Tui) reloadWorkPackage(workPackageIndex int) {
+	if tui.refreshTotals != nil {
+		tui.refreshTotals()
+	}
+	if tui.selectWorkPackage == nil || workPackageIndex < 0 || workPackageIndex >= tui.WorkPackageList.GetItemCount() {
+		return
+	}
+	// `SetCurrentItem` doesn't trigger a `change` event if the item is already selected.
+	if tui.WorkPackageList.GetCurrentItem() == workPackageIndex {
+		tui.selectWorkPackage(workPackageIndex)
+		return
+	}
+	tui.WorkPackageList.SetCurrentItem(workPackageIndex)
+}
+
+// refreshWorkPackages lists the work packages again, keeping the selected one, e.g. after a sync. Nothing is done while
+// a form or another page is shown, not to disturb the user.
+func (tui *Tui) refreshWorkPackages(client Backend, userId int) {
+	if name, _ := tui.Pages.GetFrontPage(); name != "navigation" {
+		return
+	}
+	workPackages, err := client.ListWorkPackages(userId)
+	if err != nil {
+		return
+	}
+	selected := 0
+	for i, wp := range workPackages.Embedded.Elements {
+		if tui.wp != nil && wp.Id == tui.wp.Id {
+			selected = i
+		}
+	}
+	focus := tui.App.GetFocus()
+	tui.SetupWorkPackages(client, userId, workPackages)
+	tui.reloadWorkPackage(selected)
+	tui.App.SetFocus(focus)
+}
+
+// Modal centers a view over the page, with the border and title of the accent style.
 func (tui *Tui) Modal(p tview.Primitive, width, height int) tview.Primitive {
+	if box, ok := p.(interface {
+		SetBorderColor(color tcell.Color) *tview.Box
+		SetTitleColor(color tcell.Color) *tview.Box
+	}); ok {
+		box.SetBorderColor(theme.color(styleAccent))
+		box.SetTitleColor(theme.color(styleAccent))
+	}
+	theme.apply(p)
 	return tview.NewFlex().
 		AddItem(nil, 0, 1, false).
 		AddItem(tview.NewFlex().SetDirection(tview.FlexRow).
@@ -242,43 +369,172 @@ func (tui *Tui) Modal(p tview.Primitive, width, height int) tview.Primitive {
 		AddItem(nil, 0, 1, false)
 }
 
+// ShowError shows an error that must be acknowledged, e.g. changes that could not be saved. Other errors are
+// notified in the status bar by `NotifyError`.
 func (tui *Tui) ShowError(err error) {
+	tui.setLastError(err)
 	modal := tview.NewModal().
 		SetText(err.Error()).
 		AddButtons([]string{"OK"}).
 		SetDoneFunc(func(_ int, _ string) {
 			tui.Pages.RemovePage("error")
 		})
+	theme.apply(modal)
 	tui.Pages.AddPage("error", modal, true, true)
 }
 
+// registerTimeEntryActions sets the actions on the time entries of the work package at an index of the list.
+func (tui *Tui) registerTimeEntryActions(client Backend, userId int, workPackageId int, workPackageIndex int) {
+	for name, run := range map[string]func(){
+		actionNewEntry: func() {
+			tui.showNewTimeEntryForm(client, userId, workPackageId, workPackageIndex, nil)
+		},
+		actionCopyEntry: func() {
+			if te := tui.selectedTimeEntry(); te != nil {
+				tui.showNewTimeEntryForm(client, userId, workPackageId, workPackageIndex, te)
+			}
+		},
+		actionCopyYesterday: func() {
+			yesterday := time.Now().AddDate(0, 0, -1)
+			tui.showCopyTimeEntriesForm(client, userId, workPackageIndex, "yesterday", yesterday, yesterday, 1)
+		},
+		actionCopyLastWeek: func() {
+			monday := startOfWeek(time.Now()).AddDate(0, 0, -7)
+			tui.showCopyTimeEntriesForm(client, userId, workPackageIndex, "last week", monday, monday.AddDate(0, 0, 6), 7)
+		},
+		actionEditEntry: func() {
+			tui.showEditTimeEntryForm(client, workPackageIndex)
+		},
+		actionDeleteEntry: func() {
+			tui.showDeleteTimeEntryForm(client, workPackageIndex)
+		},
+		actionMark: tui.toggleMark,
+		actionBulk: func() {
+			tui.showBulkActions(client, workPackageIndex)
+		},
+		actionTemplates: func() {
+			tui.showApplyTemplatesMenu(client, userId, workPackageIndex)
+		},
+	} {
+		run := run
+		tui.registerAction(name, actionHandler{
+			run:       func([]string) { run() },
+			available: tui.navigationShown,
+		})
+	}
+}
+
+// SetupGlobalActions registers the actions that work everywhere: switching pages, logging time, the pending changes,
+// exporting reports, refreshing and the profiles. current returns the backend and user of the active profile; switchProfile switches to
+// one of profileNames.
+func (tui *Tui) SetupGlobalActions(current func() (Backend, int), profileNames func() []string, switchProfile func(name string)) {
+	tui.onAction(actionNavigation, func() {
+		tui.Pages.SwitchToPage("navigation")
+		tui.App.SetFocus(tui.WorkPackageList)
+	})
+	tui.onAction(actionCalendar, func() {
+		tui.showCalendar(current())
+	})
+	tui.registerAction(actionLogTime, actionHandler{
+		run: func(args []string) {
+			client, userId := current()
+			tui.showLogTimeForm(client, userId, strings.Join(args, " "))
+		},
+		prompts: []actionPrompt{{label: "Work package ID or subject (empty for the recent ones)"}},
+	})
+	tui.registerAction(actionSwitchProfile, actionHandler{
+		run: func(args []string) {
+			if len(args) == 0 {
+				tui.showProfileSwitcher(profileNames(), tui.profileName, switchProfile)
+			} else if args[0] != tui.profileName {
+				switchProfile(args[0])
+			}
+		},
+		prompts: []actionPrompt{{label: "Profile", choices: profileNames}},
+	})
+	tui.onAction(actionPending, func() {
+		client, _ := current()
+		tui.showPendingOperations(client, func() {
+			tui.reloadWorkPackage(tui.WorkPackageList.GetCurrentItem())
+		})
+	})
+	tui.registerAction(actionExportReport, actionHandler{
+		run: func(args []string) {
+			client, userId := current()
+			if len(args) == 0 {
+				tui.showExportReportForm(client, userId)
+			} else {
+				tui.runExportReport(client, userId, args[0], args[1])
+			}
+		},
+		prompts: []actionPrompt{
+			{label: "Period", choices: func() []string { return reportPeriods }},
+			{label: "File (empty for lazyop-report-<start>-<end>.csv)"},
+		},
+	})
+	tui.onAction(actionRefresh, func() {
+		tui.refresh(current())
+	})
+}
+
+// showCalendar shows the time entries of the last week by day. The calendar is updated every time it's shown.
+func (tui *Tui) showCalendar(client Backend, userId int) {
+	timeEntries, err := client.ListTimeEntriesBefore(userId, 7)
+	if err != nil {
+		tui.NotifyError(err)
+		return
+	}
+	tui.CalendarFlex.Clear()
+	tui.SetupCalendar(timeEntries)
+
+	tui.Pages.SwitchToPage("calendar")
+	tui.App.SetFocus(tui.CalendarFlex)
+}
+
 func (tui *Tui) Start() error {
-	return tui.App.SetRoot(tui.Pages, true).EnableMouse(true).Run()
+	return tui.App.Run()
 }
 
-func (tui *Tui) showNewTimeEntryForm(client *Client, userId int, workPackageId int, workPackageIndex int) {
+// showNewTimeEntryForm shows the form to log time on a work package. If template is not nil, the form is prefilled
+// with its duration, comment and activity.
+func (tui *Tui) showNewTimeEntryForm(client Backend, userId int, workPackageId int, workPackageIndex int, template *TimeEntry) {
+	hours, comment, activityId := "1h30m", "", defaultActivityId
+	if template != nil {
+		if duration, err := ParseIso8601(template.Hours); err == nil {
+			hours = duration.ToString()
+		}
+		comment = template.Comment.Raw
+		if id := idFromHref(template.Links.Activity.Href); id != 0 {
+			activityId = id
+		}
+	}
+
 	form := tview.NewForm()
-	form.AddInputField("Hours", "1h30m", 0, nil, nil).
-		AddInputField("Comment", "", 0, nil, nil).
+	tui.addDurationField(form, hours).
+		AddInputField("Comment", comment, 0, nil, nil).
 		AddInputField("Spent on", time.Now().Format("2006-01-02"), 0, nil, nil).
+		AddInputField("Activity ID", strconv.Itoa(activityId), 0, nil, nil).
 		AddButton("Save", func() {
 			hours, err := Parse(form.GetFormItem(0).(*tview.InputField).GetText())
 			if err != nil {
-				tui.ShowError(fmt.Errorf("invalid duration input: %v", err))
+				tui.NotifyError(fmt.Errorf("invalid duration input: %v", err))
 				return
 			}
+			if hours.IsZero() {
+				tui.NotifyError(fmt.Errorf("invalid duration input: the duration must not be zero"))
+				return
+			}
+			hours = tui.rounding.Round(hours)
 
-			comment := form.GetFormItem(1).(*tview.InputField).GetText()
-			spentOn := form.GetFormItem(2).(*tview.InputField).GetText()
-
-			te := &TimeEntryRequest{}
-			te.Comment.Raw = comment
-			te.Hours = hours.ToIso8601String()
-			te.Date = spentOn
-			te.Links.WorkPackage.Href = fmt.Sprintf("/api/v3/work_packages/%d", workPackageId)
-			te.User.Href = fmt.Sprintf("/api/v3/users/%d", userId)
-			te.Activity.Href = "/api/v3/time_entries/activities/1"
+			comment := form.GetFormItem(2).(*tview.InputField).GetText()
+			spentOn := form.GetFormItem(3).(*tview.InputField).GetText()
+			activityId, err := strconv.Atoi(form.GetFormItem(4).(*tview.InputField).GetText())
+			if err != nil {
+				tui.NotifyError(fmt.Errorf("invalid activity ID: %v", err))
+				return
+			}
 
+			te := NewTimeEntryRequest(userId, workPackageId, activityId, hours, comment, spentOn)
 			if err := client.CreateTimeEntry(te); err != nil {
 				tui.ShowError(err)
 				return
@@ -286,10 +542,7 @@ func (tui *Tui) showNewTimeEntryForm(client *Client, userId int, workPackageId i
 
 			tui.Pages.HidePage("newTimeEntryForm")
 			tui.App.SetFocus(tui.TimeEntriesTable)
-			// `SetCurrentItem` doesn't trigger a `change` event if the item is already selected,
-			// so we need to switch to another item first. This is a workaround.
-			tui.WorkPackageList.SetCurrentItem(workPackageIndex + 1)
-			tui.WorkPackageList.SetCurrentItem(workPackageIndex)
+			tui.reloadWorkPackage(workPackageIndex)
 		}).
 		AddButton("Quit", func() {
 			tui.Pages.HidePage("newTimeEntryForm")
@@ -297,17 +550,15 @@ func (tui *Tui) showNewTimeEntryForm(client *Client, userId int, workPackageId i
 		})
 
 	form.SetBorder(true).SetTitle("Log Time").SetTitleAlign(tview.AlignCenter)
-	form.SetBorderColor(tcell.ColorYellow)
-	form.SetTitleColor(tcell.ColorYellow)
 	form.SetCancelFunc(func() {
 		tui.Pages.HidePage("newTimeEntryForm")
 		tui.App.SetFocus(tui.TimeEntriesTable)
 	})
 
-	tui.Pages.AddPage("newTimeEntryForm", tui.Modal(form, 45, 11), true, true)
+	tui.Pages.AddPage("newTimeEntryForm", tui.Modal(form, 45, 15), true, true)
 }
 
-func (tui *Tui) showEditTimeEntryForm(client *Client, workPackageIndex int) {
+func (tui *Tui) showEditTimeEntryForm(client Backend, workPackageIndex int) {
 	row, _ := tui.TimeEntriesTable.GetSelection()
 	if row < 0 {
 		return
@@ -324,18 +575,23 @@ func (tui *Tui) showEditTimeEntryForm(client *Client, workPackageIndex int) {
 	timeEntryComment := tui.TimeEntriesTable.GetCell(row, 4).Text
 
 	form := tview.NewForm()
-	form.AddInputField("Hours", timeEntryHours, 0, nil, nil).
+	tui.addDurationField(form, timeEntryHours).
 		AddInputField("Comment", timeEntryComment, 0, nil, nil).
 		AddInputField("Spent on", timeEntrySpentOn, 0, nil, nil).
 		AddButton("Save changes", func() {
 			hours, err := Parse(form.GetFormItem(0).(*tview.InputField).GetText())
 			if err != nil {
-				tui.ShowError(fmt.Errorf("invalid duration input: %v", err))
+				tui.NotifyError(fmt.Errorf("invalid duration input: %v", err))
+				return
+			}
+			if hours.IsZero() {
+				tui.NotifyError(fmt.Errorf("invalid duration input: the duration must not be zero"))
 				return
 			}
+			hours = tui.rounding.Round(hours)
 
-			comment := form.GetFormItem(1).(*tview.InputField).GetText()
-			spentOn := form.GetFormItem(2).(*tview.InputField).GetText()
+			comment := form.GetFormItem(2).(*tview.InputField).GetText()
+			spentOn := form.GetFormItem(3).(*tview.InputField).GetText()
 
 			if err := client.UpdateTimeEntryDuration(timeEntryId, hours.ToIso8601String(), comment, spentOn); err != nil {
 				tui.ShowError(err)
@@ -344,10 +600,7 @@ func (tui *Tui) showEditTimeEntryForm(client *Client, workPackageIndex int) {
 
 			tui.Pages.HidePage("editTimeEntryForm")
 			tui.App.SetFocus(tui.TimeEntriesTable)
-			// `SetCurrentItem` doesn't trigger a `change` event if the item is already selected,
-			// so we need to switch to another item first. This is a workaround.
-			tui.WorkPackageList.SetCurrentItem(workPackageIndex + 1)
-			tui.WorkPackageList.SetCurrentItem(workPackageIndex)
+			tui.reloadWorkPackage(workPackageIndex)
 		}).
 		AddButton("Quit", func() {
 			tui.Pages.HidePage("editTimeEntryForm")
@@ -355,17 +608,15 @@ func (tui *Tui) showEditTimeEntryForm(client *Client, workPackageIndex int) {
 		})
 
 	form.SetBorder(true).SetTitle(fmt.Sprintf("Edit Time Entry %d", timeEntryId)).SetTitleAlign(tview.AlignCenter)
-	form.SetBorderColor(tcell.ColorYellow)
-	form.SetTitleColor(tcell.ColorYellow)
 	form.SetCancelFunc(func() {
 		tui.Pages.HidePage("editTimeEntryForm")
 		tui.App.SetFocus(tui.TimeEntriesTable)
 	})
 
-	tui.Pages.AddPage("editTimeEntryForm", tui.Modal(form, 45, 11), true, true)
+	tui.Pages.AddPage("editTimeEntryForm", tui.Modal(form, 45, 13), true, true)
 }
 
-func (tui *Tui) showDeleteTimeEntryForm(client *Client, workPackageIndex int) {
+func (tui *Tui) showDeleteTimeEntryForm(client Backend, workPackageIndex int) {
 	row, _ := tui.TimeEntriesTable.GetSelection()
 	if row < 0 {
 		return
@@ -387,10 +638,7 @@ func (tui *Tui) showDeleteTimeEntryForm(client *Client, workPackageIndex int) {
 			}
 			tui.Pages.HidePage("deleteTimeEntryForm")
 			tui.App.SetFocus(tui.TimeEntriesTable)
-			// `SetCurrentItem` doesn't trigger a `change` event if the item is already selected,
-			// so we need to switch to another item first. This is a workaround.
-			tui.WorkPackageList.SetCurrentItem(workPackageIndex + 1)
-			tui.WorkPackageList.SetCurrentItem(workPackageIndex)
+			tui.reloadWorkPackage(workPackageIndex)
 		}).
 		AddButton("Quit", func() {
 			tui.Pages.HidePage("deleteTimeEntryForm")
@@ -398,8 +646,6 @@ func (tui *Tui) showDeleteTimeEntryForm(client *Client, workPackageIndex int) {
 		})
 
 	form.SetBorder(true).SetTitle(fmt.Sprintf("Delete Time Entry %d", timeEntryId)).SetTitleAlign(tview.AlignCenter)
-	form.SetBorderColor(tcell.ColorYellow)
-	form.SetTitleColor(tcell.ColorYellow)
 	form.SetCancelFunc(func() {
 		tui.Pages.HidePage("deleteTimeEntryForm")
 		tui.App.SetFocus(tui.TimeEntriesTable)
@@ -408,9 +654,35 @@ func (tui *Tui) showDeleteTimeEntryForm(client *Client, workPackageIndex int) {
 	tui.Pages.AddPage("deleteTimeEntryForm", tui.Modal(form, 45, 11), true, true)
 }
 
-func color(comment string) tcell.Color {
-	if comment == "" {
-		return tcell.ColorRed
+// timeEntryStyle returns the style of the row of a time entry: time entries with changes that are not on the server
+// yet, then those without a comment, stand out.
+func timeEntryStyle(te TimeEntry) tcell.Style {
+	switch {
+	case te.Pending:
+		return theme.textStyle(styleWarning)
+	case te.Comment.Raw == "":
+		return theme.textStyle(styleMissingComment)
+	}
+	return theme.textStyle(styleText)
+}
+
+// formatHours returns an ISO 8601 duration from the API in a human-readable format. Values that can't be parsed are
+// shown as they are, so that a single unexpected value doesn't hide the rest of the entries.
+func formatHours(hours string) string {
+	duration, err := ParseIso8601(hours)
+	if err != nil {
+		return fmt.Sprintf("%s?", hours)
+	}
+	return duration.ToString()
+}
+
+// totalHours returns the sum of the durations of the time entries, ignoring those that can't be parsed.
+func totalHours(timeEntries []TimeEntry) Duration {
+	total := NewDuration()
+	for _, te := range timeEntries {
+		if hours, err := ParseIso8601(te.Hours); err == nil {
+			total.Add(hours)
+		}
 	}
-	return tcell.ColorWhite
+	return total
 }
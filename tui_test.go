@@ -0,0 +1,490 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+	"github.com/rivo/tview"
+)
+
+// newMemoryTui returns a TUI on a simulation screen listing the work packages of the current user of a backend, with
+// the first one selected, and the screen. The application isn't started.
+func newMemoryTui(t *testing.T, backend Backend) (*Tui, tcell.SimulationScreen) {
+	screen := tcell.NewSimulationScreen("")
+	// The screen is initialised by the application.
+	tui := NewTui(screen)
+	screen.SetSize(120, 40)
+	user, err := backend.GetCurrentUser()
+	if err != nil {
+		t.Fatal(err)
+	}
+	workPackages, err := backend.ListWorkPackages(user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tui.SetupStatusBar(user, "test", "memory")
+	tui.SetupWorkPackages(backend, user.Id, workPackages)
+	tui.SetupGlobalActions(func() (Backend, int) { return backend, user.Id }, func() []string { return []string{"test"} }, func(string) {})
+	tui.reloadWorkPackage(0)
+	return tui, screen
+}
+
+// logTime logs time on a work package of a backend, and returns the ID of the time entry.
+func logTime(t *testing.T, backend *MemoryBackend, workPackageId int, seconds int64, comment, date string) int {
+	user, _ := backend.GetCurrentUser()
+	hours := NewDurationFromSeconds(seconds)
+	if err := backend.CreateTimeEntry(NewTimeEntryRequest(user.Id, workPackageId, 3, &hours, comment, date)); err != nil {
+		t.Fatal(err)
+	}
+	timeEntries, _ := backend.ListTimeEntries(workPackageId)
+	id := 0
+	for _, te := range timeEntries.Embedded.Elements {
+		if te.Id > id {
+			id = te.Id
+		}
+	}
+	return id
+}
+
+func TestRefreshKeepsMarks(t *testing.T) {
+	backend := NewMemoryBackend(User{Id: 1, Name: "Dana"})
+	wp := backend.AddWorkPackage(WorkPackage{Subject: "Landing page"}, 1, false)
+	kept := logTime(t, backend, wp.Id, 3600, "kept", "2024-05-06")
+	deleted := logTime(t, backend, wp.Id, 3600, "deleted", "2024-05-07")
+	unmarked := logTime(t, backend, wp.Id, 3600, "unmarked", "2024-05-08")
+
+	tui, _ := newMemoryTui(t, backend)
+	for i, te := range tui.timeEntries {
+		if te.Id == kept || te.Id == deleted {
+			tui.TimeEntriesTable.Select(i+1, 0)
+			tui.toggleMark()
+		}
+	}
+
+	// The time entry is deleted elsewhere, and the background sync refreshes the list.
+	if err := backend.DeleteTimeEntry(deleted); err != nil {
+		t.Fatal(err)
+	}
+	tui.refreshWorkPackages(backend, 1)
+
+	if !tui.marked[kept] || tui.marked[deleted] || tui.marked[unmarked] || len(tui.marked) != 1 {
+		t.Errorf("marked = %v after a refresh, want only %d", tui.marked, kept)
+	}
+	for i, te := range tui.timeEntries {
+		if got, want := tui.TimeEntriesTable.GetCell(i+1, 0).Text, markText(te.Id == kept, te); got != want {
+			t.Errorf("row of time entry %d = %q, want %q", te.Id, got, want)
+		}
+	}
+}
+
+func TestTuiShowsMemoryBackend(t *testing.T) {
+	backend := NewMemoryBackend(User{Id: 1, Name: "Dana"})
+	landing := backend.AddWorkPackage(WorkPackage{Subject: "Landing page", UpdatedAt: "2024-05-02T10:00:00Z"}, 1, false)
+	backend.AddWorkPackage(WorkPackage{Subject: "Checkout", UpdatedAt: "2024-05-01T10:00:00Z"}, 1, false)
+	backend.AddWorkPackage(WorkPackage{Subject: "Closed page"}, 1, true)
+	backend.AddWorkPackage(WorkPackage{Subject: "Someone else's page"}, 2, false)
+	today := time.Now().Format("2006-01-02")
+	logTime(t, backend, landing.Id, 5400, "Design", today)
+	logTime(t, backend, landing.Id, 1800, "Review", "2024-05-06")
+
+	tui, _ := newMemoryTui(t, backend)
+
+	if got := tui.WorkPackageList.GetItemCount(); got != 2 {
+		t.Errorf("%d work packages listed, want the 2 open ones assigned to Dana", got)
+	}
+	if main, _ := tui.WorkPackageList.GetItemText(0); !strings.Contains(main, "Landing page") {
+		t.Errorf("first work package = %q, want the most recently updated one", main)
+	}
+	details := tui.WorkPackageTextView.GetText(true)
+	for _, want := range []string{fmt.Sprintf("ID: %d", landing.Id), "Subject: Landing page", "Spent Time: 2h"} {
+		if !strings.Contains(details, want) {
+			t.Errorf("details = %q, want %q", details, want)
+		}
+	}
+	var comments []string
+	for _, te := range tui.timeEntries {
+		comments = append(comments, te.Comment.Raw)
+	}
+	if strings.Join(comments, ", ") != "Review, Design" {
+		t.Errorf("time entries = %q, want Review then Design", comments)
+	}
+	if tui.todayTotal.Seconds() != 5400 {
+		t.Errorf("today's total = %s, want 1h30m", tui.todayTotal.ToString())
+	}
+}
+
+// recordingBackend records the changes made to the time entries of a backend.
+type recordingBackend struct {
+	*MemoryBackend
+
+	mu    sync.Mutex
+	calls []string
+}
+
+func (b *recordingBackend) record(call string, args ...interface{}) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.calls = append(b.calls, fmt.Sprintf(call, args...))
+}
+
+// takeCalls returns the calls recorded since the previous time.
+func (b *recordingBackend) takeCalls() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	calls := b.calls
+	b.calls = nil
+	return calls
+}
+
+func (b *recordingBackend) CreateTimeEntry(request *TimeEntryRequest) error {
+	b.record("CreateTimeEntry(%s, %s, %q, %s)", request.Links.WorkPackage.Href, request.Hours, request.Comment.Raw, request.Date)
+	return b.MemoryBackend.CreateTimeEntry(request)
+}
+
+func (b *recordingBackend) UpdateTimeEntryDuration(timeEntryId int, duration string, comment string, spentOn string) error {
+	b.record("UpdateTimeEntryDuration(%d, %s, %q, %s)", timeEntryId, duration, comment, spentOn)
+	return b.MemoryBackend.UpdateTimeEntryDuration(timeEntryId, duration, comment, spentOn)
+}
+
+func (b *recordingBackend) UpdateWorkPackageStatus(workPackageId, statusId int) error {
+	b.record("UpdateWorkPackageStatus(%d, %d)", workPackageId, statusId)
+	return b.MemoryBackend.UpdateWorkPackageStatus(workPackageId, statusId)
+}
+
+func (b *recordingBackend) DeleteTimeEntry(id int) error {
+	b.record("DeleteTimeEntry(%d)", id)
+	return b.MemoryBackend.DeleteTimeEntry(id)
+}
+
+// runningTui is a TUI running on a simulation screen.
+type runningTui struct {
+	*Tui
+	t      *testing.T
+	screen tcell.SimulationScreen
+}
+
+// startMemoryTui runs a TUI showing the work packages of a backend, until the end of the test.
+func startMemoryTui(t *testing.T, backend Backend) *runningTui {
+	tui, screen := newMemoryTui(t, backend)
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		if err := tui.Start(); err != nil {
+			t.Error(err)
+		}
+	}()
+	t.Cleanup(func() {
+		tui.App.Stop()
+		<-stopped
+	})
+	return &runningTui{Tui: tui, t: t, screen: screen}
+}
+
+// text returns what is shown on the screen, line by line. It is read between two draws.
+func (r *runningTui) text() string {
+	var text strings.Builder
+	r.App.QueueUpdate(func() {
+		cells, width, _ := r.screen.GetContents()
+		for i, cell := range cells {
+			if len(cell.Runes) == 0 {
+				text.WriteRune(' ')
+			} else {
+				text.WriteRune(cell.Runes[0])
+			}
+			if (i+1)%width == 0 {
+				text.WriteRune('\n')
+			}
+		}
+	})
+	return text.String()
+}
+
+// waitFor waits until the screen shows all the texts, or none of them if shown is false.
+func (r *runningTui) waitFor(shown bool, texts ...string) {
+	r.t.Helper()
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		text := r.text()
+		matching := 0
+		for _, want := range texts {
+			if strings.Contains(text, want) == shown {
+				matching++
+			}
+		}
+		if matching == len(texts) {
+			return
+		}
+		if time.Now().After(deadline) {
+			r.t.Fatalf("the screen doesn't show %q (shown: %v):\n%s", texts, shown, text)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// press sends keys: names of special keys, e.g. `Enter` or `Ctrl-U`, or texts typed as they are. They are handled
+// in order, but maybe not yet when it returns: use `waitFor`.
+func (r *runningTui) press(keys ...string) {
+	for _, key := range keys {
+		if k, ok := testKeys[key]; ok {
+			r.screen.InjectKey(k, 0, tcell.ModNone)
+			continue
+		}
+		for _, ch := range key {
+			r.screen.InjectKey(tcell.KeyRune, ch, tcell.ModNone)
+		}
+	}
+}
+
+var testKeys = map[string]tcell.Key{
+	"Enter":  tcell.KeyEnter,
+	"Tab":    tcell.KeyTab,
+	"Esc":    tcell.KeyEscape,
+	"Down":   tcell.KeyDown,
+	"Ctrl-U": tcell.KeyCtrlU,
+	"F1":     tcell.KeyF1,
+	"F2":     tcell.KeyF2,
+}
+
+// expectCalls checks the calls made to a backend since the previous check.
+func expectCalls(t *testing.T, backend *recordingBackend, want ...string) {
+	t.Helper()
+	if got := backend.takeCalls(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("backend calls = %q, want %q", got, want)
+	}
+}
+
+func TestTimeEntryKeys(t *testing.T) {
+	backend := &recordingBackend{MemoryBackend: NewMemoryBackend(User{Id: 1, Name: "Dana"})}
+	landing := backend.AddWorkPackage(WorkPackage{Subject: "Landing page", UpdatedAt: "2024-05-02T10:00:00Z"}, 1, false)
+	backend.AddWorkPackage(WorkPackage{Subject: "Checkout", UpdatedAt: "2024-05-01T10:00:00Z"}, 1, false)
+	today := time.Now().Format("2006-01-02")
+	yesterday := time.Now().AddDate(0, 0, -1).Format("2006-01-02")
+	design := logTime(t, backend.MemoryBackend, landing.Id, 3600, "Design", yesterday)
+
+	tui := startMemoryTui(t, backend)
+	tui.waitFor(true, "Landing page", "Checkout", "Design", "Spent Time: 1h")
+
+	// n logs time on the selected work package, which is reloaded although it is already selected.
+	tui.press("Enter", "n")
+	tui.waitFor(true, "Log Time")
+	tui.press("Ctrl-U", "2h", "Tab", "Tab", "Review", "Tab", "Tab", "Tab", "Enter")
+	tui.waitFor(false, "Log Time")
+	tui.waitFor(true, "Review", "Spent Time: 3h", "Total: 3h")
+	expectCalls(t, backend, fmt.Sprintf("CreateTimeEntry(%s, PT2H, \"Review\", %s)", workPackageHref(landing.Id), today))
+
+	// e edits the selected time entry, the first one.
+	tui.press("e")
+	tui.waitFor(true, fmt.Sprintf("Edit Time Entry %d", design))
+	tui.press("Ctrl-U", "45m", "Tab", "Tab", "Ctrl-U", "Mockups", "Tab", "Tab", "Enter")
+	tui.waitFor(false, "Edit Time Entry")
+	tui.waitFor(true, "Mockups", "45m", "Spent Time: 2h45m")
+	tui.waitFor(false, "Design")
+	expectCalls(t, backend, fmt.Sprintf("UpdateTimeEntryDuration(%d, PT45M, \"Mockups\", %s)", design, yesterday))
+
+	// d deletes it after confirmation.
+	tui.press("d")
+	tui.waitFor(true, fmt.Sprintf("Delete Time Entry %d", design), "Are you sure")
+	tui.press("Tab", "Enter")
+	tui.waitFor(false, "Delete Time Entry", "Mockups")
+	tui.waitFor(true, "Review", "Spent Time: 2h")
+	expectCalls(t, backend, fmt.Sprintf("DeleteTimeEntry(%d)", design))
+
+	// Esc closes a form without changing anything.
+	tui.press("n")
+	tui.waitFor(true, "Log Time")
+	tui.press("Esc")
+	tui.waitFor(false, "Log Time")
+	expectCalls(t, backend)
+}
+
+func TestPageKeys(t *testing.T) {
+	backend := &recordingBackend{MemoryBackend: NewMemoryBackend(User{Id: 1, Name: "Dana"})}
+	landing := backend.AddWorkPackage(WorkPackage{Subject: "Landing page", UpdatedAt: "2024-05-02T10:00:00Z"}, 1, false)
+	checkout := backend.AddWorkPackage(WorkPackage{Subject: "Checkout", UpdatedAt: "2024-05-01T10:00:00Z"}, 1, false)
+	today := time.Now()
+	logTime(t, backend.MemoryBackend, landing.Id, 3600, "Design", today.Format("2006-01-02"))
+	logTime(t, backend.MemoryBackend, checkout.Id, 1800, "Payments", today.AddDate(0, 0, -2).Format("2006-01-02"))
+	logTime(t, backend.MemoryBackend, checkout.Id, 1800, "Long ago", today.AddDate(0, 0, -30).Format("2006-01-02"))
+
+	tui := startMemoryTui(t, backend)
+	tui.waitFor(true, "Work Packages", "Design")
+
+	// F2 shows the time entries of the last week by day, and F1 the work packages again.
+	tui.press("F2")
+	tui.waitFor(true, today.Format("2006-01-02"), today.AddDate(0, 0, -2).Format("2006-01-02"), "Design", "Payments")
+	tui.waitFor(false, "Work Packages", "Long ago")
+	tui.press("F1")
+	tui.waitFor(true, "Work Packages", "Work Package Details", "Design")
+
+	// Moving in the list shows the work package selected.
+	tui.press("Down")
+	tui.waitFor(true, "Subject: Checkout", "Payments", "Long ago")
+	tui.waitFor(false, "Design")
+
+	// Reloading another work package selects it.
+	tui.App.QueueUpdateDraw(func() { tui.reloadWorkPackage(0) })
+	tui.waitFor(true, "Subject: Landing page", "Design")
+	tui.App.QueueUpdate(func() {
+		if got := tui.WorkPackageList.GetCurrentItem(); got != 0 {
+			t.Errorf("work package %d selected after reloading the first one", got)
+		}
+	})
+	expectCalls(t, backend)
+}
+
+func TestWorkPackageAndReportActions(t *testing.T) {
+	backend := &recordingBackend{MemoryBackend: NewMemoryBackend(User{Id: 1, Name: "Dana"})}
+	landing := backend.AddWorkPackage(WorkPackage{Subject: "Landing page", UpdatedAt: "2024-05-02T10:00:00Z"}, 1, false)
+	checkout := backend.AddWorkPackage(WorkPackage{Subject: "Checkout", UpdatedAt: "2024-05-01T10:00:00Z"}, 1, false)
+	today := time.Now().Format("2006-01-02")
+	logTime(t, backend.MemoryBackend, landing.Id, 3600, "Design", today)
+	logTime(t, backend.MemoryBackend, checkout.Id, 1800, "Payments", today)
+
+	tui := startMemoryTui(t, backend)
+	tui.waitFor(true, "Status: New", "Design")
+
+	// s lists the statuses of the selected work package; a closed one is no longer listed.
+	tui.press("s")
+	tui.waitFor(true, "Change Status", "In progress", "Rejected")
+	tui.press("Down", "Down", "Down", "Enter")
+	tui.waitFor(true, "Landing page is now Closed.", "Subject: Checkout")
+	tui.waitFor(false, "Change Status", "Design")
+	expectCalls(t, backend, fmt.Sprintf("UpdateWorkPackageStatus(%d, 4)", landing.Id))
+
+	// The palette asks for the status.
+	tui.press(":", "change status", "Enter")
+	tui.waitFor(true, "Change status", "Status: ")
+	tui.press("progress", "Enter")
+	tui.waitFor(true, "Checkout is now In progress.", "Status: In progress")
+	expectCalls(t, backend, fmt.Sprintf("UpdateWorkPackageStatus(%d, 2)", checkout.Id))
+
+	// And for the period and file of a report.
+	path := filepath.Join(t.TempDir(), "report.csv")
+	tui.press(":", "export", "Enter")
+	tui.waitFor(true, "Period: ", "last month")
+	tui.press("Enter")
+	tui.waitFor(true, "File (empty for")
+	tui.press(path, "Enter")
+	tui.waitFor(true, "Exported 2 time entries from this week")
+	report, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(report), today+",0.50,,Payments") || !strings.Contains(string(report), today+",1.00,,Design") {
+		t.Errorf("report:\n%s\nwant the time entries of today", report)
+	}
+	expectCalls(t, backend)
+}
+
+// styleAt returns the style of the first cell of a text shown on the screen.
+func (r *runningTui) styleAt(text string) tcell.Style {
+	r.t.Helper()
+	var style tcell.Style
+	found := false
+	r.App.QueueUpdate(func() {
+		cells, width, _ := r.screen.GetContents()
+		var line []rune
+		for i, cell := range cells {
+			ch := ' '
+			if len(cell.Runes) > 0 {
+				ch = cell.Runes[0]
+			}
+			line = append(line, ch)
+			if (i+1)%width != 0 {
+				continue
+			}
+			if x := strings.Index(string(line), text); x >= 0 {
+				x = len([]rune(string(line)[:x]))
+				_, _, style, _ = r.screen.GetContent(x, i/width)
+				found = true
+				return
+			}
+			line = line[:0]
+		}
+	})
+	if !found {
+		r.t.Fatalf("the screen doesn't show %q", text)
+	}
+	return style
+}
+
+func TestThemeSelectionStyle(t *testing.T) {
+	previous, previousStyles := theme, tview.Styles
+	t.Cleanup(func() { theme, tview.Styles = previous, previousStyles })
+	if err := UseTheme("light", nil); err != nil {
+		t.Fatal(err)
+	}
+	wantFg, wantBg, _ := theme.style(styleSelection).Decompose()
+
+	backend := NewMemoryBackend(User{Id: 1, Name: "Dana"})
+	wp := backend.AddWorkPackage(WorkPackage{Subject: "Landing page"}, 1, false)
+	logTime(t, backend, wp.Id, 3600, "Design", "2024-05-06")
+	tui := startMemoryTui(t, backend)
+	tui.waitFor(true, "Landing page", "Design")
+
+	// The selected work package, then the selected time entry.
+	for _, text := range []string{"Landing page", "Design"} {
+		if text == "Design" {
+			tui.press("Enter")
+			tui.waitFor(true, "Design")
+		}
+		deadline := time.Now().Add(5 * time.Second)
+		for {
+			fg, bg, _ := tui.styleAt(text).Decompose()
+			if fg == wantFg && bg == wantBg {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("selected %q drawn in %v on %v, want the selection style of the light theme, %v on %v", text, fg, bg, wantFg, wantBg)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+}
+
+func TestNoColor(t *testing.T) {
+	previous, previousStyles := theme, tview.Styles
+	t.Cleanup(func() { theme, tview.Styles = previous, previousStyles })
+	t.Setenv("NO_COLOR", "1")
+	themes := map[string]Theme{"mine": {Base: "light", Styles: map[string]string{styleAccent: "#ff8800:navy"}}}
+	if err := UseTheme("mine", themes); err != nil {
+		t.Fatal(err)
+	}
+
+	for name, style := range theme {
+		if fg, bg, _ := style.style.Decompose(); fg != tcell.ColorDefault || bg != tcell.ColorDefault {
+			t.Errorf("style %s is %v on %v, want no colors", name, fg, bg)
+		}
+	}
+	styles := reflect.ValueOf(tview.Styles)
+	for i := 0; i < styles.NumField(); i++ {
+		if color, ok := styles.Field(i).Interface().(tcell.Color); ok && color != tcell.ColorDefault {
+			t.Errorf("tview.Styles.%s = %v, want the default color", styles.Type().Field(i).Name, color)
+		}
+	}
+
+	// Nothing on the screen has a color, selections included.
+	backend := NewMemoryBackend(User{Id: 1, Name: "Dana"})
+	wp := backend.AddWorkPackage(WorkPackage{Subject: "Landing page"}, 1, false)
+	logTime(t, backend, wp.Id, 3600, "", "2024-05-06")
+	tui := startMemoryTui(t, backend)
+	tui.waitFor(true, "Landing page", "Spent Time: 1h")
+	tui.press("Enter", "n")
+	tui.waitFor(true, "Log Time")
+	tui.App.QueueUpdate(func() {
+		cells, width, _ := tui.screen.GetContents()
+		for i, cell := range cells {
+			if fg, bg, _ := cell.Style.Decompose(); fg != tcell.ColorDefault || bg != tcell.ColorDefault {
+				t.Errorf("cell %d,%d %q is %v on %v, want no colors", i%width, i/width, string(cell.Runes), fg, bg)
+				return
+			}
+		}
+	})
+}
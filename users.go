@@ -0,0 +1,59 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// User represents an OpenProject user.
+type User struct {
+	Id    int    `json:"id"`
+	Login string `json:"login"`
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+// GetCurrentUser returns the user the API key belongs to. Offline, the user last seen is returned.
+func (c *Client) GetCurrentUser() (*User, error) {
+	user, err := c.fetchCurrentUser()
+	if c.store == nil {
+		return user, err
+	}
+	switch {
+	case err == nil:
+		c.store.saveUser(user)
+	case isOffline(err):
+		if cached := c.store.user(); cached != nil {
+			return cached, nil
+		}
+	}
+	return user, err
+}
+
+func (c *Client) fetchCurrentUser() (*User, error) {
+	endpoint := fmt.Sprintf("%susers/me", c.baseURL)
+	body, err := c.doRequest("GET", endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	var user User
+	if err := json.Unmarshal(body, &user); err != nil {
+		return nil, fmt.Errorf("error unmarshalling response: %v", err)
+	}
+	return &user, nil
+}
+
+// resolveCurrentUser returns the user the API key of a profile belongs to. The user ID of the profile is filled in
+// if it isn't configured; a configured ID that belongs to someone else is an error, as it would show their work.
+func resolveCurrentUser(client Backend, profile *Profile) (*User, error) {
+	user, err := client.GetCurrentUser()
+	if err != nil {
+		return nil, fmt.Errorf("error getting the current user: %v", err)
+	}
+	if profile.UserID == 0 {
+		profile.UserID = user.Id
+	} else if profile.UserID != user.Id {
+		return nil, fmt.Errorf("user_id %d doesn't match the user of the API key, %s (%d)", profile.UserID, user.Name, user.Id)
+	}
+	return user, nil
+}
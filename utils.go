@@ -2,89 +2,120 @@ package main
 
 import (
 	"fmt"
+	"math"
 	"regexp"
 	"strconv"
 	"strings"
 )
 
-// Duration struct to hold hours and minutes.
+// HoursPerDay is the length of the working days typed in durations, e.g. `2d`. It defaults to 8 and can be changed in
+// the config file. The days of the ISO 8601 durations exchanged with OpenProject, e.g. `P2D`, are always 24 hours.
+var HoursPerDay = 8.0
+
+var (
+	// durationNumber matches a plain decimal number, e.g. `1.5`.
+	durationNumber = regexp.MustCompile(`^\d+(?:[.,]\d+)?$`)
+
+	// durationComponent matches a number followed by a unit at the start of a human-readable duration, e.g. `1.5h`.
+	durationComponent = regexp.MustCompile(`^(\d+(?:[.,]\d+)?)\s*([a-zA-Z]+)\s*`)
+
+	// durationClock matches a duration written as a clock, e.g. `1:30` or `01:30:00`.
+	durationClock = regexp.MustCompile(`^(\d+):(\d{1,2})(?::(\d{1,2}(?:[.,]\d+)?))?$`)
+)
+
+// durationUnits are the units of human-readable durations, from the largest to the smallest.
+var durationUnits = []struct {
+	names   []string
+	seconds func() float64
+}{
+	{[]string{"d", "day", "days"}, func() float64 { return HoursPerDay * 3600 }},
+	{[]string{"h", "hr", "hrs", "hour", "hours"}, func() float64 { return 3600 }},
+	{[]string{"m", "min", "mins", "minute", "minutes"}, func() float64 { return 60 }},
+	{[]string{"s", "sec", "secs", "second", "seconds"}, func() float64 { return 1 }},
+}
+
+// Duration is a length of time with a precision of one second. Hours, minutes and seconds are always normalised,
+// e.g. 90 minutes is 1h30m.
 type Duration struct {
-	Hours   int
-	Minutes int
+	seconds int64
 }
 
 func NewDuration() Duration {
 	return Duration{}
 }
 
-// Parse parses a duration string.
+// NewDurationFromSeconds returns a duration of the given number of seconds.
+func NewDurationFromSeconds(seconds int64) Duration {
+	return Duration{seconds: seconds}
+}
+
+// durationFromSeconds returns a duration of the given (fractional) number of seconds, rounded to the nearest second.
+func durationFromSeconds(seconds float64) *Duration {
+	return &Duration{seconds: int64(math.Round(seconds))}
+}
+
+// parseDecimal parses a non-negative decimal number that may use a comma as decimal separator.
+func parseDecimal(number string) float64 {
+	value, _ := strconv.ParseFloat(strings.Replace(number, ",", ".", 1), 64)
+	return value
+}
+
+// Parse parses a human-readable duration such as `1h30m`, `1h 30m`, `1.5h`, `90m`, `1:30` or `2d` (see
+// `HoursPerDay`). A number without a unit is a number of hours.
 func Parse(duration string) (*Duration, error) {
-	re := regexp.MustCompile(`(\d+h)?(\d+m)?`)
-	matches := re.FindStringSubmatch(duration)
-	if matches == nil {
-		return nil, fmt.Errorf("invalid duration format")
+	input := strings.TrimSpace(duration)
+	if input == "" {
+		return nil, fmt.Errorf("empty duration")
 	}
-	d := NewDuration()
-	for _, match := range matches[1:] {
-		if match == "" {
-			continue
+
+	if strings.Contains(input, ":") {
+		matches := durationClock.FindStringSubmatch(input)
+		if matches == nil {
+			return nil, fmt.Errorf("invalid duration %q: expected hours:minutes[:seconds]", duration)
 		}
-		switch {
-		case strings.HasSuffix(match, "h"):
-			h, err := strconv.Atoi(strings.TrimSuffix(match, "h"))
-			if err != nil {
-				return nil, err
-			}
-			d.Hours = h
-		case strings.HasSuffix(match, "m"):
-			m, err := strconv.Atoi(strings.TrimSuffix(match, "m"))
-			if err != nil {
-				return nil, err
-			}
-			d.Minutes = m
+		minutes, _ := strconv.Atoi(matches[2])
+		seconds := parseDecimal(matches[3])
+		if minutes >= 60 || seconds >= 60 {
+			return nil, fmt.Errorf("invalid duration %q: minutes and seconds must be less than 60", duration)
 		}
+		return durationFromSeconds(parseDecimal(matches[1])*3600 + float64(minutes)*60 + seconds), nil
 	}
-	return &d, nil
-}
 
-// ParseIso8601 parses a duration string in ISO 8601 format.
-func ParseIso8601(duration string) (*Duration, error) {
-	re := regexp.MustCompile(`PT(\d+H)?(\d+M)?`)
-	matches := re.FindStringSubmatch(duration)
-	if matches == nil {
-		return nil, fmt.Errorf("invalid duration format: %s", duration)
+	if durationNumber.MatchString(input) {
+		return durationFromSeconds(parseDecimal(input) * 3600), nil
 	}
-	d := NewDuration()
-	for _, match := range matches[1:] {
-		if match == "" {
-			continue
+
+	total := 0.0
+	previous := -1
+	for rest := input; rest != ""; {
+		matches := durationComponent.FindStringSubmatch(rest)
+		if matches == nil {
+			return nil, fmt.Errorf("invalid duration %q: unexpected %q, expected a number followed by a unit (d, h, m or s)", duration, rest)
 		}
-		switch {
-		case strings.HasSuffix(match, "H"):
-			h, err := strconv.Atoi(strings.TrimSuffix(match, "H"))
-			if err != nil {
-				return nil, err
+		unit := -1
+		for i, u := range durationUnits {
+			for _, name := range u.names {
+				if strings.EqualFold(matches[2], name) {
+					unit = i
+				}
 			}
-			d.Hours = h
-		case strings.HasSuffix(match, "M"):
-			m, err := strconv.Atoi(strings.TrimSuffix(match, "M"))
-			if err != nil {
-				return nil, err
-			}
-			d.Minutes = m
 		}
+		if unit < 0 {
+			return nil, fmt.Errorf("invalid duration %q: unknown unit %q", duration, matches[2])
+		}
+		if unit <= previous {
+			return nil, fmt.Errorf("invalid duration %q: unit %q is repeated or out of order", duration, matches[2])
+		}
+		previous = unit
+		total += parseDecimal(matches[1]) * durationUnits[unit].seconds()
+		rest = rest[len(matches[0]):]
 	}
-	return &d, nil
+	return durationFromSeconds(total), nil
 }
 
 // Add adds another duration to the current duration.
 func (d *Duration) Add(other *Duration) {
-	d.Hours += other.Hours
-	d.Minutes += other.Minutes
-	if d.Minutes >= 60 {
-		d.Hours++
-		d.Minutes -= 60
-	}
+	d.seconds += other.seconds
 }
 
 // Adds hours to the current duration.
@@ -94,25 +125,51 @@ func (d *Duration) Adds(other *[]Duration) {
 	}
 }
 
-// ToIso8601String returns the duration as an ISO 8601 string.
-func (d *Duration) ToIso8601String() string {
-	var isoDuration []string
-	isoDuration = append(isoDuration, "PT")
-	if d.Hours > 0 {
-		isoDuration = append(isoDuration, fmt.Sprintf("%dH", d.Hours))
+// Sub subtracts another duration from the current duration. The result may be negative.
+func (d *Duration) Sub(other *Duration) {
+	d.seconds -= other.seconds
+}
+
+// Compare returns -1, 0 or 1 if the duration is shorter than, equal to or longer than the other one.
+func (d *Duration) Compare(other *Duration) int {
+	switch {
+	case d.seconds < other.seconds:
+		return -1
+	case d.seconds > other.seconds:
+		return 1
 	}
-	if d.Minutes > 0 {
-		isoDuration = append(isoDuration, fmt.Sprintf("%dM", d.Minutes))
+	return 0
+}
+
+// IsZero reports whether the duration is zero.
+func (d *Duration) IsZero() bool {
+	return d.seconds == 0
+}
+
+// Seconds returns the total number of seconds of the duration.
+func (d *Duration) Seconds() int64 {
+	return d.seconds
+}
+
+// components returns the sign and the hours, minutes and seconds of the duration.
+func (d *Duration) components() (sign string, hours, minutes, seconds int64) {
+	total := d.seconds
+	if total < 0 {
+		sign, total = "-", -total
 	}
-	return strings.Join(isoDuration, "")
+	return sign, total / 3600, total % 3600 / 60, total % 60
 }
 
-// ToString returns the duration as a human-readable string in the format "HhMm".
+// ToString returns the duration as a human-readable string in the format "HhMm" (and "Ss" if there are seconds).
 func (d *Duration) ToString() string {
+	sign, hours, minutes, seconds := d.components()
 	var humanReadable []string
-	humanReadable = append(humanReadable, fmt.Sprintf("%dh", d.Hours))
-	if d.Minutes > 0 {
-		humanReadable = append(humanReadable, fmt.Sprintf("%dm", d.Minutes))
+	humanReadable = append(humanReadable, fmt.Sprintf("%s%dh", sign, hours))
+	if minutes > 0 {
+		humanReadable = append(humanReadable, fmt.Sprintf("%dm", minutes))
+	}
+	if seconds > 0 {
+		humanReadable = append(humanReadable, fmt.Sprintf("%ds", seconds))
 	}
 	return strings.Join(humanReadable, "")
 }
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// withHoursPerDay sets `HoursPerDay` for the duration of a test.
+func withHoursPerDay(t *testing.T, hours float64) {
+	previous := HoursPerDay
+	HoursPerDay = hours
+	t.Cleanup(func() { HoursPerDay = previous })
+}
+
+func TestParse(t *testing.T) {
+	withHoursPerDay(t, 8)
+	tests := []struct {
+		input   string
+		seconds int64
+	}{
+		{"1h30m", 5400},
+		{"1h 30m", 5400},
+		{"1.5h", 5400},
+		{"1,5h", 5400},
+		{"1.5", 5400},
+		{"2", 7200},
+		{"90m", 5400},
+		{"1:30", 5400},
+		{"01:30:00", 5400},
+		{"0:45:30", 2730},
+		{"2d", 2 * 8 * 3600},
+		{"1d 2h", 10 * 3600},
+		{"1 day 2 hours", 10 * 3600},
+		{"45 mins", 2700},
+		{"30s", 30},
+		{"1H30M", 5400},
+		{"  2h  ", 7200},
+		{"0.25h", 900},
+		{"0", 0},
+	}
+	for _, test := range tests {
+		d, err := Parse(test.input)
+		if err != nil {
+			t.Errorf("Parse(%q): %v", test.input, err)
+			continue
+		}
+		if d.Seconds() != test.seconds {
+			t.Errorf("Parse(%q) = %ds, want %ds", test.input, d.Seconds(), test.seconds)
+		}
+	}
+}
+
+func TestParseHoursPerDay(t *testing.T) {
+	withHoursPerDay(t, 7.5)
+	d, err := Parse("2d")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d.Seconds() != 15*3600 {
+		t.Errorf("Parse(2d) = %ds with 7.5 hours per day, want %ds", d.Seconds(), 15*3600)
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{"", "empty duration"},
+		{"   ", "empty duration"},
+		{"abc", `unexpected "abc"`},
+		{"1x", `unknown unit "x"`},
+		{"30m 1h", `unit "h" is repeated or out of order`},
+		{"1h 1h", `unit "h" is repeated or out of order`},
+		{"1:60", "minutes and seconds must be less than 60"},
+		{"1:30:60", "minutes and seconds must be less than 60"},
+		{"1:", "expected hours:minutes[:seconds]"},
+		{"-1h", `unexpected "-1h"`},
+		{"1h30", `unexpected "30"`},
+	}
+	for _, test := range tests {
+		d, err := Parse(test.input)
+		if err == nil {
+			t.Errorf("Parse(%q) = %s, want an error", test.input, d.ToString())
+			continue
+		}
+		if !strings.Contains(err.Error(), test.err) {
+			t.Errorf("Parse(%q) error = %q, want it to contain %q", test.input, err, test.err)
+		}
+	}
+}
+
+func TestDurationArithmetic(t *testing.T) {
+	d := NewDurationFromSeconds(3600)
+	d.Add(&Duration{seconds: 5400})
+	if d.Seconds() != 9000 {
+		t.Errorf("1h + 1h30m = %ds, want 9000s", d.Seconds())
+	}
+	d.Sub(&Duration{seconds: 10800})
+	if d.Seconds() != -1800 {
+		t.Errorf("2h30m - 3h = %ds, want -1800s", d.Seconds())
+	}
+	if got := d.ToString(); got != "-0h30m" {
+		t.Errorf("ToString() = %q, want %q", got, "-0h30m")
+	}
+
+	one, two := NewDurationFromSeconds(3600), NewDurationFromSeconds(7200)
+	if one.Compare(&two) != -1 || two.Compare(&one) != 1 || one.Compare(&one) != 0 {
+		t.Error("Compare doesn't order 1h and 2h")
+	}
+}
+
+func TestToString(t *testing.T) {
+	tests := []struct {
+		seconds int64
+		want    string
+	}{
+		{0, "0h"},
+		{5400, "1h30m"},
+		{90 * 60, "1h30m"},
+		{3661, "1h1m1s"},
+		{26 * 3600, "26h"},
+	}
+	for _, test := range tests {
+		d := NewDurationFromSeconds(test.seconds)
+		if got := d.ToString(); got != test.want {
+			t.Errorf("ToString() of %ds = %q, want %q", test.seconds, got, test.want)
+		}
+	}
+}
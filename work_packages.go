@@ -1,18 +1,25 @@
 package main
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"net/url"
+	"strconv"
 )
 
 var (
 	// filterWorkPackageAssignedTo is a filter to get open work packages assigned to a specific user.
 	filterWorkPackageAssignedTo = "[{\"assigned_to\":{\"operator\":\"=\",\"values\":[\"%d\"]}},{\"status\":{\"operator\":\"o\",\"values\":[]}}]"
+
+	// filterWorkPackageSubject is a filter to get work packages whose subject contains a (JSON-quoted) text.
+	filterWorkPackageSubject = "[{\"subject\":{\"operator\":\"~\",\"values\":[%s]}}]"
 )
 
 // WorkPackageCollection represents a collection of work packages.
 type WorkPackageCollection struct {
+	// Total is the number of work packages matching the filters, across all pages.
+	Total    int `json:"total"`
 	Embedded struct {
 		Elements []WorkPackage `json:"elements"`
 	} `json:"_embedded"`
@@ -41,16 +48,60 @@ type WorkPackage struct {
 		Status struct {
 			Title string `json:"title"`
 			Href  string `json:"href"`
-		}
+		} `json:"status"`
 	} `json:"_links"`
 }
 
-// GetWorkPackage returns a single work package based on its ID.
+// Status is a status a work package can be in.
+type Status struct {
+	Id       int    `json:"id"`
+	Name     string `json:"name"`
+	IsClosed bool   `json:"isClosed"`
+}
+
+// workPackageForm is the form of a work package: its current lock version, and the statuses it can be set to.
+type workPackageForm struct {
+	Embedded struct {
+		Payload struct {
+			LockVersion int `json:"lockVersion"`
+		} `json:"payload"`
+		Schema struct {
+			Status struct {
+				Embedded struct {
+					AllowedValues []Status `json:"allowedValues"`
+				} `json:"_embedded"`
+			} `json:"status"`
+		} `json:"schema"`
+	} `json:"_embedded"`
+}
+
+// GetWorkPackage returns a single work package based on its ID. Mirrored or offline, the copy from the local store is
+// returned.
 func (c *Client) GetWorkPackage(workPackageId int) (*WorkPackage, error) {
+	if c.store != nil && c.store.mirror() != nil && c.store.isAssigned(workPackageId) {
+		// The assigned work packages are kept up to date by `Sync`.
+		return c.store.workPackage(workPackageId), nil
+	}
+	wp, err := c.fetchWorkPackage(workPackageId)
+	if c.store == nil {
+		return wp, err
+	}
+	switch {
+	case err == nil:
+		c.store.saveWorkPackages([]WorkPackage{*wp})
+	case isOffline(err):
+		if cached := c.store.workPackage(workPackageId); cached != nil {
+			return cached, nil
+		}
+	}
+	return wp, err
+}
+
+func (c *Client) fetchWorkPackage(workPackageId int) (*WorkPackage, error) {
 	endpoint := fmt.Sprintf("%swork_packages/%d", c.baseURL, workPackageId)
 	body, err := c.doRequest("GET", endpoint, nil)
 	if err != nil {
-		return nil, fmt.Errorf("error making request: %v", err)
+		return nil, fmt.Errorf("error making request: %w", err)
 	}
 	var wp WorkPackage
 	if err := json.Unmarshal(body, &wp); err != nil {
@@ -61,23 +112,69 @@ func (c *Client) GetWorkPackage(workPackageId int) (*WorkPackage, error) {
 
 // ListWorkPackages returns a collection of open work packages assigned to a specific user.
 func (c *Client) ListWorkPackages(userId int) (*WorkPackageCollection, error) {
+	if c.store != nil {
+		// The mirror is kept up to date by `Sync`.
+		if state := c.store.mirror(); state != nil && state.UserId == userId {
+			assigned, _ := c.store.assignedWorkPackages()
+			return assigned, nil
+		}
+	}
 	filters := fmt.Sprintf(filterWorkPackageAssignedTo, userId)
-	return c.listWorkPackages(filters)
+	collection, err := c.listWorkPackages(filters)
+	if c.store == nil {
+		return collection, err
+	}
+	switch {
+	case err == nil:
+		c.store.saveAssignedWorkPackages(collection.Embedded.Elements)
+	case isOffline(err):
+		if cached, ok := c.store.assignedWorkPackages(); ok {
+			return cached, nil
+		}
+	}
+	return collection, err
 }
 
-// listTimeEntries is a helper function to get time entries based on filters.
+// SearchWorkPackages returns a collection of work packages, in any project and status, whose subject contains the given text.
+func (c *Client) SearchWorkPackages(subject string) (*WorkPackageCollection, error) {
+	quoted, err := json.Marshal(subject)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling subject: %v", err)
+	}
+	filters := fmt.Sprintf(filterWorkPackageSubject, quoted)
+	collection, err := c.listWorkPackages(filters)
+	if c.store == nil {
+		return collection, err
+	}
+	switch {
+	case err == nil:
+		c.store.saveWorkPackages(collection.Embedded.Elements)
+	case isOffline(err):
+		return c.store.searchWorkPackages(subject), nil
+	}
+	return collection, err
+}
+
+// listWorkPackages is a helper function to get work packages based on filters.
 func (c *Client) listWorkPackages(filters string) (*WorkPackageCollection, error) {
+	return c.listWorkPackagesPage(filters, 1, "*,elements/*,self/status")
+}
+
+// listWorkPackagesPage returns a page of 100 work packages, starting at 1, with the given properties (see the
+// `select` parameter of the API).
+func (c *Client) listWorkPackagesPage(filters string, page int, properties string) (*WorkPackageCollection, error) {
 	params := url.Values{}
 	params.Add("pageSize", "100")
+	params.Add("offset", strconv.Itoa(page))
 	params.Add("sortBy", "[[\"updated_at\", \"desc\"]]")
 	params.Add("groupBy", "status")
-	params.Add("select", "*,elements/*,self/status")
+	params.Add("select", properties)
 	params.Add("filters", filters)
 	endpoint := fmt.Sprintf("%swork_packages?%s", c.baseURL, params.Encode())
 
 	body, err := c.doRequest("GET", endpoint, nil)
 	if err != nil {
-		return nil, fmt.Errorf("error making request: %v", err)
+		return nil, fmt.Errorf("error making request: %w", err)
 	}
 
 	var collection WorkPackageCollection
@@ -86,3 +183,65 @@ func (c *Client) listWorkPackages(filters string) (*WorkPackageCollection, error
 	}
 	return &collection, nil
 }
+
+// ListStatuses returns the statuses a work package can be set to, as allowed by the workflows of its type and the
+// roles of the user.
+func (c *Client) ListStatuses(workPackageId int) ([]Status, error) {
+	form, err := c.fetchWorkPackageForm(workPackageId)
+	if err != nil {
+		return nil, err
+	}
+	return form.Embedded.Schema.Status.Embedded.AllowedValues, nil
+}
+
+// UpdateWorkPackageStatus sets the status of a work package. Unlike the changes to time entries, it isn't queued
+// offline: it is made against the lock version of the work package, which could be outdated by the time it is sent.
+func (c *Client) UpdateWorkPackageStatus(workPackageId, statusId int) error {
+	form, err := c.fetchWorkPackageForm(workPackageId)
+	if err != nil {
+		return err
+	}
+	var status *Status
+	for _, allowed := range form.Embedded.Schema.Status.Embedded.AllowedValues {
+		if allowed.Id == statusId {
+			allowed := allowed
+			status = &allowed
+		}
+	}
+	if status == nil {
+		return fmt.Errorf("work package %d can't be set to status %d", workPackageId, statusId)
+	}
+
+	update := map[string]interface{}{
+		"lockVersion": form.Embedded.Payload.LockVersion,
+		"_links":      map[string]interface{}{"status": map[string]string{"href": statusHref(statusId)}},
+	}
+	jsonValue, err := json.Marshal(update)
+	if err != nil {
+		return fmt.Errorf("error marshalling request: %v", err)
+	}
+	endpoint := fmt.Sprintf("%swork_packages/%d", c.baseURL, workPackageId)
+	body, err := c.doRequest("PATCH", endpoint, bytes.NewBuffer(jsonValue))
+	if err != nil {
+		return fmt.Errorf("error making request: %w", err)
+	}
+	var wp WorkPackage
+	if c.store != nil && json.Unmarshal(body, &wp) == nil && wp.Id != 0 {
+		c.store.replaceWorkPackage(wp, !status.IsClosed)
+	}
+	return nil
+}
+
+// fetchWorkPackageForm returns the form of a work package, without changes.
+func (c *Client) fetchWorkPackageForm(workPackageId int) (*workPackageForm, error) {
+	endpoint := fmt.Sprintf("%swork_packages/%d/form", c.baseURL, workPackageId)
+	body, err := c.doRequest("POST", endpoint, bytes.NewBufferString("{}"))
+	if err != nil {
+		return nil, fmt.Errorf("error making request: %w", err)
+	}
+	var form workPackageForm
+	if err := json.Unmarshal(body, &form); err != nil {
+		return nil, fmt.Errorf("error unmarshalling response: %v", err)
+	}
+	return &form, nil
+}